@@ -19,8 +19,16 @@ package v1beta1
 import (
 	"cloudnativeapp/clm/internal"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// Set "true" to plan the crd release without applying it, the same as spec.dryRun.
+const DryRunAnnotation = "clm.cloudnativeapp.io/dry-run"
+
+// Names of the modules separated by comma, or "*" for all modules, to renew their recover budget. The modules
+// RecoveryExhausted recover again, the annotation is removed after that.
+const ResetRecoveryAnnotation = "clm.cloudnativeapp.io/reset-recovery"
+
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
@@ -35,6 +43,55 @@ type CRDReleaseSpec struct {
 	Dependencies []internal.Dependency `json:"dependencies,omitempty"`
 	// CRDRelease consist of multi modules, every module implements part of functions of release
 	Modules []internal.Module `json:"modules,omitempty"`
+	// The number of old revisions to retain, defaults to 10.
+	// +optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+	// The revision to rollback to, it is cleared after the rollback applied.
+	// +optional
+	RollbackTo *RollbackConfig `json:"rollbackTo,omitempty"`
+	// Policy to handle the module upgrades failed.
+	// +optional
+	UpgradePolicy *UpgradePolicy `json:"upgradePolicy,omitempty"`
+	// Plan the actions to status.plan without applying them.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+	// How to handle the crd releases depending on this one and the modules installed when it is deleted.
+	// Block, Cascade or Orphan, defaults to Cascade.
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// Parameters of the crd release, referred by the templates in module values as .Parameters. The templated
+	// modules are upgraded when the parameters change.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Parameters *runtime.RawExtension `json:"parameters,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Block;Cascade;Orphan
+type DeletionPolicy string
+
+const (
+	// Refuse the deletion while crd releases depending on it exist.
+	DeletionBlock DeletionPolicy = "Block"
+	// Delete the crd releases depending on it first, then uninstall the modules.
+	DeletionCascade DeletionPolicy = "Cascade"
+	// Remove the crd release and leave the modules installed, the crd releases depending on it are kept.
+	DeletionOrphan DeletionPolicy = "Orphan"
+)
+
+type UpgradePolicy struct {
+	// Rollback the module to the configuration of the previous revision when it does not turn running
+	// within the deadline after upgrade.
+	// +optional
+	AutoRollback bool `json:"autoRollback,omitempty"`
+	// Seconds for a module to turn running after upgrade, defaults to 600.
+	// +optional
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+}
+
+type RollbackConfig struct {
+	// The revision to rollback to. If set to 0, rollback to the last revision.
+	// +optional
+	Revision int64 `json:"revision,omitempty"`
 }
 
 // CRDReleaseStatus defines the observed state of CRDRelease
@@ -48,6 +105,11 @@ type CRDReleaseStatus struct {
 	CurrentVersion string                   `json:"currentVersion,omitempty"`
 	Phase          internal.CRDReleasePhase `json:"phase,omitempty"`
 	Reason         string                   `json:"reason,omitempty"`
+	// Revision of the crd release spec applied latest, see CRDReleaseRevision.
+	Revision int64 `json:"revision,omitempty"`
+	// Actions planned in dry-run mode, cleared after dry-run mode turned off.
+	// +optional
+	Plan *internal.ReleasePlan `json:"plan,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -0,0 +1,209 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"cloudnativeapp/clm/internal"
+	"cloudnativeapp/clm/pkg/dag"
+	"context"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var crdreleaselog = logf.Log.WithName("crdrelease-resource")
+
+// webhookClient is used to find the other crd releases when checking dependency cycles.
+var webhookClient client.Reader
+
+func (r *CRDRelease) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	webhookClient = mgr.GetClient()
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-clm-cloudnativeapp-io-v1beta1-crdrelease,mutating=true,failurePolicy=fail,groups=clm.cloudnativeapp.io,resources=crdreleases,verbs=create;update,versions=v1beta1,name=mcrdrelease.kb.io
+
+var _ webhook.Defaulter = &CRDRelease{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *CRDRelease) Default() {
+	crdreleaselog.Info("default", "name", r.Name)
+	for i := range r.Spec.Modules {
+		r.Spec.Modules[i].Default()
+	}
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-clm-cloudnativeapp-io-v1beta1-crdrelease,mutating=false,failurePolicy=fail,groups=clm.cloudnativeapp.io,resources=crdreleases,versions=v1beta1,name=vcrdrelease.kb.io
+
+var _ webhook.Validator = &CRDRelease{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *CRDRelease) ValidateCreate() error {
+	crdreleaselog.Info("validate create", "name", r.Name)
+	return r.validateCRDRelease()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *CRDRelease) ValidateUpdate(old runtime.Object) error {
+	crdreleaselog.Info("validate update", "name", r.Name)
+	if r.GetDeletionTimestamp() != nil {
+		// Do not block finalizers removing.
+		return nil
+	}
+	return r.validateCRDRelease()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *CRDRelease) ValidateDelete() error {
+	return nil
+}
+
+func (r *CRDRelease) validateCRDRelease() error {
+	allErrs := r.validateCRDReleaseSpec()
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("CRDRelease").GroupKind(), r.Name, allErrs)
+}
+
+func (r *CRDRelease) validateCRDReleaseSpec() field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	modules := make(map[string]bool)
+	for i, m := range r.Spec.Modules {
+		p := specPath.Child("modules").Index(i)
+		if modules[m.Name] {
+			allErrs = append(allErrs, field.Duplicate(p.Child("name"), m.Name))
+		}
+		modules[m.Name] = true
+		allErrs = append(allErrs, m.Validate(p)...)
+		// The controller execs with its own permissions, namespaced releases may not exec in the other namespaces.
+		if e := m.Readiness.Exec; e != nil && len(r.Namespace) > 0 && e.Namespace != r.Namespace {
+			allErrs = append(allErrs, field.Invalid(p.Child("readiness", "exec", "namespace"), e.Namespace,
+				"must be the namespace of the crd release"))
+		}
+	}
+	for i, m := range r.Spec.Modules {
+		for j, d := range m.DependsOn {
+			if !modules[d] {
+				allErrs = append(allErrs, field.NotFound(specPath.Child("modules").Index(i).Child("dependsOn").Index(j), d))
+			}
+		}
+	}
+	if len(allErrs) == 0 {
+		if _, err := internal.SortModules(r.Spec.Modules, false); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("modules"), getModuleNames(r), "dependsOn cycle found"))
+		}
+	}
+
+	dependencies := make(map[string]bool)
+	for i, d := range r.Spec.Dependencies {
+		p := specPath.Child("dependencies").Index(i)
+		if dependencies[d.Name] {
+			allErrs = append(allErrs, field.Duplicate(p.Child("name"), d.Name))
+		}
+		dependencies[d.Name] = true
+		allErrs = append(allErrs, d.Validate(p)...)
+	}
+
+	if r.Spec.RevisionHistoryLimit != nil && *r.Spec.RevisionHistoryLimit < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("revisionHistoryLimit"), *r.Spec.RevisionHistoryLimit,
+			"must be greater than or equal to 0"))
+	}
+	if p := r.Spec.UpgradePolicy; p != nil && p.ProgressDeadlineSeconds != nil && *p.ProgressDeadlineSeconds <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("upgradePolicy", "progressDeadlineSeconds"),
+			*p.ProgressDeadlineSeconds, "must be greater than 0"))
+	}
+	switch r.Spec.DeletionPolicy {
+	case "", DeletionBlock, DeletionCascade, DeletionOrphan:
+	default:
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("deletionPolicy"), r.Spec.DeletionPolicy,
+			[]string{string(DeletionBlock), string(DeletionCascade), string(DeletionOrphan)}))
+	}
+	if r.Spec.RollbackTo != nil && r.Spec.RollbackTo.Revision < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("rollbackTo", "revision"), r.Spec.RollbackTo.Revision,
+			"must be greater than or equal to 0"))
+	}
+
+	if len(allErrs) == 0 {
+		if err := r.validateDependencyCycle(specPath.Child("dependencies")); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	return allErrs
+}
+
+//validateDependencyCycle  check the release does not depend on itself through other crd releases in its namespace.
+func (r *CRDRelease) validateDependencyCycle(fldPath *field.Path) *field.Error {
+	if len(r.Spec.Dependencies) == 0 {
+		return nil
+	}
+	releases := map[string][]string{r.Name: getDependencyNames(r)}
+	if webhookClient != nil {
+		list := &CRDReleaseList{}
+		if err := webhookClient.List(context.Background(), list, client.InNamespace(r.Namespace)); err != nil {
+			crdreleaselog.Error(err, "unable to fetch crd release list", "name", r.Name)
+			return field.InternalError(fldPath, err)
+		}
+		for i := range list.Items {
+			if list.Items[i].Name != r.Name {
+				releases[list.Items[i].Name] = getDependencyNames(&list.Items[i])
+			}
+		}
+	}
+
+	d := new(dag.DAG)
+	d.Init()
+	for name, deps := range releases {
+		// Absent dependencies are waited or pulled, only the existing ones can make a cycle.
+		var existing []string
+		for _, dep := range deps {
+			if _, ok := releases[dep]; ok {
+				existing = append(existing, dep)
+			}
+		}
+		d.AddNode(name, existing)
+	}
+	if !d.Shape() {
+		return field.Invalid(fldPath, getDependencyNames(r), "dependency cycle found")
+	}
+	return nil
+}
+
+func getModuleNames(r *CRDRelease) []string {
+	var result []string
+	for _, m := range r.Spec.Modules {
+		result = append(result, m.Name)
+	}
+	return result
+}
+
+func getDependencyNames(r *CRDRelease) []string {
+	var result []string
+	for _, d := range r.Spec.Dependencies {
+		result = append(result, d.Name)
+	}
+	return result
+}
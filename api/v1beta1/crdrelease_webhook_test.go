@@ -0,0 +1,171 @@
+package v1beta1
+
+import (
+	"cloudnativeapp/clm/internal"
+	"cloudnativeapp/clm/pkg/check/resource"
+	"cloudnativeapp/clm/pkg/probe"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"strings"
+	"testing"
+)
+
+func initTestRelease() *CRDRelease {
+	return &CRDRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "a"},
+		Spec: CRDReleaseSpec{
+			Version:      "1.0.0",
+			Dependencies: []internal.Dependency{{Name: "b", Version: "1.0.0"}},
+			Modules: []internal.Module{
+				{
+					Name: "m1",
+					Readiness: probe.Probe{
+						Handler: probe.Handler{
+							TCPSocket: &probe.TCPSocketAction{Host: "svc", Port: intstr.FromInt(80)},
+						},
+					},
+				},
+				{Name: "m2"},
+			},
+		},
+	}
+}
+
+func TestCRDRelease_Default(t *testing.T) {
+	r := initTestRelease()
+	r.Default()
+	p := r.Spec.Modules[0].Readiness
+	if p.PeriodSeconds != probe.MinPeriodSeconds || p.SuccessThreshold != 1 || p.FailureThreshold != 1 ||
+		p.RecoverThreshold != 1 {
+		t.Errorf("readiness not defaulted: %v", p)
+	}
+	if r.Spec.Modules[1].Readiness != (probe.Probe{}) {
+		t.Errorf("empty readiness should not be defaulted: %v", r.Spec.Modules[1].Readiness)
+	}
+}
+
+func TestCRDRelease_ValidateCreate(t *testing.T) {
+	if err := initTestRelease().ValidateCreate(); err != nil {
+		t.Errorf("validate release failed: %v", err)
+	}
+
+	r := initTestRelease()
+	r.Spec.Modules[1].Name = "m1"
+	if err := r.ValidateCreate(); err == nil {
+		t.Errorf("repeated module should be rejected")
+	}
+
+	r = initTestRelease()
+	r.Spec.Dependencies = append(r.Spec.Dependencies, internal.Dependency{Name: "a"})
+	if err := r.ValidateCreate(); err == nil {
+		t.Errorf("dependency cycle should be rejected")
+	}
+
+	r = initTestRelease()
+	r.Spec.Modules[0].DependsOn = []string{"m3"}
+	if err := r.ValidateCreate(); err == nil {
+		t.Errorf("absent dependsOn module should be rejected")
+	}
+
+	r = initTestRelease()
+	r.Spec.Modules[0].DependsOn = []string{"m2"}
+	if err := r.ValidateCreate(); err != nil {
+		t.Errorf("validate dependsOn failed: %v", err)
+	}
+	r.Spec.Modules[1].DependsOn = []string{"m1"}
+	if err := r.ValidateCreate(); err == nil {
+		t.Errorf("dependsOn cycle should be rejected")
+	}
+
+	r = initTestRelease()
+	r.Spec.Modules[0].Readiness.TCPSocket.Port = intstr.FromInt(70000)
+	if err := r.ValidateCreate(); err == nil {
+		t.Errorf("invalid probe port should be rejected")
+	}
+
+	r = initTestRelease()
+	r.Spec.Modules[0].Readiness.HTTPGet = &probe.HTTPGetAction{Host: "svc", Port: intstr.FromInt(80)}
+	if err := r.ValidateCreate(); err == nil || !strings.Contains(err.Error(), "more than 1 handler type") {
+		t.Errorf("multiple probe handlers should be rejected, got %v", err)
+	}
+
+	r = initTestRelease()
+	r.Spec.Modules[0].Readiness.TCPSocket = nil
+	r.Spec.Modules[0].Readiness.HTTPGet = &probe.HTTPGetAction{Host: "svc", Port: intstr.FromInt(80),
+		Success: &probe.HTTPSuccessCriteria{Mode: probe.HTTPSuccessMatch, StatusCodes: []string{"200-299"},
+			JSONPath: "{.status.phase}", Value: "Running"}}
+	if err := r.ValidateCreate(); err != nil {
+		t.Errorf("validate http success criteria failed: %v", err)
+	}
+	r.Spec.Modules[0].Readiness.HTTPGet.Success.BodyRegex = "("
+	r.Spec.Modules[0].Readiness.HTTPGet.Success.StatusCodes = []string{"299-200"}
+	if err := r.ValidateCreate(); err == nil {
+		t.Errorf("invalid http success criteria should be rejected")
+	}
+
+	r = initTestRelease()
+	r.Spec.Modules[0].Readiness.TCPSocket = nil
+	r.Spec.Modules[0].Readiness.Exec = &probe.ExecAction{Namespace: "default", Selector: map[string]string{"app": "web"}}
+	if err := r.ValidateCreate(); err == nil {
+		t.Errorf("exec probe without command should be rejected")
+	}
+	r.Spec.Modules[0].Readiness.Exec.Command = []string{"cat", "/tmp/ready"}
+	if err := r.ValidateCreate(); err != nil {
+		t.Errorf("validate exec probe failed: %v", err)
+	}
+	r.Namespace = "tenant"
+	if err := r.ValidateCreate(); err == nil {
+		t.Errorf("exec probe in the other namespace of namespaced release should be rejected")
+	}
+	r.Spec.Modules[0].Readiness.Exec.Namespace = "tenant"
+	if err := r.ValidateCreate(); err != nil {
+		t.Errorf("validate exec probe in the release namespace failed: %v", err)
+	}
+
+	r = initTestRelease()
+	r.Spec.Modules[0].Readiness.TCPSocket = nil
+	r.Spec.Modules[0].Readiness.Workloads = &probe.WorkloadsAction{}
+	if err := r.ValidateCreate(); err != nil {
+		t.Errorf("validate workloads probe of source objects failed: %v", err)
+	}
+	r.Spec.Modules[0].Readiness.Workloads.Objects = []probe.WorkloadReference{{Kind: "Pod", Name: "web"}}
+	r.Spec.Modules[0].Readiness.Workloads.Selector = map[string]string{"app": "web"}
+	if err := r.ValidateCreate(); err == nil {
+		t.Errorf("invalid workloads probe should be rejected")
+	}
+
+	r = initTestRelease()
+	r.Spec.Modules[0].PreCheck.ResourceExist = []resource.Resource{{Type: "nodes", Selector: "role in ("}}
+	if err := r.ValidateCreate(); err == nil {
+		t.Errorf("invalid pre-check selector should be rejected")
+	}
+	r = initTestRelease()
+	r.Spec.Modules[0].PreCheck.KubernetesVersion = ">= 1.16 <"
+	if err := r.ValidateCreate(); err == nil {
+		t.Errorf("invalid kubernetes version constraint should be rejected")
+	}
+
+	r = initTestRelease()
+	r.Spec.Dependencies[0].Strategy = internal.PullIfAbsent
+	r.Spec.Dependencies[0].Registry = internal.Registry{Host: "registry:port"}
+	if err := r.ValidateCreate(); err == nil {
+		t.Errorf("malformed registry host should be rejected")
+	}
+
+	r = initTestRelease()
+	deadline := int32(0)
+	r.Spec.UpgradePolicy = &UpgradePolicy{AutoRollback: true, ProgressDeadlineSeconds: &deadline}
+	if err := r.ValidateCreate(); err == nil {
+		t.Errorf("non-positive progress deadline should be rejected")
+	}
+
+	r = initTestRelease()
+	r.Spec.DeletionPolicy = DeletionOrphan
+	if err := r.ValidateCreate(); err != nil {
+		t.Errorf("validate deletion policy failed: %v", err)
+	}
+	r.Spec.DeletionPolicy = "Delete"
+	if err := r.ValidateCreate(); err == nil {
+		t.Errorf("unsupported deletion policy should be rejected")
+	}
+}
@@ -0,0 +1,54 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Label of the crd release name on its revisions.
+const RevisionReleaseLabel = "clm.cloudnativeapp.io/release"
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Release",type="string",JSONPath=".metadata.labels.clm\\.cloudnativeapp\\.io/release"
+// +kubebuilder:printcolumn:name="Revision",type="integer",JSONPath=".revision"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// CRDReleaseRevision is an immutable snapshot of a CRDRelease spec applied, like ControllerRevision.
+type CRDReleaseRevision struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// The crd release spec applied in the revision, rollback settings are not recorded.
+	Spec CRDReleaseSpec `json:"spec,omitempty"`
+	// Revision indicates the revision of the crd release spec.
+	Revision int64 `json:"revision"`
+}
+
+// +kubebuilder:object:root=true
+
+// CRDReleaseRevisionList contains a list of CRDReleaseRevision
+type CRDReleaseRevisionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CRDReleaseRevision `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CRDReleaseRevision{}, &CRDReleaseRevisionList{})
+}
@@ -0,0 +1,115 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"cloudnativeapp/clm/pkg/implement/helm"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var sourcelog = logf.Log.WithName("source-resource")
+
+func (r *Source) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-clm-cloudnativeapp-io-v1beta1-source,mutating=true,failurePolicy=fail,groups=clm.cloudnativeapp.io,resources=sources,verbs=create;update,versions=v1beta1,name=msource.kb.io
+
+var _ webhook.Defaulter = &Source{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *Source) Default() {
+	sourcelog.Info("default", "name", r.Name)
+	if len(r.Spec.Type) == 0 {
+		r.Spec.Type = r.Spec.Implement.Type()
+	}
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-clm-cloudnativeapp-io-v1beta1-source,mutating=false,failurePolicy=fail,groups=clm.cloudnativeapp.io,resources=sources,versions=v1beta1,name=vsource.kb.io
+
+var _ webhook.Validator = &Source{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Source) ValidateCreate() error {
+	sourcelog.Info("validate create", "name", r.Name)
+	return r.validateSource()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Source) ValidateUpdate(old runtime.Object) error {
+	sourcelog.Info("validate update", "name", r.Name)
+	if r.GetDeletionTimestamp() != nil {
+		// Do not block finalizers removing.
+		return nil
+	}
+	return r.validateSource()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Source) ValidateDelete() error {
+	return nil
+}
+
+func (r *Source) validateSource() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	implementErrs := r.Spec.Implement.Validate(specPath.Child("implement"))
+	allErrs = append(allErrs, implementErrs...)
+	if len(implementErrs) == 0 && len(r.Spec.Type) > 0 && r.Spec.Type != r.Spec.Implement.Type() {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("type"), r.Spec.Type,
+			fmt.Sprintf("does not match the %s implement", r.Spec.Implement.Type())))
+	}
+	allErrs = append(allErrs, r.validateSecretNamespaces(specPath.Child("implement", "helm"))...)
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Source").GroupKind(), r.Name, allErrs)
+}
+
+//validateSecretNamespaces  only the cluster scoped sources refer to the secrets in other namespaces.
+func (r *Source) validateSecretNamespaces(fldPath *field.Path) field.ErrorList {
+	h := r.Spec.Implement.Helm
+	if len(r.Namespace) == 0 || h == nil {
+		return nil
+	}
+	var allErrs field.ErrorList
+	check := func(ref *helm.SecretReference, p *field.Path) {
+		if ref != nil && len(ref.Namespace) > 0 && ref.Namespace != r.Namespace {
+			allErrs = append(allErrs, field.Forbidden(p.Child("namespace"),
+				"may not refer to secrets in other namespaces"))
+		}
+	}
+	for k, repo := range h.Repositories {
+		check(repo.SecretRef, fldPath.Child("repositories").Index(k).Child("secretRef"))
+	}
+	for k, registry := range h.Registries {
+		check(registry.SecretRef, fldPath.Child("registries").Index(k).Child("secretRef"))
+	}
+	if h.Verify != nil {
+		check(&h.Verify.KeyringSecretRef, fldPath.Child("verify", "keyringSecretRef"))
+	}
+	return allErrs
+}
@@ -0,0 +1,34 @@
+package v1beta1
+
+import (
+	"cloudnativeapp/clm/pkg/implement"
+	"cloudnativeapp/clm/pkg/implement/helm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"testing"
+)
+
+func TestSource_ValidateSecretNamespaces(t *testing.T) {
+	s := &Source{
+		ObjectMeta: metav1.ObjectMeta{Name: "charts"},
+		Spec: SourceSpec{Implement: implement.Implement{Helm: &helm.Implement{
+			Repositories: []helm.Repo{{Name: "apps", Url: "https://charts.example.com",
+				SecretRef: &helm.SecretReference{Name: "creds", Namespace: "tenant-a"}}},
+			Verify: &helm.Verify{KeyringSecretRef: helm.SecretReference{Name: "keys", Namespace: "tenant-a"}},
+		}}},
+	}
+	if err := s.ValidateCreate(); err != nil {
+		t.Errorf("cluster scoped source should refer to secrets in any namespace, got %v", err)
+	}
+
+	s.Namespace = "tenant-a"
+	if err := s.ValidateCreate(); err != nil {
+		t.Errorf("source should refer to secrets in its namespace, got %v", err)
+	}
+	s.Namespace = "tenant-b"
+	err := s.ValidateCreate()
+	if err == nil || !strings.Contains(err.Error(), "repositories[0].secretRef.namespace") ||
+		!strings.Contains(err.Error(), "verify.keyringSecretRef.namespace") {
+		t.Errorf("namespaced source should not refer to secrets in other namespaces, got %v", err)
+	}
+}
@@ -22,14 +22,22 @@ import (
 	"context"
 	"errors"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"time"
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	clmv1beta1 "cloudnativeapp/clm/api/v1beta1"
 )
@@ -48,6 +56,13 @@ const CycleDelay = 10
 // +kubebuilder:rbac:groups=clm.cloudnativeapp.io,resources=crdreleases,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=clm.cloudnativeapp.io,resources=crdreleases/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=clm.cloudnativeapp.io,resources=events,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=clm.cloudnativeapp.io,resources=crdreleaserevisions,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+// +kubebuilder:rbac:groups="",resources=secrets;configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list
 
 func (r *CRDReleaseReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -61,20 +76,48 @@ func (r *CRDReleaseReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 	}
 	log.V(utils.Info).Info("succeed get release", "name", release.Name, "version", release.Spec.Version)
 	log.V(utils.Debug).Info("source values", "value", release.Spec.Modules[0].Source.Values)
+	if isDryRun(release) && release.GetDeletionTimestamp() == nil {
+		return ctrl.Result{}, r.planRelease(log, release)
+	}
+	rollback := false
+	if release.Spec.RollbackTo != nil && release.GetDeletionTimestamp() == nil {
+		found, err := rollbackRelease(release)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !found {
+			// Give up the rollback.
+			return ctrl.Result{}, r.Update(ctx, release)
+		}
+		rollback = true
+	}
+	reset := false
+	if release.GetDeletionTimestamp() == nil {
+		reset = resetRecovery(release)
+	}
 	// Record crd release status.
-	if !internal.RecordStatus(release.Name, release.Spec.Version, *release.Status.DeepCopy()) {
+	if !internal.RecordStatus(utils.NamespacedKey(release.Namespace, release.Name), release.Spec.Version, *release.Status.DeepCopy()) {
 		// retry later
 		releaseLog.V(utils.Info).Info("crd release is processing, retry later.")
 		return ctrl.Result{Requeue: true, RequeueAfter: 10 * time.Second}, nil
 	}
 	defer func() {
-		internal.DeleteStatus(release.Name, release.Spec.Version)
+		internal.DeleteStatus(utils.NamespacedKey(release.Namespace, release.Name), release.Spec.Version)
 		if p := recover(); p != nil {
 			log.Error(errors.New("panic occurs"), "panic occurs", "panic:", p)
 			r.updateRelease(log, internal.CRDReleaseAbnormal, "panic", release)
 			r.Eventer.Eventf(release, v1.EventTypeWarning, "Panic", "panic:%v", p)
 		}
 	}()
+	// Roll back under the status record, so the concurrent reconciles do not roll back twice.
+	rolledBack := false
+	if release.GetDeletionTimestamp() == nil {
+		var err error
+		if rolledBack, err = autoRollback(release); err != nil {
+			return ctrl.Result{}, err
+		}
+		rollback = rollback || rolledBack
+	}
 
 	if release.GetDeletionTimestamp() != nil {
 		log.V(utils.Info).Info("crd release is going to be deleted", "name", release.Name,
@@ -99,10 +142,12 @@ func (r *CRDReleaseReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 			return reconcile.Result{}, err
 		}
 	}
+	// Dry-run mode turned off.
+	release.Status.Plan = nil
 
 	var reason string
 	var crdReleasePhase internal.CRDReleasePhase
-	if ok, err := CheckCRDRelease(release); err != nil {
+	if ok, err := CheckCRDRelease(release, rollback); err != nil {
 		log.Error(err, "crd release check error", "spec", release.Spec, "status", release.Status)
 		r.Eventer.Eventf(release, v1.EventTypeWarning, "Error", "crd release check error:%v", err)
 		reason = err.Error()
@@ -121,19 +166,53 @@ func (r *CRDReleaseReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 			"version", release.Spec.Version)
 		crdReleasePhase = internal.CRDReleaseRunning
 	}
+	if recorded, err := recordRevision(release, r.Scheme); err != nil {
+		r.Eventer.Eventf(release, v1.EventTypeWarning, "Error", "record revision error:%v", err)
+	} else if recorded && !rolledBack && conditionTrue(release, internal.CRDReleaseRolledBack) {
+		// New spec applied after rollback.
+		updateCRDReleaseCondition(release, internal.CRDReleaseRolledBack, apiextensions.ConditionFalse)
+	}
 
-	if updated, err := r.updateRelease(log, crdReleasePhase, reason, release); err != nil {
+	updated, err := r.updateRelease(log, crdReleasePhase, reason, release)
+	recordMetrics(release)
+	if err == nil && (rollback || reset) && !updated {
+		// Persist the rollback or recovery reset applied.
+		err = r.Update(ctx, release)
+		updated = true
+	}
+	if err != nil {
 		log.Error(err, "updateRelease error")
 		r.Eventer.Eventf(release, v1.EventTypeWarning, "Error", "updateRelease error:%v", err)
 		// 出现无法控制的故障，requeue也毫无意义
 		return ctrl.Result{}, err
 	} else if updated {
 		return ctrl.Result{}, nil
+	} else if delay, ok := nextCheckDelay(release); ok {
+		return ctrl.Result{Requeue: true, RequeueAfter: delay}, nil
 	} else {
-		return ctrl.Result{Requeue: true, RequeueAfter: 10 * time.Second}, nil
+		return ctrl.Result{}, nil
 	}
 }
 
+//planRelease  write the actions planned to status, it is updated only when the plan changes.
+func (r *CRDReleaseReconciler) planRelease(log logr.Logger, release *clmv1beta1.CRDRelease) error {
+	plan, err := planRelease(release)
+	if err != nil {
+		log.Error(err, "plan crd release error", "name", release.Name)
+		r.Eventer.Eventf(release, v1.EventTypeWarning, "Error", "plan crd release error:%v", err)
+		return err
+	}
+	if release.Status.Plan != nil && release.Status.Plan.Equal(*plan) {
+		log.V(utils.Debug).Info("crd release plan not changed", "name", release.Name)
+		return nil
+	}
+	plan.PlannedAt = metav1.Now()
+	release.Status.Plan = plan
+	r.Eventer.Eventf(release, v1.EventTypeNormal, "Planned", "%d dependencies and %d modules planned",
+		len(plan.Dependencies), len(plan.Modules))
+	return r.Update(context.Background(), release)
+}
+
 // Think twice before turn crd release phase to abnormal
 func abnormalCheck(release clmv1beta1.CRDRelease, err error) bool {
 	if err.Error() == utils.ModuleStateAbnormal {
@@ -141,11 +220,15 @@ func abnormalCheck(release clmv1beta1.CRDRelease, err error) bool {
 			if m.State.Abnormal != nil && m.State.Abnormal.Reason != utils.ImplementNotFound {
 				return true
 			}
+			if m.State.RecoveryExhausted != nil {
+				return true
+			}
 		}
 	}
 	if err.Error() == utils.DependencyStateAbnormal {
 		for _, d := range release.Status.Dependencies {
-			if d.Phase == internal.DependencyAbnormal && d.Reason != utils.ImplementNotFound {
+			if (d.Phase == internal.DependencyAbnormal && d.Reason != utils.ImplementNotFound) ||
+				d.Phase == internal.DependencyUnsatisfiable {
 				return true
 			}
 		}
@@ -156,9 +239,105 @@ func abnormalCheck(release clmv1beta1.CRDRelease, err error) bool {
 func (r *CRDReleaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&clmv1beta1.CRDRelease{}).
+		Watches(&source.Kind{Type: &clmv1beta1.Source{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.sourceToReleases)}).
+		Watches(&source.Kind{Type: &clmv1beta1.CRDRelease{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.dependencyToReleases)},
+			builder.WithPredicates(dependencyChangedPredicate())).
+		Watches(&source.Kind{Type: &v1.Secret{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: r.valuesFromToReleases(internal.ValuesFromSecret)}).
+		Watches(&source.Kind{Type: &v1.ConfigMap{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: r.valuesFromToReleases(internal.ValuesFromConfigMap)}).
 		Complete(r)
 }
 
+//sourceToReleases  return the requests of crd releases whose modules install from the source, the source resolves
+//in the namespace of crd release or is cluster scoped.
+func (r *CRDReleaseReconciler) sourceToReleases(o handler.MapObject) []reconcile.Request {
+	namespace := o.Meta.GetNamespace()
+	return r.mapReleases(func(release clmv1beta1.CRDRelease) bool {
+		if len(namespace) > 0 && namespace != internal.ClusterSourceNamespace && namespace != release.Namespace {
+			return false
+		}
+		for _, m := range release.Spec.Modules {
+			if m.Source.Name == o.Meta.GetName() {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+//valuesFromToReleases  return the requests of crd releases in the same namespace whose modules refer to the secret or
+//config map by valuesFrom, so that the modules upgrade when the data changes.
+func (r *CRDReleaseReconciler) valuesFromToReleases(kind string) handler.ToRequestsFunc {
+	return func(o handler.MapObject) []reconcile.Request {
+		return r.mapReleases(func(release clmv1beta1.CRDRelease) bool {
+			if release.Namespace != o.Meta.GetNamespace() {
+				return false
+			}
+			for _, m := range release.Spec.Modules {
+				for _, ref := range m.Source.ValuesFrom {
+					if ref.Kind == kind && ref.Name == o.Meta.GetName() {
+						return true
+					}
+				}
+			}
+			return false
+		})
+	}
+}
+
+//dependencyToReleases  return the requests of crd releases in the same namespace which depend on the crd release.
+func (r *CRDReleaseReconciler) dependencyToReleases(o handler.MapObject) []reconcile.Request {
+	return r.mapReleases(func(release clmv1beta1.CRDRelease) bool {
+		if release.Namespace != o.Meta.GetNamespace() {
+			return false
+		}
+		for _, d := range release.Spec.Dependencies {
+			if d.Name == o.Meta.GetName() {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (r *CRDReleaseReconciler) mapReleases(match func(clmv1beta1.CRDRelease) bool) []reconcile.Request {
+	releases := &clmv1beta1.CRDReleaseList{}
+	if err := r.List(context.Background(), releases); err != nil {
+		r.Log.Error(err, "unable to fetch crd release list")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, release := range releases.Items {
+		if match(release) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: release.Namespace, Name: release.Name}})
+		}
+	}
+	return requests
+}
+
+//dependencyChangedPredicate  filter the crd release updates which do not matter to the releases depending on it.
+func dependencyChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldRelease, ok := e.ObjectOld.(*clmv1beta1.CRDRelease)
+			if !ok {
+				return false
+			}
+			newRelease, ok := e.ObjectNew.(*clmv1beta1.CRDRelease)
+			if !ok {
+				return false
+			}
+			return oldRelease.Status.Phase != newRelease.Status.Phase ||
+				oldRelease.Status.CurrentVersion != newRelease.Status.CurrentVersion ||
+				(oldRelease.GetDeletionTimestamp() == nil) != (newRelease.GetDeletionTimestamp() == nil)
+		},
+	}
+}
+
 func (r *CRDReleaseReconciler) finalizeRelease(reqLogger logr.Logger, release *clmv1beta1.CRDRelease) (bool, error) {
 	reqLogger.V(utils.Debug).Info("crdRelease finalizer", "name", release.Name)
 	ok, err := UninstallCRDRelease(release)
@@ -175,6 +354,8 @@ func (r *CRDReleaseReconciler) finalizeRelease(reqLogger logr.Logger, release *c
 		reqLogger.Error(err, "failed to update crd release in finalizer", "name", release.Name)
 		return false, err
 	}
+	deleteMetrics(utils.NamespacedKey(release.Namespace, release.Name))
+	internal.DeleteProbeRecords(release.Namespace, release.Name)
 	return true, nil
 }
 
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	clmv1beta1 "cloudnativeapp/clm/api/v1beta1"
+	"cloudnativeapp/clm/internal"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"testing"
+)
+
+func TestValuesFromToReleases(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clmv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	release := func(name, namespace string, refs ...internal.ValuesReference) *clmv1beta1.CRDRelease {
+		return &clmv1beta1.CRDRelease{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: clmv1beta1.CRDReleaseSpec{Modules: []internal.Module{{Name: "m",
+				Source: internal.Source{Name: "s", ValuesFrom: refs}}}}}
+	}
+	r := &CRDReleaseReconciler{Log: ctrl.Log, Client: fake.NewFakeClientWithScheme(scheme,
+		release("web", "default", internal.ValuesReference{Kind: internal.ValuesFromSecret, Name: "db"}),
+		release("api", "default", internal.ValuesReference{Kind: internal.ValuesFromConfigMap, Name: "db"}),
+		release("web", "tenant", internal.ValuesReference{Kind: internal.ValuesFromSecret, Name: "db"}))}
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"}}
+	requests := r.valuesFromToReleases(internal.ValuesFromSecret)(handler.MapObject{Meta: secret, Object: secret})
+	if len(requests) != 1 || requests[0].Namespace != "default" || requests[0].Name != "web" {
+		t.Errorf("the release referring to the secret should be requested, got %v", requests)
+	}
+}
@@ -17,6 +17,8 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"reflect"
+	"strings"
+	"time"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -28,15 +30,15 @@ var EventRecorder record.EventRecorder
 const lastStatus = "clm.cloudnativeapp.io/last-configuration-status"
 const MaxRecordLen = 32 * 1024
 
-// CheckCRDRelease : Check all crd release status.
-func CheckCRDRelease(c *v1beta1.CRDRelease) (bool, error) {
+// CheckCRDRelease : Check all crd release status, modules changed by rollback are upgraded.
+func CheckCRDRelease(c *v1beta1.CRDRelease, rollback bool) (bool, error) {
 	// Check the status of dependencies.
 	if ok, err := checkDependencies(c); err != nil || !ok {
 		return false, err
 	}
 	updateCRDReleaseCondition(c, internal.CRDReleasesDependenciesSatisfied, apiextensions.ConditionTrue)
 	// Check the status of modules.
-	if ok, err := checkModules(c); err != nil || !ok {
+	if ok, err := checkModules(c, rollback); err != nil || !ok {
 		return false, err
 	}
 	// 修改状态
@@ -84,7 +86,7 @@ func checkDependencies(c *v1beta1.CRDRelease) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	ready, err := plugin.CheckPlugins(dependencies, dependencySetStatus(c), dependencyCheckStatus())
+	ready, err := plugin.CheckPlugins(dependencies, dependencySetStatus(c), dependencyCheckStatus(c.Namespace))
 	if !ready {
 		releaseLog.V(utils.Warn).Info("not all dependencies ready", "name", c.Name, "version", c.Spec.Version)
 		updateCRDReleaseCondition(c, internal.CRDReleasesDependenciesSatisfied, apiextensions.ConditionFalse)
@@ -106,7 +108,8 @@ func dependencySetStatus(c *v1beta1.CRDRelease) plugin.StatusSet {
 			EventRecorder.Eventf(c, corev1.EventTypeWarning, "Dependency:"+string(p.Phase), p.Reason)
 		}
 		releaseLog.V(utils.Debug).Info("set phase from check", "phase", p)
-		if p.Phase == internal.DependencyAbnormal || p.Phase == internal.DependencyAbsentErr || p.Phase == internal.DependencyPullingErr {
+		if p.Phase == internal.DependencyAbnormal || p.Phase == internal.DependencyAbsentErr ||
+			p.Phase == internal.DependencyPullingErr || p.Phase == internal.DependencyUnsatisfiable {
 			e = errors.New(utils.DependencyStateAbnormal)
 		}
 		for j, i := range c.Status.Dependencies {
@@ -127,11 +130,11 @@ func dependencySetStatus(c *v1beta1.CRDRelease) plugin.StatusSet {
 	}
 }
 
-//dependencyCheckStatus : return plugin phase.
-func dependencyCheckStatus() plugin.StatusGet {
+//dependencyCheckStatus : return plugin phase, dependencies are crd releases in the same namespace.
+func dependencyCheckStatus(namespace string) plugin.StatusGet {
 	return func(name string, version string) (act plugin.Action, phase string, e error) {
 		release := &v1beta1.CRDRelease{}
-		if err := MGRClient.Get(context.Background(), types.NamespacedName{Name: name, Namespace: ""}, release); err != nil {
+		if err := MGRClient.Get(context.Background(), types.NamespacedName{Name: name, Namespace: namespace}, release); err != nil {
 			err = client.IgnoreNotFound(err)
 			if err == nil {
 				releaseLog.Info("crd release absent", "name", name, "version", version)
@@ -140,18 +143,27 @@ func dependencyCheckStatus() plugin.StatusGet {
 				return plugin.NeedRecover, string(internal.DependencyDontCare), err
 			}
 		}
-		if release.Status.Phase == internal.CRDReleaseRunning && !utils.VersionMatch(release.Status.CurrentVersion, version, "") {
-			releaseLog.Info("crd release version mismatch", "name", name,
-				"current", release.Spec.Version, "expected", version)
-			// upgrade crd release to target version
-			return plugin.NeedUpgrade, string(internal.DependencyDontCare), nil
+		if release.Status.Phase == internal.CRDReleaseRunning {
+			d := internal.Dependency{Name: name, Version: version}
+			ok, upgrade, err := d.CheckVersion(release.Status.CurrentVersion)
+			if err != nil {
+				releaseLog.Info("crd release version unsatisfiable", "name", name,
+					"current", release.Status.CurrentVersion, "expected", version, "reason", err.Error())
+				return plugin.NeedConvert, string(internal.DependencyUnsatisfiable), err
+			}
+			if !ok {
+				releaseLog.Info("crd release version mismatch", "name", name,
+					"current", release.Status.CurrentVersion, "expected", version, "upgrade", upgrade)
+				// upgrade crd release to target version
+				return plugin.NeedUpgrade, string(internal.DependencyDontCare), nil
+			}
 		}
 
 		return plugin.NeedConvert, string(release.Status.Phase), nil
 	}
 }
 
-func getModulesUnion(current []internal.Module, lastModuleMap map[string]internal.Module,
+func getModulesUnion(ctx *internal.ValuesContext, current []internal.Module, lastModuleMap map[string]internal.Module,
 	imported []internal.Module) ([]plugin.Iplugin, error) {
 	releaseLog.V(utils.Debug).Info("get modules union")
 	dmap := make(map[string]internal.Module)
@@ -163,8 +175,14 @@ func getModulesUnion(current []internal.Module, lastModuleMap map[string]interna
 		}
 	}
 
+	excluded := make(map[string]bool)
 	for _, k := range imported {
 		delete(dmap, k.Name)
+		excluded[k.Name] = true
+	}
+	kept := make(map[string]bool)
+	for k := range dmap {
+		kept[k] = true
 	}
 	if len(lastModuleMap) > 0 {
 		for k, v := range lastModuleMap {
@@ -174,17 +192,47 @@ func getModulesUnion(current []internal.Module, lastModuleMap map[string]interna
 		}
 	}
 
-	modules := make([]plugin.Iplugin, len(dmap))
-	k := 0
+	var union, removed []internal.Module
 	for _, j := range dmap {
+		// The external and imported modules are not handled, order the modules without them. The modules removed
+		// are ordered among themselves.
+		var dependsOn []string
+		for _, d := range j.DependsOn {
+			_, ok := dmap[d]
+			if excluded[d] || (kept[j.Name] && ok && !kept[d]) || (!kept[j.Name] && (!ok || kept[d])) {
+				continue
+			}
+			dependsOn = append(dependsOn, d)
+		}
+		j.DependsOn = dependsOn
+		if kept[j.Name] {
+			union = append(union, j)
+		} else {
+			removed = append(removed, j)
+		}
+	}
+	// Check modules in install order, so the modules depended on are handled first.
+	sorted, err := internal.SortModules(union, false)
+	if err != nil {
+		return nil, err
+	}
+	// Then the modules removed in uninstall order, so the dependent modules are uninstalled first.
+	uninstall, err := internal.SortModules(removed, true)
+	if err != nil {
+		return nil, err
+	}
+	sorted = append(sorted, uninstall...)
+	modules := make([]plugin.Iplugin, len(sorted))
+	for k, j := range sorted {
+		j.Source.Namespace = ctx.Release.Namespace
+		j.Source.Context = ctx
 		modules[k] = j
-		k++
 	}
 	return modules, nil
 }
 
 //checkModules Check whether all modules are ready.
-func checkModules(c *v1beta1.CRDRelease) (bool, error) {
+func checkModules(c *v1beta1.CRDRelease, rollback bool) (bool, error) {
 	releaseLog.Info("check modules", "name", c.Name, "version", c.Spec.Version)
 	//第一次进来的时候status都为空，进行一次全量external判断, 同时module需要注意external状态的破坏
 	var modulesExclude []internal.Module
@@ -215,15 +263,18 @@ func checkModules(c *v1beta1.CRDRelease) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	if update {
+	if rollback {
+		// Upgrade the modules rolled back instead of reinstalling them.
+		update = false
+	} else if update {
 		releaseLog.V(utils.Info).Info("crd release updated", "crd release", c.Name, "version", c.Spec.Version)
 	}
 
-	modules, err := getModulesUnion(c.Spec.Modules, mmap, modulesExclude)
+	modules, err := getModulesUnion(valuesContext(c), c.Spec.Modules, mmap, modulesExclude)
 	if err != nil {
 		return false, err
 	}
-	ready, err := plugin.CheckPlugins(modules, moduleSetStatus(c), moduleCheckStatus(c, mmap, update))
+	ready, err := plugin.CheckPlugins(modules, moduleSetStatus(c), moduleCheckStatus(c, mmap, update, false))
 	if !ready {
 		releaseLog.Info("not all modules ready", "name", c.Name, "version", c.Spec.Version)
 		updateCRDReleaseCondition(c, internal.CRDReleaseModulesReady, apiextensions.ConditionFalse)
@@ -250,13 +301,44 @@ func getLastConfigModuleMap(c *v1beta1.CRDRelease) (map[string]internal.Module,
 		if c.Generation > lastCRDRelease.Generation+1 {
 			update = true
 		}
+		// Upgrade the templated modules still configured when the parameters changed, by leaving their last
+		// configuration out.
+		paramsChanged := !reflect.DeepEqual(lastCRDRelease.Spec.Parameters, c.Spec.Parameters)
+		current := make(map[string]bool, len(c.Spec.Modules))
+		for _, i := range c.Spec.Modules {
+			current[i.Name] = true
+		}
 		for _, i := range lastCRDRelease.Spec.Modules {
+			if paramsChanged && current[i.Name] && i.Source.Templated() {
+				releaseLog.V(utils.Info).Info("parameters changed, upgrade templated module",
+					"crd release name", c.Name, "module", i.Name)
+				continue
+			}
 			mmap[i.Name] = i
 		}
 	}
 	return mmap, update, nil
 }
 
+//valuesContext  return the context to render the module values and outputs of the crd release with.
+func valuesContext(c *v1beta1.CRDRelease) *internal.ValuesContext {
+	ctx := &internal.ValuesContext{
+		Release: internal.ReleaseContext{
+			Name:      c.Name,
+			Namespace: c.Namespace,
+			Version:   c.Spec.Version,
+		},
+		Parameters: c.Spec.Parameters,
+		Outputs:    make(map[string]map[string]string),
+	}
+	for _, m := range c.Status.Modules {
+		if len(m.Outputs) > 0 {
+			ctx.Outputs[m.Name] = m.Outputs
+		}
+	}
+	return ctx
+}
+
 func recordModuleState(c *v1beta1.CRDRelease, state *internal.ModuleState, name string) {
 	if state.Abnormal != nil {
 		EventRecorder.Eventf(c, corev1.EventTypeWarning, name+":Abnormal", "message:%v reason:%v",
@@ -266,6 +348,10 @@ func recordModuleState(c *v1beta1.CRDRelease, state *internal.ModuleState, name
 		EventRecorder.Eventf(c, corev1.EventTypeWarning, name+":Recovering", "message:%v reason:%v",
 			state.Recovering.Message, state.Recovering.Reason)
 	}
+	if state.RecoveryExhausted != nil {
+		EventRecorder.Eventf(c, corev1.EventTypeWarning, name+":RecoveryExhausted", "message:%v reason:%v",
+			state.RecoveryExhausted.Message, state.RecoveryExhausted.Reason)
+	}
 	if state.Terminated != nil {
 		EventRecorder.Eventf(c, corev1.EventTypeNormal, name+":Terminated", "message:%v reason:%v",
 			state.Terminated.Message, state.Terminated.Reason)
@@ -289,7 +375,7 @@ func moduleSetStatus(c *v1beta1.CRDRelease) plugin.StatusSet {
 		}
 		releaseLog.V(utils.Debug).Info(fmt.Sprintf("try to set module status %v", s),
 			"crd release name", c.Name, "module", name)
-		if s.State != nil && s.State.Abnormal != nil {
+		if s.State != nil && (s.State.Abnormal != nil || s.State.RecoveryExhausted != nil) {
 			e = errors.New(utils.ModuleStateAbnormal)
 		}
 		for i, j := range c.Status.Modules {
@@ -305,20 +391,26 @@ func moduleSetStatus(c *v1beta1.CRDRelease) plugin.StatusSet {
 		}
 		releaseLog.V(utils.Debug).Info("add module status", "crd release name", name, "module", name)
 		recordModuleState(c, s.State, s.Name)
-		c.Status.Modules = append(c.Status.Modules, status.(internal.ModuleStatus))
+		c.Status.Modules = append(c.Status.Modules, internal.ModuleStatus{}.UpdateStatus(s))
 		return s.Ready || (s.State != nil && s.State.Terminated != nil), e
 	}
 }
 
-//moduleCheckStatus Check the status of module, return the act and phase.
-func moduleCheckStatus(c *v1beta1.CRDRelease, lastModuleMap map[string]internal.Module, crdUpdate bool) plugin.StatusGet {
+//moduleCheckStatus Check the status of module, return the act and phase. A plan skips the probes and recover check.
+func moduleCheckStatus(c *v1beta1.CRDRelease, lastModuleMap map[string]internal.Module, crdUpdate bool,
+	plan bool) plugin.StatusGet {
 	return func(name string, version string) (act plugin.Action, s string, e error) {
-		var lastState *internal.ModuleState
+		var lastStatus internal.ModuleStatus
 		external := false
+		ctx := valuesContext(c)
 		lastApplied := lastModuleMap[name]
+		if len(lastApplied.Name) > 0 {
+			lastApplied.Source.Namespace = c.Namespace
+			lastApplied.Source.Context = ctx
+		}
 		for _, i := range c.Status.Modules {
 			if i.Name == name {
-				lastState = i.State
+				lastStatus = i
 				if i.GetConditionStatus(internal.ModuleExternal) == apiextensions.ConditionTrue {
 					external = true
 					releaseLog.V(utils.Debug).Info("check external module", "crd release name", c.Name,
@@ -330,7 +422,27 @@ func moduleCheckStatus(c *v1beta1.CRDRelease, lastModuleMap map[string]internal.
 			"crd release name", c.Name, "module", name)
 		for _, i := range c.Spec.Modules {
 			if i.Name == name {
-				return i.CheckStatus(lastApplied, lastState, external, crdUpdate, moduleUpdateCondition(c))
+				// Resolve the source and render the values the same as the modules applied.
+				i.Source.Namespace = c.Namespace
+				i.Source.Context = ctx
+				act, phase, err := i.CheckStatus(lastApplied, lastStatus, external, crdUpdate, plan,
+					moduleUpdateCondition(c), moduleUpdateProbe(c))
+				if len(i.DependsOn) == 0 || err != nil {
+					return act, phase, err
+				}
+				if !i.DependsOnReady(c.Status.Modules) {
+					if act == plugin.NeedInstall || act == plugin.NeedUpgrade {
+						releaseLog.V(utils.Info).Info("module waits for dependsOn modules", "crd release name", c.Name,
+							"module", name, "dependsOn", i.DependsOn)
+						return plugin.NeedNothing, internal.DependsOnWaiting, nil
+					}
+					moduleUpdateCondition(c)(internal.ModuleCondition{Type: internal.ModuleDependsOnReady,
+						Status: apiextensions.ConditionFalse, LastTransitionTime: v1.Now()}, name)
+				} else {
+					moduleUpdateCondition(c)(internal.ModuleCondition{Type: internal.ModuleDependsOnReady,
+						Status: apiextensions.ConditionTrue, LastTransitionTime: v1.Now()}, name)
+				}
+				return act, phase, err
 			}
 		}
 		releaseLog.V(utils.Warn).Info("can not find module to check status, delete it", "name", c.Name,
@@ -344,22 +456,23 @@ func moduleUpdateCondition(c *v1beta1.CRDRelease) func(internal.ModuleCondition,
 	return func(m internal.ModuleCondition, name string) {
 		releaseLog.V(utils.Debug).Info(fmt.Sprintf("try to update condition %v", m), "crd release name",
 			c.Name, "module", name)
-		for _, j := range c.Status.Modules {
+		for k, j := range c.Status.Modules {
 			if j.Name == name {
-				for _, i := range j.Conditions {
+				for l, i := range j.Conditions {
 					if i.Type == m.Type {
 						releaseLog.V(utils.Debug).Info(fmt.Sprintf("update module condition from %s to %s", i.Status, m.Status),
 							"crd release name", c.Name, "module", name)
 						if i.Status != m.Status {
-							i.Status = m.Status
-							i.LastTransitionTime = m.LastTransitionTime
+							c.Status.Modules[k].Conditions[l].Status = m.Status
+							c.Status.Modules[k].Conditions[l].LastTransitionTime = m.LastTransitionTime
 							EventRecorder.Eventf(c, corev1.EventTypeNormal, "Module:"+string(m.Type),
 								"module %v condition %v", name, m.Status)
 						}
+						c.Status.Modules[k].Conditions[l].Message = m.Message
 						return
 					}
 				}
-				j.Conditions = append(j.Conditions, m)
+				c.Status.Modules[k].Conditions = append(j.Conditions, m)
 				releaseLog.V(utils.Debug).Info("add module condition",
 					"crd release name", c.Name, "module", name)
 				EventRecorder.Eventf(c, corev1.EventTypeNormal, "Module:"+string(m.Type),
@@ -377,9 +490,62 @@ func moduleUpdateCondition(c *v1beta1.CRDRelease) func(internal.ModuleCondition,
 	}
 }
 
+//moduleUpdateProbe Record the readiness probe results of the module.
+func moduleUpdateProbe(c *v1beta1.CRDRelease) func(internal.ModuleProbeStatus, string) {
+	return func(p internal.ModuleProbeStatus, name string) {
+		releaseLog.V(utils.Debug).Info(fmt.Sprintf("try to update probe status %v", p), "crd release name",
+			c.Name, "module", name)
+		for i, j := range c.Status.Modules {
+			if j.Name == name {
+				c.Status.Modules[i].Probe = &p
+				return
+			}
+		}
+		t := internal.ModuleStatus{}
+		t.Name = name
+		t.Probe = &p
+		c.Status.Modules = append(c.Status.Modules, t)
+	}
+}
+
+//nextCheckDelay  return the delay to check the crd release again, false when only watch events are waited.
+//Sources and dependency releases are watched, the release is checked periodically only when it is not running,
+//has external modules to recheck, or a readiness probe or recover attempt is due.
+func nextCheckDelay(c *v1beta1.CRDRelease) (time.Duration, bool) {
+	delay := CycleDelay * time.Second
+	periodic := c.Status.Phase != internal.CRDReleaseRunning
+	for _, m := range c.Status.Modules {
+		if m.GetConditionStatus(internal.ModuleExternal) == apiextensions.ConditionTrue {
+			periodic = true
+		}
+	}
+	after, ok := internal.NextProbeAfter(c.Namespace, c.Name, c.Spec.Modules, c.Status.Modules)
+	if recoverAfter, found := internal.NextRecoverAfter(c.Spec.Modules, c.Status.Modules); found &&
+		(!ok || recoverAfter < after) {
+		after, ok = recoverAfter, true
+	}
+	if !ok {
+		return delay, periodic
+	}
+	if after < time.Second {
+		after = time.Second
+	}
+	if !periodic || after < delay {
+		delay = after
+	}
+	return delay, true
+}
+
 //UninstallCRDRelease Uninstall the crd release.
 func UninstallCRDRelease(c *v1beta1.CRDRelease) (bool, error) {
-	releaseLog.Info("try to uninstall feature", "name", c.Name, "version", c.Spec.Version)
+	releaseLog.Info("try to uninstall feature", "name", c.Name, "version", c.Spec.Version,
+		"deletionPolicy", c.Spec.DeletionPolicy)
+	if c.Spec.DeletionPolicy == v1beta1.DeletionOrphan {
+		// Leave the modules and the crd releases depending on it as they are.
+		releaseLog.V(utils.Info).Info("orphan the modules of crd release", "name", c.Name)
+		c.Status.CurrentVersion = ""
+		return true, nil
+	}
 	updateCRDReleaseCondition(c, internal.CRDReleaseReady, apiextensions.ConditionFalse)
 	if ok, err := uninstallDependencies(c); err != nil {
 		return false, err
@@ -398,12 +564,13 @@ func UninstallCRDRelease(c *v1beta1.CRDRelease) (bool, error) {
 	return true, nil
 }
 
-//uninstallDependencies Uninstall the dependencies using DAG.
+//uninstallDependencies Uninstall the crd releases depending on it using DAG, refuse to when the deletion policy is
+//Block.
 func uninstallDependencies(c *v1beta1.CRDRelease) (bool, error) {
 	releaseLog.V(utils.Debug).Info("try to uninstall dependencies", "crd release name", c.Name,
 		"version", c.Spec.Version)
 	releases := &v1beta1.CRDReleaseList{}
-	if err := MGRClient.List(context.Background(), releases); err != nil {
+	if err := MGRClient.List(context.Background(), releases, client.InNamespace(c.Namespace)); err != nil {
 		releaseLog.Error(err, "unable to fetch crd release list")
 		return false, err
 	}
@@ -425,6 +592,16 @@ func uninstallDependencies(c *v1beta1.CRDRelease) (bool, error) {
 		return false, err
 	}
 	releaseLog.V(utils.Debug).Info("uninstall sequence", "values", q)
+	if c.Spec.DeletionPolicy == v1beta1.DeletionBlock {
+		if dependents := dependentReleases(q, c.Name); len(dependents) > 0 {
+			err := fmt.Errorf("%s: %v", utils.DeletionBlocked, dependents)
+			releaseLog.V(utils.Warn).Info("crd release deletion blocked", "name", c.Name, "dependents", dependents)
+			updateCRDReleaseCondition(c, internal.CRDReleaseDeletionBlocked, apiextensions.ConditionTrue)
+			c.Status.Reason = err.Error()
+			return false, err
+		}
+		updateCRDReleaseCondition(c, internal.CRDReleaseDeletionBlocked, apiextensions.ConditionFalse)
+	}
 	for _, n := range q {
 		if n == c.Name {
 			// 只需要删除自己, 直接去删除module
@@ -454,12 +631,32 @@ func uninstallDependencies(c *v1beta1.CRDRelease) (bool, error) {
 	return true, nil
 }
 
+//dependentReleases  return the crd releases queued before the release to uninstall, which depend on it.
+func dependentReleases(queue []string, name string) []string {
+	var dependents []string
+	for _, n := range queue {
+		if n == name {
+			break
+		}
+		dependents = append(dependents, n)
+	}
+	return dependents
+}
+
 //uninstallModules Uninstall modules using plugin management.
 func uninstallModules(c *v1beta1.CRDRelease) error {
 	releaseLog.V(utils.Debug).Info("try to uninstall modules", "crd release name", c.Name,
 		"version", c.Spec.Version)
-	modules := make([]plugin.Iplugin, len(c.Spec.Modules))
-	for i, j := range c.Spec.Modules {
+	// Uninstall modules in reverse install order.
+	sorted, err := internal.SortModules(c.Spec.Modules, true)
+	if err != nil {
+		return err
+	}
+	ctx := valuesContext(c)
+	modules := make([]plugin.Iplugin, len(sorted))
+	for i, j := range sorted {
+		j.Source.Namespace = c.Namespace
+		j.Source.Context = ctx
 		modules[i] = j
 	}
 	deleted, err := plugin.CheckPlugins(modules, moduleSetStatus(c), moduleDeleteCheck(c))
@@ -486,6 +683,11 @@ func moduleDeleteCheck(c *v1beta1.CRDRelease) plugin.StatusGet {
 					return plugin.NeedNothing, internal.ModuleRunning, nil
 				}
 				if i.State != nil && i.State.Terminated == nil {
+					if d, ok := moduleDependentAlive(c, name); ok {
+						releaseLog.V(utils.Info).Info("module waits for dependent module uninstalled", "crd release name",
+							c.Name, "module", name, "dependent", d)
+						return plugin.NeedNothing, internal.ModuleDontCare, nil
+					}
 					releaseLog.V(utils.Debug).Info("module check need uninstall", "crd release name",
 						c.Name, "module", name)
 					return plugin.NeedUninstall, internal.ModuleDontCare, nil
@@ -498,6 +700,22 @@ func moduleDeleteCheck(c *v1beta1.CRDRelease) plugin.StatusGet {
 	}
 }
 
+//moduleDependentAlive  return the module which depends on the named module and is not uninstalled yet.
+func moduleDependentAlive(c *v1beta1.CRDRelease, name string) (string, bool) {
+	for _, m := range c.Spec.Modules {
+		if !utils.Contains(m.DependsOn, name) {
+			continue
+		}
+		for _, s := range c.Status.Modules {
+			if s.Name == m.Name && s.State != nil && s.State.Terminated == nil &&
+				s.GetConditionStatus(internal.ModuleExternal) != apiextensions.ConditionTrue {
+				return m.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
 func getDependencies(ds []internal.Dependency) ([]string, error) {
 	var result []string
 	for _, d := range ds {
@@ -537,18 +755,16 @@ func genRecordRelease(release v1beta1.CRDRelease) (v1beta1.CRDRelease, bool) {
 	}
 	releaseLog.V(utils.Debug).Info("last release state", "lastCRDRelease", lastCRDRelease,
 		"crd release name", release.Name, "version", release.Spec.Version)
-	applied := false
-	if len(release.Status.Conditions) != 0 {
-		for _, c := range release.Status.Conditions {
-			if c.Type == internal.CRDReleasesDependenciesSatisfied && c.Status == apiextensions.ConditionTrue {
-				applied = true
-			}
-		}
-	}
-	if !applied {
+	// The parameters are applied with the modules, the templated modules waiting keep the last ones.
+	result.Spec.Parameters = lastCRDRelease.Spec.Parameters
+	if !dependenciesSatisfied(&release) {
 		result.Spec.Modules = lastCRDRelease.Spec.Modules
 	} else {
-		result.Spec.Modules = release.Spec.Modules
+		var waiting bool
+		result.Spec.Modules, waiting = appliedModules(release, lastCRDRelease.Spec.Modules)
+		if !waiting {
+			result.Spec.Parameters = release.Spec.Parameters
+		}
 	}
 
 	// Record them for backup.
@@ -558,7 +774,8 @@ func genRecordRelease(release v1beta1.CRDRelease) (v1beta1.CRDRelease, bool) {
 	result.Spec.Version = release.Spec.Version
 	result.Spec.Dependencies = release.Spec.Dependencies
 
-	if reflect.DeepEqual(lastCRDRelease.Spec.Modules, result.Spec.Modules) {
+	if reflect.DeepEqual(lastCRDRelease.Spec.Modules, result.Spec.Modules) &&
+		reflect.DeepEqual(lastCRDRelease.Spec.Parameters, result.Spec.Parameters) {
 		record = false
 	} else {
 		releaseLog.V(utils.Debug).Info("spec diff from last applied", "last", lastCRDRelease.Spec,
@@ -569,12 +786,42 @@ func genRecordRelease(release v1beta1.CRDRelease) (v1beta1.CRDRelease, bool) {
 	return result, record
 }
 
+//appliedModules  return the modules applied, the ones waiting for dependsOn modules keep the last applied configuration.
+//Return true when any templated module waiting keeps the last one.
+func appliedModules(release v1beta1.CRDRelease, last []internal.Module) ([]internal.Module, bool) {
+	var result []internal.Module
+	templatedWaiting := false
+	for _, m := range release.Spec.Modules {
+		waiting := false
+		for _, s := range release.Status.Modules {
+			if s.Name == m.Name {
+				for _, c := range s.Conditions {
+					if c.Type == internal.ModuleDependsOnReady {
+						waiting = c.Status == apiextensions.ConditionFalse
+					}
+				}
+			}
+		}
+		if !waiting {
+			result = append(result, m)
+			continue
+		}
+		for _, l := range last {
+			if l.Name == m.Name {
+				result = append(result, l)
+				templatedWaiting = templatedWaiting || l.Source.Templated()
+			}
+		}
+	}
+	return result, templatedWaiting
+}
+
 //updateReleaseCheck Check whether spec.module and status changed
 func updateReleaseCheck(release *v1beta1.CRDRelease) (bool, error) {
 	releaseLog.V(utils.Debug).Info("try to compare with record status", "crd release name", release.Name,
 		"version", release.Spec.Version)
 	var statusRecord v1beta1.CRDReleaseStatus
-	tmp, ok := internal.GetStatus(release.Name, release.Spec.Version)
+	tmp, ok := internal.GetStatus(utils.NamespacedKey(release.Namespace, release.Name), release.Spec.Version)
 	if ok {
 		statusRecord = tmp.(v1beta1.CRDReleaseStatus)
 	} else {
@@ -642,3 +889,27 @@ func updateCRDReleaseCondition(release *v1beta1.CRDRelease, conditionType intern
 			LastTransitionTime: v1.Now()})
 	return
 }
+
+//resetRecovery  renew the recover budget of the modules named in the reset annotation, * for all modules.
+//The annotation is removed after that, return true when the crd release changed.
+func resetRecovery(c *v1beta1.CRDRelease) bool {
+	value, ok := c.Annotations[v1beta1.ResetRecoveryAnnotation]
+	if !ok {
+		return false
+	}
+	names := make(map[string]bool)
+	for _, n := range strings.Split(value, ",") {
+		names[strings.TrimSpace(n)] = true
+	}
+	for i, m := range c.Status.Modules {
+		if names["*"] || names[m.Name] {
+			releaseLog.V(utils.Info).Info("reset module recovery", "name", c.Name, "module", m.Name,
+				"attempts", m.RecoverAttempts)
+			c.Status.Modules[i].ResetRecovery()
+			EventRecorder.Eventf(c, corev1.EventTypeNormal, m.Name+":RecoveryReset", "recover attempts %d reset",
+				m.RecoverAttempts)
+		}
+	}
+	delete(c.Annotations, v1beta1.ResetRecoveryAnnotation)
+	return true
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"cloudnativeapp/clm/api/v1beta1"
+	"cloudnativeapp/clm/internal"
+	"encoding/json"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"reflect"
+	"testing"
+)
+
+func TestGetModulesUnion(t *testing.T) {
+	ctx := &internal.ValuesContext{Release: internal.ReleaseContext{Name: "app", Namespace: "default"}}
+	current := []internal.Module{
+		{Name: "web", DependsOn: []string{"db", "cache"}},
+		{Name: "cache", DependsOn: []string{"db"}},
+		{Name: "db"},
+	}
+	// The module imported is not handled by the release, the modules depending on it are still ordered.
+	imported := []internal.Module{{Name: "db"}}
+	modules, err := getModulesUnion(ctx, current, nil, imported)
+	if err != nil {
+		t.Fatalf("get modules union error: %v", err)
+	}
+	var names []string
+	for _, m := range modules {
+		names = append(names, m.(internal.Module).Name)
+	}
+	if len(names) != 2 || names[0] != "cache" || names[1] != "web" {
+		t.Errorf("expected [cache web], got %v", names)
+	}
+	if m := modules[0].(internal.Module); m.Source.Namespace != "default" {
+		t.Errorf("unexpected source namespace %s", m.Source.Namespace)
+	}
+
+	// The modules removed are uninstalled after the others, the dependent modules first.
+	last := map[string]internal.Module{
+		"web":   {Name: "web", DependsOn: []string{"db", "cache"}},
+		"cache": {Name: "cache", DependsOn: []string{"db"}},
+		"queue": {Name: "queue", DependsOn: []string{"web"}},
+		"log":   {Name: "log"},
+		"agent": {Name: "agent", DependsOn: []string{"queue", "log"}},
+	}
+	if modules, err = getModulesUnion(ctx, current, last, imported); err != nil {
+		t.Fatalf("get modules union error: %v", err)
+	}
+	names = nil
+	for _, m := range modules {
+		names = append(names, m.(internal.Module).Name)
+	}
+	if len(names) != 5 || names[0] != "cache" || names[1] != "web" || names[2] != "agent" ||
+		(names[3] != "queue" && names[3] != "log") {
+		t.Errorf("expected [cache web agent queue log], got %v", names)
+	}
+
+	// The modules absent are still reported.
+	current = append(current, internal.Module{Name: "api", DependsOn: []string{"absent"}})
+	if _, err := getModulesUnion(ctx, current, nil, imported); err == nil {
+		t.Error("dependency on absent module not reported")
+	}
+}
+
+func TestGenRecordReleaseParameters(t *testing.T) {
+	c := v1beta1.CRDRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Annotations: map[string]string{}},
+		Spec: v1beta1.CRDReleaseSpec{
+			Parameters: &runtime.RawExtension{Raw: []byte(`{"replicas":2}`)},
+			Modules: []internal.Module{{Name: "web", Source: internal.Source{Name: "s",
+				Values: &runtime.RawExtension{Raw: []byte(`{"replicas":"{{ .Parameters.replicas }}"}`)}}}},
+		},
+		Status: v1beta1.CRDReleaseStatus{Conditions: []internal.CRDReleaseCondition{
+			{Type: internal.CRDReleasesDependenciesSatisfied, Status: apiextensions.ConditionTrue}}},
+	}
+	target, record := genRecordRelease(c)
+	if !record || !reflect.DeepEqual(target.Spec.Parameters, c.Spec.Parameters) {
+		t.Fatalf("parameters should be recorded, got %v %v", record, target.Spec.Parameters)
+	}
+	bytes, _ := json.Marshal(target)
+	c.Annotations[lastStatus] = string(bytes)
+	if _, record := genRecordRelease(c); record {
+		t.Errorf("parameters recorded should not be recorded again")
+	}
+	if mmap, _, _ := getLastConfigModuleMap(&c); len(mmap) != 1 {
+		t.Errorf("templated module should keep the last configuration when parameters unchanged, got %v", mmap)
+	}
+
+	c.Spec.Parameters = &runtime.RawExtension{Raw: []byte(`{"replicas":3}`)}
+	if mmap, _, _ := getLastConfigModuleMap(&c); len(mmap) != 0 {
+		t.Errorf("templated module should upgrade when parameters changed, got %v", mmap)
+	}
+	if target, record := genRecordRelease(c); !record || !reflect.DeepEqual(target.Spec.Parameters, c.Spec.Parameters) {
+		t.Errorf("parameters changed should be recorded, got %v %v", record, target.Spec.Parameters)
+	}
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"cloudnativeapp/clm/api/v1beta1"
+	"cloudnativeapp/clm/internal"
+	"cloudnativeapp/clm/pkg/metrics"
+	"cloudnativeapp/clm/pkg/utils"
+	"sync"
+)
+
+var releasePhases = []string{string(internal.CRDReleaseRunning), string(internal.CRDReleaseInstalling),
+	string(internal.CRDReleaseAbnormal)}
+
+var moduleStates = []string{internal.ModuleInstalling, internal.ModuleRunning, internal.ModuleRecovering,
+	internal.ModuleAbnormal, internal.ModuleRecoveryExhausted, internal.ModuleTerminated}
+
+// Modules recorded in metrics of each crd release, the metrics of modules removed from status are deleted.
+var metricModules = struct {
+	m map[string][]string
+	sync.Mutex
+}{
+	m: make(map[string][]string),
+}
+
+//recordMetrics  record the crd release phase and module states to metrics.
+//The release label is namespace/name for the namespaced crd release.
+func recordMetrics(c *v1beta1.CRDRelease) {
+	release := utils.NamespacedKey(c.Namespace, c.Name)
+	metrics.SetReleasePhase(release, string(c.Status.Phase), releasePhases)
+	current := make(map[string]bool)
+	for _, m := range c.Status.Modules {
+		metrics.SetModuleState(release, m.Name, m.State.Phase(), moduleStates, m.RecoverCount)
+		current[m.Name] = true
+	}
+	metricModules.Lock()
+	defer metricModules.Unlock()
+	for _, m := range metricModules.m[release] {
+		if !current[m] {
+			metrics.DeleteModule(release, m, moduleStates)
+		}
+	}
+	names := make([]string, 0, len(current))
+	for m := range current {
+		names = append(names, m)
+	}
+	metricModules.m[release] = names
+}
+
+//deleteMetrics  delete the metrics of the crd release deleted by release label.
+func deleteMetrics(name string) {
+	metrics.DeleteRelease(name, releasePhases)
+	metricModules.Lock()
+	defer metricModules.Unlock()
+	for _, m := range metricModules.m[name] {
+		metrics.DeleteModule(name, m, moduleStates)
+	}
+	delete(metricModules.m, name)
+}
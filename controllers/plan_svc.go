@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"cloudnativeapp/clm/api/v1beta1"
+	"cloudnativeapp/clm/internal"
+	"cloudnativeapp/clm/pkg/check/condition"
+	"cloudnativeapp/clm/pkg/plugin"
+	"cloudnativeapp/clm/pkg/utils"
+	"reflect"
+	"sort"
+)
+
+//isDryRun  return true when the crd release should be planned instead of applied.
+func isDryRun(c *v1beta1.CRDRelease) bool {
+	return c.Spec.DryRun || c.Annotations[v1beta1.DryRunAnnotation] == "true"
+}
+
+//planRelease  check the dependencies and modules as a reconcile does and return the actions it would take,
+//no source implement is called and the crd release is not changed.
+func planRelease(release *v1beta1.CRDRelease) (*internal.ReleasePlan, error) {
+	releaseLog.V(utils.Info).Info("plan crd release", "name", release.Name, "version", release.Spec.Version)
+	c := release.DeepCopy()
+	plan := &internal.ReleasePlan{}
+	dependencies, err := getActivedDependencies(c)
+	if err != nil {
+		return nil, err
+	}
+	getDependency := dependencyCheckStatus(c.Namespace)
+	for _, d := range dependencies {
+		name, version := d.Attributes()
+		act, phase, err := getDependency(name, version)
+		plan.Dependencies = append(plan.Dependencies, planAction(name, version, act, phase, err))
+	}
+	sort.Slice(plan.Dependencies, func(i, j int) bool {
+		return plan.Dependencies[i].Name < plan.Dependencies[j].Name
+	})
+
+	var modulesExclude []internal.Module
+	if len(c.Status.Modules) == 0 {
+		for _, m := range c.Spec.Modules {
+			ok, err := m.ConditionCheck()
+			if err != nil {
+				return nil, err
+			} else if ok {
+				continue
+			}
+			phase := string(internal.ModuleExternal)
+			if !reflect.DeepEqual(m.Conditions, condition.Condition{}) && m.Conditions.Strategy == condition.Import {
+				phase = string(internal.ModuleImported)
+			}
+			plan.Modules = append(plan.Modules, planAction(m.Name, "", plugin.NeedNothing, phase, nil))
+			modulesExclude = append(modulesExclude, m)
+		}
+	}
+	mmap, update, err := getLastConfigModuleMap(c)
+	if err != nil {
+		return nil, err
+	}
+	modules, err := getModulesUnion(valuesContext(c), c.Spec.Modules, mmap, modulesExclude)
+	if err != nil {
+		return nil, err
+	}
+	getModule := moduleCheckStatus(c, mmap, update, true)
+	for _, p := range modules {
+		name, _ := p.Attributes()
+		act, phase, err := getModule(name, "")
+		a := planAction(name, "", act, phase, err)
+		if m, ok := p.(internal.Module); ok && err == nil {
+			if a.Diff, err = m.Plan(act); err != nil {
+				a.Reason = err.Error()
+			}
+		}
+		plan.Modules = append(plan.Modules, a)
+	}
+	return plan, nil
+}
+
+func planAction(name, version string, act plugin.Action, phase string, err error) internal.PlanAction {
+	a := internal.PlanAction{Name: name, Version: version, Action: act}
+	if len(act) == 0 {
+		a.Action = plugin.NeedNothing
+	}
+	if a.Action == plugin.NeedConvert || a.Action == plugin.NeedNothing {
+		a.Phase = phase
+	}
+	if err != nil {
+		a.Reason = err.Error()
+	}
+	return a
+}
@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"cloudnativeapp/clm/api/v1beta1"
+	"cloudnativeapp/clm/internal"
+	"cloudnativeapp/clm/pkg/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sort"
+	"time"
+)
+
+// Old revisions retained when spec.revisionHistoryLimit omitted.
+const DefaultRevisionHistoryLimit = 10
+
+// Seconds for a module to turn running after upgrade when spec.upgradePolicy.progressDeadlineSeconds omitted.
+const DefaultProgressDeadlineSeconds = 600
+
+//revisionSpec  return the crd release spec to be recorded in revision.
+func revisionSpec(c *v1beta1.CRDRelease) v1beta1.CRDReleaseSpec {
+	spec := *c.Spec.DeepCopy()
+	spec.RevisionHistoryLimit = nil
+	spec.RollbackTo = nil
+	spec.UpgradePolicy = nil
+	return spec
+}
+
+//listRevisions  list the revisions of crd release in its namespace sorted by revision.
+func listRevisions(namespace, name string) ([]v1beta1.CRDReleaseRevision, error) {
+	list := &v1beta1.CRDReleaseRevisionList{}
+	if err := MGRClient.List(context.Background(), list, client.InNamespace(namespace),
+		client.MatchingLabels{v1beta1.RevisionReleaseLabel: name}); err != nil {
+		releaseLog.Error(err, "unable to fetch crd release revision list", "crd release name", name)
+		return nil, err
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Revision < list.Items[j].Revision
+	})
+	return list.Items, nil
+}
+
+//specEqual  compare the specs by serialization, the ones read from api server differ in empty values.
+func specEqual(a, b v1beta1.CRDReleaseSpec) bool {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(x) == string(y)
+}
+
+//recordRevision  record the crd release spec applied as a new revision when it differs from the latest revision.
+//Return true when a new revision recorded.
+func recordRevision(c *v1beta1.CRDRelease, scheme *runtime.Scheme) (bool, error) {
+	if !dependenciesSatisfied(c) {
+		// Spec is not applied before dependencies satisfied.
+		return false, nil
+	}
+	revisions, err := listRevisions(c.Namespace, c.Name)
+	if err != nil {
+		return false, err
+	}
+	spec := revisionSpec(c)
+	var latest int64
+	if n := len(revisions); n > 0 {
+		latest = revisions[n-1].Revision
+		if specEqual(revisions[n-1].Spec, spec) {
+			c.Status.Revision = latest
+			return false, nil
+		}
+	}
+
+	revision := &v1beta1.CRDReleaseRevision{
+		Spec:     spec,
+		Revision: latest + 1,
+	}
+	revision.Name = fmt.Sprintf("%s-%d", c.Name, revision.Revision)
+	revision.Namespace = c.Namespace
+	revision.Labels = map[string]string{v1beta1.RevisionReleaseLabel: c.Name}
+	if err := controllerutil.SetControllerReference(c, revision, scheme); err != nil {
+		return false, err
+	}
+	if err := MGRClient.Create(context.Background(), revision); err != nil {
+		releaseLog.Error(err, "create crd release revision failed", "crd release name", c.Name,
+			"revision", revision.Revision)
+		return false, err
+	}
+	releaseLog.V(utils.Info).Info("crd release revision recorded", "crd release name", c.Name,
+		"revision", revision.Revision)
+	EventRecorder.Eventf(c, corev1.EventTypeNormal, "RevisionRecorded", "revision %d recorded", revision.Revision)
+	c.Status.Revision = revision.Revision
+	return true, cleanupRevisions(c, revisions)
+}
+
+//cleanupRevisions  delete the oldest revisions beyond the history limit, the current revision is not included.
+func cleanupRevisions(c *v1beta1.CRDRelease, old []v1beta1.CRDReleaseRevision) error {
+	limit := DefaultRevisionHistoryLimit
+	if c.Spec.RevisionHistoryLimit != nil {
+		limit = int(*c.Spec.RevisionHistoryLimit)
+	}
+	for i := 0; i < len(old)-limit; i++ {
+		releaseLog.V(utils.Debug).Info("delete crd release revision", "crd release name", c.Name,
+			"revision", old[i].Revision)
+		if err := MGRClient.Delete(context.Background(), &old[i]); client.IgnoreNotFound(err) != nil {
+			releaseLog.Error(err, "delete crd release revision failed", "crd release name", c.Name,
+				"revision", old[i].Revision)
+			return err
+		}
+	}
+	return nil
+}
+
+//rollbackRelease  apply the spec of revision to rollback to, the changed modules are upgraded by the following check.
+//Return false when the revision not found.
+func rollbackRelease(c *v1beta1.CRDRelease) (bool, error) {
+	target := c.Spec.RollbackTo.Revision
+	revisions, err := listRevisions(c.Namespace, c.Name)
+	if err != nil {
+		return false, err
+	}
+	c.Spec.RollbackTo = nil
+	if target == 0 {
+		// Rollback to the last revision.
+		for _, r := range revisions {
+			if r.Revision < c.Status.Revision {
+				target = r.Revision
+			}
+		}
+	}
+	for _, r := range revisions {
+		if r.Revision == target && target != 0 {
+			releaseLog.V(utils.Info).Info("rollback crd release", "crd release name", c.Name,
+				"revision", target)
+			c.Spec.Version = r.Spec.Version
+			c.Spec.Dependencies = r.Spec.Dependencies
+			c.Spec.Parameters = r.Spec.Parameters
+			c.Spec.Modules = r.Spec.Modules
+			EventRecorder.Eventf(c, corev1.EventTypeNormal, "Rollback", "rollback to revision %d", target)
+			return true, nil
+		}
+	}
+	releaseLog.V(utils.Warn).Info("rollback revision not found", "crd release name", c.Name, "revision", target)
+	EventRecorder.Eventf(c, corev1.EventTypeWarning, "RollbackRevisionNotFound",
+		"unable to find revision %d to rollback", target)
+	return false, nil
+}
+
+//autoRollback  rollback the modules which do not turn running within the deadline after upgrade to the configuration
+//of the previous revision. Return true when any module rolled back.
+func autoRollback(c *v1beta1.CRDRelease) (bool, error) {
+	policy := c.Spec.UpgradePolicy
+	if policy == nil || !policy.AutoRollback {
+		return false, nil
+	}
+	deadline := DefaultProgressDeadlineSeconds * time.Second
+	if policy.ProgressDeadlineSeconds != nil {
+		deadline = time.Duration(*policy.ProgressDeadlineSeconds) * time.Second
+	}
+	var revisions []v1beta1.CRDReleaseRevision
+	rolledBack := false
+	for i, m := range c.Spec.Modules {
+		k := -1
+		for j, s := range c.Status.Modules {
+			if s.Name == m.Name && s.UpgradeStartedAt != nil && time.Since(s.UpgradeStartedAt.Time) > deadline {
+				k = j
+			}
+		}
+		if k < 0 {
+			continue
+		}
+		if revisions == nil {
+			var err error
+			if revisions, err = listRevisions(c.Namespace, c.Name); err != nil {
+				return rolledBack, err
+			}
+		}
+		// Roll back only once for an upgrade.
+		c.Status.Modules[k].UpgradeStartedAt = nil
+		last, ok := previousModule(revisions, c.Status.Revision, m)
+		if !ok {
+			releaseLog.V(utils.Warn).Info("no previous configuration to rollback", "crd release name", c.Name,
+				"module", m.Name)
+			EventRecorder.Eventf(c, corev1.EventTypeWarning, "RollbackRevisionNotFound",
+				"no previous configuration of module %s to rollback", m.Name)
+			continue
+		}
+		releaseLog.V(utils.Info).Info("module upgrade deadline exceeded, rollback", "crd release name", c.Name,
+			"module", m.Name)
+		rollback := last
+		rollback.Source.Namespace = c.Namespace
+		rollback.Source.Context = valuesContext(c)
+		status, err := rollback.DoRollback()
+		moduleSetStatus(c)(m.Name, "", status)
+		if err != nil {
+			EventRecorder.Eventf(c, corev1.EventTypeWarning, "Error", "rollback module %s error:%v", m.Name, err)
+			continue
+		}
+		c.Spec.Modules[i] = last
+		rolledBack = true
+		EventRecorder.Eventf(c, corev1.EventTypeWarning, "RolledBack",
+			"module %s not running %v after upgrade, rolled back", m.Name, deadline)
+	}
+	if rolledBack {
+		updateCRDReleaseCondition(c, internal.CRDReleaseRolledBack, apiextensions.ConditionTrue)
+	}
+	return rolledBack, nil
+}
+
+//previousModule  return the latest module configuration before the revision which differs from the current one.
+func previousModule(revisions []v1beta1.CRDReleaseRevision, revision int64, m internal.Module) (internal.Module, bool) {
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if revisions[i].Revision >= revision {
+			continue
+		}
+		for _, l := range revisions[i].Spec.Modules {
+			if l.Name == m.Name && !specEqual(v1beta1.CRDReleaseSpec{Modules: []internal.Module{l}},
+				v1beta1.CRDReleaseSpec{Modules: []internal.Module{m}}) {
+				return l, true
+			}
+		}
+	}
+	return internal.Module{}, false
+}
+
+//dependenciesSatisfied  check whether the crd release spec is applied after dependencies satisfied.
+func dependenciesSatisfied(c *v1beta1.CRDRelease) bool {
+	return conditionTrue(c, internal.CRDReleasesDependenciesSatisfied)
+}
+
+//conditionTrue  return true when the crd release condition exists and is true.
+func conditionTrue(c *v1beta1.CRDRelease, conditionType internal.CRDReleaseConditionType) bool {
+	for _, i := range c.Status.Conditions {
+		if i.Type == conditionType && i.Status == apiextensions.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
@@ -19,11 +19,17 @@ package controllers
 import (
 	"cloudnativeapp/clm/internal"
 	"cloudnativeapp/clm/pkg/helmsdk"
+	"cloudnativeapp/clm/pkg/implement"
+	"cloudnativeapp/clm/pkg/implement/helm"
 	"cloudnativeapp/clm/pkg/utils"
 	"context"
+	"fmt"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -45,6 +51,7 @@ const sourceFinalizer = "finalizer.clm.cloudnativeapp.io"
 
 // +kubebuilder:rbac:groups=clm.cloudnativeapp.io,resources=sources,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=clm.cloudnativeapp.io,resources=sources/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 
 func (r *SourceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -73,7 +80,29 @@ func (r *SourceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		}
 	}
 
-	if ok := internal.AddSource(source.Name, source.Spec.Implement); !ok {
+	// Load the keyring to verify the helm charts with, the source is registered with its key and the keyring secret
+	// namespace resolved.
+	key := internal.SourceKey(source.Namespace, source.Name)
+	impl := source.Spec.Implement
+	if source.Spec.Type == implement.HelmType && source.Spec.Implement.Helm != nil {
+		impl = *source.Spec.Implement.DeepCopy()
+		impl.Helm.SourceKey = key
+		if impl.Helm.Verify != nil {
+			ref := &impl.Helm.Verify.KeyringSecretRef
+			data, err := r.secretData(source, *ref)
+			if err == nil {
+				ref.Namespace = secretNamespace(source, *ref)
+				err = helmsdk.WriteKeyring(ref.Namespace, ref.Name, data[helm.KeyringKey])
+			}
+			if err != nil {
+				log.Error(err, "load helm keyring failed", "secret", ref.Name)
+				r.Eventer.Eventf(source, v1.EventTypeWarning, "helm keyring load failed", "error:%v", err)
+				return reconcile.Result{}, err
+			}
+		}
+	}
+
+	if ok := internal.AddSource(key, impl); !ok {
 		log.V(utils.Info).Info("source updated", "name", source.Name)
 		// ignore add error
 		//return ctrl.Result{}, nil
@@ -84,9 +113,15 @@ func (r *SourceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	}
 
 	// Add repo for helm source
-	if source.Spec.Type == "helm" && source.Spec.Implement.Helm != nil && source.Spec.Implement.Helm.Repositories != nil {
+	if source.Spec.Type == implement.HelmType && source.Spec.Implement.Helm != nil && source.Spec.Implement.Helm.Repositories != nil {
 		for _, repo := range source.Spec.Implement.Helm.Repositories {
-			err := helmsdk.Add(repo.Name, repo.Url, repo.UserName, repo.PassWord, log)
+			opts, err := r.repoOptions(source, repo)
+			if err != nil {
+				log.Error(err, "get helm repo credentials failed", "repo", repo.Name)
+				r.Eventer.Eventf(source, v1.EventTypeWarning, "helm repo add failed", "error:%v", err)
+				return reconcile.Result{}, err
+			}
+			err = helmsdk.Add(repo.Name, repo.Url, key, opts, log)
 			if err != nil {
 				log.Error(err, "helm repo add failed")
 				r.Eventer.Eventf(source, v1.EventTypeWarning, "helm repo add failed", "error:%v", err)
@@ -95,6 +130,29 @@ func (r *SourceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		}
 	}
 
+	// Login registries for helm source
+	if source.Spec.Type == implement.HelmType && source.Spec.Implement.Helm != nil {
+		for _, registry := range source.Spec.Implement.Helm.Registries {
+			if registry.SecretRef == nil {
+				// Accessed anonymously, the credentials saved before are dropped.
+				if err := helmsdk.Logout(key, registry.Host); err != nil {
+					log.Error(err, "helm registry logout failed", "registry", registry.Host)
+					return reconcile.Result{}, err
+				}
+				continue
+			}
+			opts, err := r.registryOptions(source, registry)
+			if err == nil {
+				err = helmsdk.Login(key, registry.Host, opts, log)
+			}
+			if err != nil {
+				log.Error(err, "helm registry login failed", "registry", registry.Host)
+				r.Eventer.Eventf(source, v1.EventTypeWarning, "helm registry login failed", "error:%v", err)
+				return reconcile.Result{}, err
+			}
+		}
+	}
+
 	if !source.Status.Ready {
 		source.Status.Ready = true
 		if err := r.Update(ctx, source); err != nil {
@@ -109,15 +167,105 @@ func (r *SourceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 func (r *SourceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&clmv1beta1.Source{}).
+		Watches(&source.Kind{Type: &v1.Secret{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.secretToSources)}).
 		Complete(r)
 }
 
+//repoOptions  return the options to add the helm repository with, the credentials read from the secret referred.
+func (r *SourceReconciler) repoOptions(s *clmv1beta1.Source, repo helm.Repo) (helmsdk.RepoOptions, error) {
+	if repo.SecretRef == nil {
+		return repo.Options(nil), nil
+	}
+	data, err := r.secretData(s, *repo.SecretRef)
+	if err != nil {
+		return helmsdk.RepoOptions{}, err
+	}
+	return repo.Options(data), nil
+}
+
+//registryOptions  return the options to login the helm registry with, the credentials read from the secret referred.
+func (r *SourceReconciler) registryOptions(s *clmv1beta1.Source, registry helm.Registry) (helmsdk.RegistryOptions,
+	error) {
+	data, err := r.secretData(s, *registry.SecretRef)
+	if err != nil {
+		return helmsdk.RegistryOptions{}, err
+	}
+	return registry.Options(data)
+}
+
+//secretData  return the data of the secret referred by the source.
+func (r *SourceReconciler) secretData(s *clmv1beta1.Source, ref helm.SecretReference) (map[string][]byte, error) {
+	namespace := secretNamespace(s, ref)
+	if len(namespace) == 0 {
+		return nil, fmt.Errorf("namespace of secret %s needed by cluster scoped source", ref.Name)
+	}
+	secret := &v1.Secret{}
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: ref.Name},
+		secret); err != nil {
+		return nil, err
+	}
+	return secret.Data, nil
+}
+
+//secretNamespace  return the namespace of the secret referred by the source. Only the cluster scoped sources refer to
+//the secrets in other namespaces, the namespaced sources refer to those in their own namespace.
+func secretNamespace(s *clmv1beta1.Source, ref helm.SecretReference) string {
+	if len(s.Namespace) > 0 {
+		return s.Namespace
+	}
+	return ref.Namespace
+}
+
+//secretToSources  return the requests of helm sources whose repositories, registries or keyring refer to the secret.
+func (r *SourceReconciler) secretToSources(o handler.MapObject) []reconcile.Request {
+	sources := &clmv1beta1.SourceList{}
+	if err := r.List(context.Background(), sources); err != nil {
+		r.Log.Error(err, "unable to fetch source list")
+		return nil
+	}
+	var requests []reconcile.Request
+	for i, s := range sources.Items {
+		if s.Spec.Implement.Helm == nil {
+			continue
+		}
+		var refs []*helm.SecretReference
+		for _, repo := range s.Spec.Implement.Helm.Repositories {
+			refs = append(refs, repo.SecretRef)
+		}
+		for _, registry := range s.Spec.Implement.Helm.Registries {
+			refs = append(refs, registry.SecretRef)
+		}
+		if s.Spec.Implement.Helm.Verify != nil {
+			refs = append(refs, &s.Spec.Implement.Helm.Verify.KeyringSecretRef)
+		}
+		for _, ref := range refs {
+			if ref != nil && ref.Name == o.Meta.GetName() &&
+				secretNamespace(&sources.Items[i], *ref) == o.Meta.GetNamespace() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: s.Namespace, Name: s.Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
 func (r *SourceReconciler) finalizeSource(reqLogger logr.Logger, instance *clmv1beta1.Source) error {
 	reqLogger.V(utils.Debug).Info("source finalizer", "name", instance.Name)
-	ok := internal.DeleteSource(instance.Name)
+	ok := internal.DeleteSource(internal.SourceKey(instance.Namespace, instance.Name))
 	if !ok {
 		reqLogger.V(utils.Warn).Info("source delete failed")
 	}
+	key := internal.SourceKey(instance.Namespace, instance.Name)
+	if err := helmsdk.RemoveRepos(key, reqLogger); err != nil {
+		reqLogger.Error(err, "helm repo remove failed", "name", instance.Name)
+		return err
+	}
+	if err := helmsdk.RemoveRegistries(key); err != nil {
+		reqLogger.Error(err, "helm registry credentials remove failed", "name", instance.Name)
+		return err
+	}
 	instance.SetFinalizers(utils.Remove(instance.GetFinalizers(), sourceFinalizer))
 	if err := r.Update(context.TODO(), instance); err != nil {
 		reqLogger.Error(err, "failed to update ecs in finalizer", "name", instance.Name)
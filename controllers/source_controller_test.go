@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	clmv1beta1 "cloudnativeapp/clm/api/v1beta1"
+	"cloudnativeapp/clm/pkg/implement/helm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestSecretNamespace(t *testing.T) {
+	ref := helm.SecretReference{Name: "creds", Namespace: "tenant-a"}
+	if ns := secretNamespace(&clmv1beta1.Source{}, ref); ns != "tenant-a" {
+		t.Errorf("cluster scoped source should refer to the namespace set, got %s", ns)
+	}
+	s := &clmv1beta1.Source{ObjectMeta: metav1.ObjectMeta{Name: "charts", Namespace: "tenant-b"}}
+	if ns := secretNamespace(s, ref); ns != "tenant-b" {
+		t.Errorf("namespaced source should refer to its own namespace, got %s", ns)
+	}
+}
@@ -36,6 +36,10 @@ const (
 	CRDReleaseModulesReady CRDReleaseConditionType = "ModulesReady"
 	// It means crd release is ready to work now.
 	CRDReleaseReady CRDReleaseConditionType = "Ready"
+	// Modules failed to turn running after upgrade are rolled back to the previous configuration.
+	CRDReleaseRolledBack CRDReleaseConditionType = "RolledBack"
+	// The deletion is blocked by the crd releases depending on it.
+	CRDReleaseDeletionBlocked CRDReleaseConditionType = "DeletionBlocked"
 )
 
 var log = ctrl.Log.WithName("crd release status")
@@ -3,12 +3,19 @@ package internal
 import (
 	"cloudnativeapp/clm/pkg/utils"
 	"errors"
+	"fmt"
+	"github.com/Masterminds/semver/v3"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"reflect"
+	"regexp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"strings"
 )
 
 type Dependency struct {
-	Name    string `json:"name"`
+	Name string `json:"name"`
+	// Semver constraint of the dependency version, e.g. `>=1.2.0 <2.0.0`, `^1.4`, `~2.1`. A plain version means
+	// the minimum version. Pre-release versions match only constraints with a pre-release, e.g. `>=1.2.0-0`.
 	Version string `json:"version"`
 	// Strategy when dependency not found in cluster.
 	Strategy DependencyStrategy `json:"strategy,omitempty"`
@@ -16,11 +23,6 @@ type Dependency struct {
 	Registry Registry `json:"registry,omitempty"`
 }
 
-//type Version struct {
-//	Min		string `json:"min,omitempty"`
-//	Max 	string `json:"max,omitempty"`
-//}
-
 type DependencyStatus struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
@@ -55,12 +57,44 @@ const (
 	DependencyRunning DependencyPhase = "Running"
 	// Dependency phase abnormal.
 	DependencyAbnormal DependencyPhase = "Abnormal"
+	// Dependency version installed does not satisfy the constraint and can not be upgraded to satisfy it.
+	DependencyUnsatisfiable DependencyPhase = "Unsatisfiable"
 	// Do not care
 	DependencyDontCare DependencyPhase = "DependencyDontCare"
 )
 
 var dLog = ctrl.Log.WithName("dependency")
 
+// Versions with an operator allowing them as the lowest version of a constraint.
+var constraintVersionRegex = regexp.MustCompile(`(\^|~>|~|>=|<=|!=|>|<|=)?\s*v?([0-9xX*]+(\.[0-9xX*]+)*` +
+	`(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?)`)
+
+//Validate  check the dependency strategy and registry.
+func (d Dependency) Validate(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(d.Name) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("name"), ""))
+	}
+	if _, err := d.constraint(); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("version"), d.Version, err.Error()))
+	}
+	switch d.Strategy {
+	case "", PullIfAbsent, WaitIfAbsent, ErrorIfAbsent:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("strategy"), d.Strategy,
+			[]string{string(PullIfAbsent), string(WaitIfAbsent), string(ErrorIfAbsent)}))
+	}
+	if reflect.DeepEqual(d.Registry, Registry{}) {
+		if d.Strategy == PullIfAbsent {
+			allErrs = append(allErrs, field.Required(fldPath.Child("registry"),
+				"registry needed when strategy is "+string(PullIfAbsent)))
+		}
+	} else {
+		allErrs = append(allErrs, d.Registry.Validate(fldPath.Child("registry"))...)
+	}
+	return allErrs
+}
+
 //Install  do install from registry.
 func (d Dependency) Install() (interface{}, error) {
 	dLog.V(utils.Debug).Info("install dependency", "name", d.Name, "version", d.Version)
@@ -70,11 +104,7 @@ func (d Dependency) Install() (interface{}, error) {
 		return string(DependencyAbsentErr), errors.New(utils.DependencyAbsentError)
 	case PullIfAbsent:
 		dLog.V(utils.Debug).Info("dependency strategy is pullIfAbsent")
-		if reflect.DeepEqual(d.Registry, Registry{}) {
-			dLog.V(utils.Warn).Info("no crd release registry found")
-			return string(DependencyPullingErr), errors.New(utils.DependencyPullError)
-		}
-		return d.Registry.Pull(d.Name, d.Version)
+		return d.pull()
 	case WaitIfAbsent:
 		fallthrough
 	default:
@@ -101,11 +131,84 @@ func (d Dependency) DoRecover() (interface{}, error) {
 //DoUpgrade  do upgrade from registry.
 func (d Dependency) DoUpgrade() (interface{}, error) {
 	dLog.V(utils.Info).Info("dependency upgrade", "release name", d.Name, "target version", d.Version)
+	return d.pull()
+}
+
+//pull  pull the lowest version satisfying the version constraint from registry, in full like 1.4.0 for ^1.4.
+//The registry does not resolve the constraints, those with no lower bound like <2.0.0 are not pulled.
+func (d Dependency) pull() (interface{}, error) {
 	if reflect.DeepEqual(d.Registry, Registry{}) {
 		dLog.V(utils.Warn).Info("no crd release registry found")
 		return string(DependencyPullingErr), errors.New(utils.DependencyPullError)
 	}
-	return d.Registry.Pull(d.Name, d.Version)
+	v, ok := d.minVersion()
+	if !ok {
+		dLog.V(utils.Warn).Info("no version to pull for the constraint", "name", d.Name, "constraint", d.Version)
+		return string(DependencyUnsatisfiable), errors.New(utils.DependencyUnsatisfiable)
+	}
+	return d.Registry.Pull(d.Name, v.String())
+}
+
+//constraint  parse the dependency version as semver constraint, nil for any version.
+func (d Dependency) constraint() (*semver.Constraints, error) {
+	if len(d.Version) == 0 {
+		return nil, nil
+	}
+	if v, err := semver.NewVersion(d.Version); err == nil {
+		return semver.NewConstraint(">=" + v.String())
+	}
+	return semver.NewConstraint(d.Version)
+}
+
+//minVersion  return the lowest version given in the constraint which satisfies it.
+func (d Dependency) minVersion() (*semver.Version, bool) {
+	if v, err := semver.NewVersion(d.Version); err == nil {
+		return v, true
+	}
+	c, err := d.constraint()
+	if err != nil || c == nil {
+		return nil, false
+	}
+	var min *semver.Version
+	for _, m := range constraintVersionRegex.FindAllStringSubmatch(d.Version, -1) {
+		switch m[1] {
+		case "", "=", ">=", "^", "~", "~>":
+		default:
+			continue
+		}
+		s := strings.NewReplacer("x", "0", "X", "0", "*", "0").Replace(m[2])
+		v, err := semver.NewVersion(s)
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		if min == nil || v.LessThan(min) {
+			min = v
+		}
+	}
+	return min, min != nil
+}
+
+//CheckVersion  check the installed version against the version constraint. Return the version to upgrade to when
+//the installed version is older than the constraint allows, an error when the constraint can not be satisfied.
+func (d Dependency) CheckVersion(current string) (ok bool, upgrade string, err error) {
+	c, err := d.constraint()
+	if err != nil {
+		return false, "", fmt.Errorf("invalid version constraint %s: %v", d.Version, err)
+	}
+	if c == nil {
+		return true, "", nil
+	}
+	v, err := semver.NewVersion(current)
+	if err != nil {
+		return false, "", fmt.Errorf("installed version %s is not a semantic version", current)
+	}
+	if c.Check(v) {
+		return true, "", nil
+	}
+	if min, ok := d.minVersion(); ok && v.LessThan(min) {
+		return false, min.String(), nil
+	}
+	return false, "", fmt.Errorf("installed version %s does not satisfy %s", current, d.Version)
 }
 
 //ConvertStatus : convert crd release phase to dependency status.
@@ -129,6 +232,11 @@ func (d Dependency) ConvertStatus(crdReleasePhase string, err error) (interface{
 		if len(status.Reason) == 0 {
 			status.Reason = utils.DependencyWaiting
 		}
+	case string(DependencyUnsatisfiable):
+		status.Phase = DependencyUnsatisfiable
+		if len(status.Reason) == 0 {
+			status.Reason = utils.DependencyUnsatisfiable
+		}
 	case string(CRDReleaseAbnormal):
 		fallthrough
 	default:
@@ -0,0 +1,44 @@
+package internal
+
+import "testing"
+
+func TestDependency_CheckVersion(t *testing.T) {
+	cases := []struct {
+		constraint, current string
+		ok                  bool
+		upgrade             string
+		err                 bool
+	}{
+		{"1.0.0", "1.2.0", true, "", false},
+		{"1.2.0", "1.1.9", false, "1.2.0", false},
+		{">=1.2.0 <2.0.0", "1.10.0", true, "", false},
+		{">=1.2.0 <2.0.0", "1.1.0", false, "1.2.0", false},
+		{">=1.2.0 <2.0.0", "2.0.0", false, "", true},
+		{"^1.4", "1.9.1", true, "", false},
+		{"^1.4", "1.3.0", false, "1.4.0", false},
+		{"1.x", "0.9.0", false, "1.0.0", false},
+		{"~2.1", "2.2.0", false, "", true},
+		{">=1.2.0", "1.3.0-beta", false, "", true},
+		{">=1.2.0-0", "1.3.0-beta", true, "", false},
+		{"<2.0.0", "1.0.0", true, "", false},
+		{"<2.0.0", "2.1.0", false, "", true},
+		{"", "anything", true, "", false},
+		{"^1.4", "latest", false, "", true},
+	}
+	for _, c := range cases {
+		ok, upgrade, err := Dependency{Name: "d", Version: c.constraint}.CheckVersion(c.current)
+		if ok != c.ok || upgrade != c.upgrade || (err != nil) != c.err {
+			t.Errorf("check %s against %s expected %v %q %v, got %v %q %v", c.current, c.constraint,
+				c.ok, c.upgrade, c.err, ok, upgrade, err)
+		}
+	}
+}
+
+func TestDependency_Validate(t *testing.T) {
+	if errs := (Dependency{Name: "d", Version: ">=1.2.0 <2.0.0"}).Validate(nil); len(errs) > 0 {
+		t.Errorf("valid constraint rejected: %v", errs)
+	}
+	if errs := (Dependency{Name: "d", Version: ">=1.2.0 <<2"}).Validate(nil); len(errs) == 0 {
+		t.Errorf("invalid constraint should be rejected")
+	}
+}
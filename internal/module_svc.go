@@ -3,6 +3,7 @@ package internal
 import (
 	"cloudnativeapp/clm/pkg/check/condition"
 	"cloudnativeapp/clm/pkg/check/precheck"
+	"cloudnativeapp/clm/pkg/dag"
 	"cloudnativeapp/clm/pkg/plugin"
 	"cloudnativeapp/clm/pkg/probe"
 	"cloudnativeapp/clm/pkg/recover"
@@ -11,6 +12,7 @@ import (
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"reflect"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
@@ -20,13 +22,21 @@ type Module struct {
 	// Indicates whether the module should be managed by controller.
 	Conditions condition.Condition `json:"conditions,omitempty"`
 	// Check before do crd release installation from source, the installation blocks until check success.
-	PreCheck condition.Condition `json:"preCheck,omitempty"`
+	PreCheck precheck.Precheck `json:"preCheck,omitempty"`
 	// The source of module installation.
 	Source Source `json:"source,omitempty"`
 	// Readiness prober after module installs successfully, the probe result will change the status of module.
 	Readiness probe.Probe `json:"readiness,omitempty"`
 	// Indicates whether do source recovery.
 	Recover Recover `json:"recover,omitempty"`
+	// Names of the modules in the same crd release which should be running before the module installs or upgrades.
+	// The module is uninstalled before them.
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Values exported to the other modules of the crd release as .Modules.<name>.<key>, templates rendered after
+	// the module installed or upgraded.
+	// +optional
+	Outputs map[string]string `json:"outputs,omitempty"`
 }
 
 type Recover struct {
@@ -34,14 +44,26 @@ type Recover struct {
 	Retry bool `json:"retry,omitempty"`
 	// Specify the action to recover. Do source recover when omitted.
 	Recover recover.Recover `json:"action,omitempty"`
+	// Backoff and budget of the recover attempts. Recover at each check while abnormal when omitted.
+	// +optional
+	Policy *RecoverPolicy `json:"policy,omitempty"`
 }
 
 type Source struct {
 	Name string `json:"name,omitempty"`
-	// Values to do installation from source.
+	// Namespace of the crd release to resolve the source, set by controller.
+	Namespace string `json:"-"`
+	// Values to do installation from source, the templates in string values are rendered with .Release, .Cluster,
+	// .Parameters and .Modules.
 	// +kubebuilder:pruning:PreserveUnknownFields
 	// +optional
 	Values *runtime.RawExtension `json:"values,omitempty"`
+	// Secrets and config maps in the namespace of the crd release merged in order into the values, the inline
+	// values win. The module is upgraded when the data referred changes.
+	// +optional
+	ValuesFrom []ValuesReference `json:"valuesFrom,omitempty"`
+	// Context to render the values with, set by controller.
+	Context *ValuesContext `json:"-"`
 }
 
 type ModuleStatus struct {
@@ -52,18 +74,42 @@ type ModuleStatus struct {
 	Conditions []ModuleCondition `json:"conditions,omitempty"`
 	// Recover count.
 	RecoverCount int `json:"recoverCount,omitempty"`
+	// Recover attempts since the module installed, upgraded or its recovery reset, limited by recover policy.
+	// +optional
+	RecoverAttempts int `json:"recoverAttempts,omitempty"`
+	// Time of the last recover attempt, the next attempt backs off from it.
+	// +optional
+	LastRecoverTime *v1.Time `json:"lastRecoverTime,omitempty"`
 	// Current state of the module.
 	State *ModuleState `json:"state,omitempty"`
 	// Last state of the module.
 	LastState *ModuleState `json:"lastState,omitempty"`
+	// Readiness probe results of the current state, the readiness check resumes from it after controller restarts.
+	// +optional
+	Probe *ModuleProbeStatus `json:"probe,omitempty"`
+	// Time the last upgrade started, cleared after the module turns running.
+	// +optional
+	UpgradeStartedAt *v1.Time `json:"upgradeStartedAt,omitempty"`
+	// Outputs exported by the module.
+	// +optional
+	Outputs map[string]string `json:"outputs,omitempty"`
+	// Checksum of the data referred by the source valuesFrom when the module was applied.
+	// +optional
+	ValuesChecksum string `json:"valuesChecksum,omitempty"`
 }
 
+// RecoverAttempts of the status returned by install, upgrade and rollback, renews the recover budget when updated.
+const RecoverAttemptsReset = -1
+
 type ModuleCondition struct {
 	Type   ModuleConditionType           `json:"type,omitempty"`
 	Status apiextensions.ConditionStatus `json:"status,omitempty"`
 	// Last time the condition transitioned from one status to another.
 	// +optional
 	LastTransitionTime v1.Time `json:"lastTransitionTime,omitempty" protobuf:"bytes,4,opt,name=lastTransitionTime"`
+	// Details of the condition, e.g. the failing clause of the pre-check.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 type ModuleConditionType string
@@ -81,6 +127,8 @@ const (
 	ModuleSourceReady ModuleConditionType = "SourceReady"
 	// ModuleReady means that module finished installation
 	ModuleReady ModuleConditionType = "Ready"
+	// ModuleDependsOnReady means that all modules the module depends on are running
+	ModuleDependsOnReady ModuleConditionType = "DependsOnReady"
 )
 
 const (
@@ -92,12 +140,16 @@ const (
 	ModuleRecovering string = "Recovering"
 	// Module abnormal phase.
 	ModuleAbnormal string = "Abnormal"
+	// Module abnormal and its recover attempts exhausted, until spec changes or recovery reset.
+	ModuleRecoveryExhausted string = "RecoveryExhausted"
 	// Module terminated phase, after the module has been uninstalled.
 	ModuleTerminated string = "Terminated"
 	// Do not care.
 	ModuleDontCare string = "DontCare"
 	// Module did not pass the preCheck.
 	PreCheckWaiting string = "PreCheckWaiting"
+	// Module waits for the modules it depends on to be running.
+	DependsOnWaiting string = "DependsOnWaiting"
 )
 
 type ModuleState struct {
@@ -109,6 +161,8 @@ type ModuleState struct {
 	Recovering *ModuleStateInternal `json:"recovering,omitempty"`
 	// Module installation failed or readiness probe failed without recovery strategy
 	Abnormal *ModuleStateInternal `json:"abnormal,omitempty"`
+	// Module abnormal after the recover attempts of recover policy exhausted
+	RecoveryExhausted *ModuleStateInternal `json:"recoveryExhausted,omitempty"`
 	// Module deleted success
 	Terminated *ModuleStateInternal `json:"terminated,omitempty"`
 }
@@ -121,6 +175,85 @@ type ModuleStateInternal struct {
 
 var mLog = ctrl.Log.WithName("module")
 
+//Default  set the module defaults, the readiness probe thresholds and the recover policy.
+func (m *Module) Default() {
+	m.Readiness.Default()
+	if m.Recover.Policy != nil {
+		m.Recover.Policy.Default()
+	}
+}
+
+//Validate  check the module settings which would otherwise fail during reconciliation.
+func (m Module) Validate(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(m.Name) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("name"), ""))
+	}
+	allErrs = append(allErrs, m.Conditions.Validate(fldPath.Child("conditions"))...)
+	allErrs = append(allErrs, m.PreCheck.Validate(fldPath.Child("preCheck"))...)
+	allErrs = append(allErrs, m.Readiness.Validate(fldPath.Child("readiness"))...)
+	allErrs = append(allErrs, m.validateTemplates(fldPath)...)
+	for i, r := range m.Source.ValuesFrom {
+		allErrs = append(allErrs, r.Validate(fldPath.Child("source", "valuesFrom").Index(i))...)
+	}
+	if m.Recover.Policy != nil {
+		allErrs = append(allErrs, m.Recover.Policy.Validate(fldPath.Child("recover", "policy"))...)
+	}
+	for i, d := range m.DependsOn {
+		if d == m.Name {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("dependsOn").Index(i), d,
+				"module can not depend on itself"))
+		}
+	}
+	return allErrs
+}
+
+//SortModules  sort the modules by dependsOn, in install order or in uninstall order when reverse.
+func SortModules(modules []Module, reverse bool) ([]Module, error) {
+	d := new(dag.DAG)
+	d.Init()
+	mmap := make(map[string]Module)
+	for _, m := range modules {
+		if !d.AddNode(m.Name, m.DependsOn) {
+			return nil, errors.New("repeated crd release module spec")
+		}
+		mmap[m.Name] = m
+	}
+	var q []string
+	var ok bool
+	if reverse {
+		q, ok = d.GetUninstallQueue()
+	} else {
+		q, ok = d.GetInstallQueue()
+	}
+	if !ok {
+		return nil, errors.New(utils.DependsOnInvalid)
+	}
+	result := make([]Module, len(q))
+	for i, n := range q {
+		result[i] = mmap[n]
+	}
+	return result, nil
+}
+
+//DependsOnReady  check whether all modules the module depends on are running.
+func (m Module) DependsOnReady(status []ModuleStatus) bool {
+	for _, d := range m.DependsOn {
+		ready := false
+		for _, s := range status {
+			if s.Name == d {
+				ready = s.Ready && s.State.Phase() == ModuleRunning
+				break
+			}
+		}
+		if !ready {
+			mLog.V(utils.Debug).Info("module waits for dependsOn module", "module", m.Name, "dependsOn", d)
+			return false
+		}
+	}
+	return true
+}
+
 //ConditionCheck do condition check , when the result is false, module will not be managed by clm.
 func (m Module) ConditionCheck() (bool, error) {
 	mLog.V(utils.Debug).Info("try to check condition", "module", m.Name)
@@ -129,14 +262,17 @@ func (m Module) ConditionCheck() (bool, error) {
 		return true, nil
 	}
 
-	return m.Conditions.Check(mLog)
+	ok, _, err := m.Conditions.Check(mLog)
+	return ok, err
 }
 
 // CheckStatus: return the action needed.
-func (m Module) CheckStatus(last Module, s *ModuleState, external bool, releaseUpdated bool,
-	updateCondition func(ModuleCondition, string)) (plugin.Action, string, error) {
+// A plan only reports the action, it runs neither the readiness probes nor the recover check.
+func (m Module) CheckStatus(last Module, status ModuleStatus, external bool, releaseUpdated bool, plan bool,
+	updateCondition func(ModuleCondition, string), updateProbe func(ModuleProbeStatus, string)) (plugin.Action, string, error) {
 	mLog.V(utils.Debug).Info("try to check module status", "module", m.Name, "last config",
 		last, "external", external)
+	s := status.State
 	if external {
 		// recheck in case external state changed
 		mLog.V(utils.Debug).Info("try to recheck condition", "module", m.Name)
@@ -157,87 +293,85 @@ func (m Module) CheckStatus(last Module, s *ModuleState, external bool, releaseU
 		ModuleCondition{Type: ModuleInitialized, Status: apiextensions.ConditionTrue, LastTransitionTime: v1.Now()}, m.Name)
 	updated := releaseUpdated && !reflect.DeepEqual(m, last)
 	// Start a complete process
-	if updated {
-		deleteModule(m.Name)
-	}
 	if s == nil || updated {
 		return m.emptyStateProc(updateCondition)
+	} else if s.RecoveryExhausted != nil && m.changed(last, status) {
+		// the upgrade renews the recover budget.
+		mLog.V(utils.Info).Info("recovery exhausted module changed, upgrade module", "module", m.Name)
+		return plugin.NeedUpgrade, ModuleDontCare, nil
+	} else if s.RecoveryExhausted != nil {
+		mLog.V(utils.Debug).Info("module recovery exhausted", "module", m.Name)
+		return plugin.NeedConvert, ModuleRecoveryExhausted, errors.New(utils.RecoveryExhausted)
+	} else if s.Abnormal != nil && plan {
+		return plugin.NeedNothing, ModuleAbnormal, nil
 	} else if s.Abnormal != nil {
 		mLog.V(utils.Debug).Info("abnormal module need recover", "module", m.Name)
-		return plugin.NeedRecover, ModuleDontCare, nil
+		return m.recoverCheck(status)
 	} else if s.Terminated != nil {
 		mLog.V(utils.Debug).Info("check terminated module", "module", m.Name)
 		return plugin.NeedNothing, ModuleTerminated, nil
-	} else {
-		mLog.V(utils.Info).Info("try to get module status", "name", m.Name)
-		state := getModule(m.Name)
-		if len(state) == 0 {
-			// maybe system reboot
-			state = m.resetMemAfterReboot(s)
-		} else {
-			mLog.V(utils.Debug).Info("module found in controller memory", "module", m.Name, "state", state)
-			if state == ModuleAbnormal {
-				return plugin.NeedRecover, ModuleDontCare, nil
-			}
-			// check last apply config and probe config
-			if !reflect.DeepEqual(m.Source, last.Source) || !reflect.DeepEqual(m.Readiness, last.Readiness) {
-				return plugin.NeedUpgrade, ModuleDontCare, nil
-			}
-		}
+	}
 
-		return plugin.NeedConvert, state, nil
+	// check last apply config and probe config, do not interrupt recovering.
+	if s.Recovering == nil && m.changed(last, status) {
+		return plugin.NeedUpgrade, ModuleDontCare, nil
 	}
+	if plan {
+		return plugin.NeedNothing, s.Phase(), nil
+	}
+	var key string
+	if c := m.Source.Context; c != nil {
+		key = probeKey(c.Release.Namespace, c.Release.Name, m.Name)
+	} else {
+		key = probeKey("", "", m.Name)
+	}
+	ps := lastProbe(key, status.Probe)
+	phase, err := m.readinessCheck(s, &ps)
+	if recordProbe(key, status.Probe, ps, phase != s.Phase()) {
+		updateProbe(ps, m.Name)
+	}
+	mLog.V(utils.Debug).Info("module state checked", "module", m.Name, "phase", phase)
+	return plugin.NeedConvert, phase, err
 }
 
-func (m Module) resetMemAfterReboot(s *ModuleState) (state string) {
-	mLog.V(utils.Debug).Info("can not find module in controller memory, maybe system reboot",
-		"module", m.Name)
-	ch := make(chan bool)
-	addModule(m.Name)
-	if s.Installing != nil {
-		updateModule(m.Name, ModuleInstalling, ch)
-		state = ModuleInstalling
-	} else if s.Recovering != nil {
-		updateModule(m.Name, ModuleRecovering, ch)
-		state = ModuleRecovering
-	} else if s.Running != nil {
-		updateModule(m.Name, ModuleRunning, ch)
-		state = ModuleRunning
+//changed  return true if the source or probe config differs from the last applied, or the data referred by
+//valuesFrom changed.
+func (m Module) changed(last Module, status ModuleStatus) bool {
+	if !reflect.DeepEqual(m.Source, last.Source) || !reflect.DeepEqual(m.Readiness, last.Readiness) {
+		return true
 	}
-	go readinessCheck(ch, m)
-	return
+	if m.valuesFromChanged(status) {
+		mLog.V(utils.Info).Info("data referred by valuesFrom changed", "module", m.Name)
+		return true
+	}
+	return false
 }
 
 func (m Module) emptyStateProc(updateCondition func(ModuleCondition, string)) (plugin.Action, string, error) {
-	if ok, err := m.preCheck(); err != nil {
+	if ok, clause, err := m.preCheck(); err != nil {
 		mLog.Error(err, "pre check failed", "name", m.Name)
 		return plugin.NeedNothing, PreCheckWaiting, err
 	} else if !ok {
-		mLog.V(utils.Debug).Info("module pre-check failed", "module", m.Name)
+		mLog.V(utils.Debug).Info("module pre-check failed", "module", m.Name, "clause", clause)
 		updateCondition(
-			ModuleCondition{Type: ModulePreChecked, Status: apiextensions.ConditionFalse, LastTransitionTime: v1.Now()},
-			m.Name)
-		return plugin.NeedNothing, PreCheckWaiting, nil
+			ModuleCondition{Type: ModulePreChecked, Status: apiextensions.ConditionFalse, LastTransitionTime: v1.Now(),
+				Message: clause}, m.Name)
+		return plugin.NeedNothing, PreCheckWaiting, errors.New(clause)
 	}
 	updateCondition(
 		ModuleCondition{Type: ModulePreChecked, Status: apiextensions.ConditionTrue, LastTransitionTime: v1.Now()},
 		m.Name)
-	if ok := addModule(m.Name); ok {
-		mLog.V(utils.Debug).Info("module add success", "module", m.Name)
-		return plugin.NeedInstall, ModuleDontCare, nil
-	} else {
-		mLog.V(utils.Debug).Info("module add failed", "module", m.Name)
-		return plugin.NeedNothing, ModuleAbnormal, nil
-	}
+	return plugin.NeedInstall, ModuleDontCare, nil
 }
 
-func (m Module) preCheck() (bool, error) {
+//preCheck  check the module can be installed, return the failing clause when it can not.
+func (m Module) preCheck() (bool, string, error) {
 	mLog.V(utils.Debug).Info("try to do pre-check", "module", m.Name)
 	if !reflect.DeepEqual(m.PreCheck, precheck.Precheck{}) {
 		return m.PreCheck.Check(mLog)
 	}
 	mLog.V(utils.Debug).Info("empty pre-check configuration", "module", m.Name)
-	return true, nil
+	return true, "", nil
 }
 
 //Install  do install with source and return module status to be updated.
@@ -249,9 +383,8 @@ func (m Module) Install() (interface{}, error) {
 		ModuleCondition{Type: ModuleInitialized, Status: apiextensions.ConditionTrue, LastTransitionTime: v1.Now()})
 	result.Conditions = append(result.Conditions,
 		ModuleCondition{Type: ModulePreChecked, Status: apiextensions.ConditionTrue, LastTransitionTime: v1.Now()})
-	if reflect.DeepEqual(m.Source, Source{}) {
+	if m.Source.empty() {
 		mLog.V(utils.Warn).Info("source not configured", "module", m.Name, "source", m.Source.Name)
-		deleteModule(m.Name)
 		result.Conditions = append(result.Conditions,
 			ModuleCondition{Type: ModuleSourceReady, Status: apiextensions.ConditionFalse, LastTransitionTime: v1.Now()})
 		return result, errors.New(utils.ModuleStateAbnormal)
@@ -263,15 +396,15 @@ func (m Module) Install() (interface{}, error) {
 		if err := installFromSource(m.Source, m.Name, ""); err != nil {
 			mLog.Error(err, "install from source error", "name", m.Name)
 			result.State = GenModuleState(ModuleAbnormal, "install from source failed", err.Error())
+			result.RecoverAttempts = RecoverAttemptsReset
 			return result, err
 		} else {
-			// 开启监控协程
 			mLog.V(utils.Debug).Info("install from source success", "module", m.Name, "source", m.Source)
-			ch := make(chan bool)
-			addModule(m.Name)
-			updateModule(m.Name, ModuleInstalling, ch)
-			go readinessCheck(ch, m)
+			result.Outputs = m.outputs()
+			result.ValuesChecksum = m.valuesChecksum()
 			result.State = GenModuleState(ModuleInstalling, "", "")
+			result.Probe = &ModuleProbeStatus{}
+			result.RecoverAttempts = RecoverAttemptsReset
 			return result, nil
 		}
 	}
@@ -280,12 +413,10 @@ func (m Module) Install() (interface{}, error) {
 //Uninstall do uninstall with source and return module status to be updated.
 func (m Module) Uninstall() (interface{}, error) {
 	mLog.V(utils.Debug).Info("try to uninstall module", "module", m.Name)
-	deleteModule(m.Name)
 	result := ModuleStatus{}
 	result.Name = m.Name
-	if reflect.DeepEqual(m.Source, Source{}) {
+	if m.Source.empty() {
 		mLog.V(utils.Warn).Info("source not configured", "module", m.Name, "source", m.Source.Name)
-		deleteModule(m.Name)
 		result.Conditions = append(result.Conditions,
 			ModuleCondition{Type: ModuleSourceReady, Status: apiextensions.ConditionFalse, LastTransitionTime: v1.Now()})
 		return result, nil
@@ -298,6 +429,7 @@ func (m Module) Uninstall() (interface{}, error) {
 			return result, nil
 		} else {
 			mLog.V(utils.Debug).Info("uninstall from source success", "module", m.Name, "source", m.Source)
+			m.forgetHelmTests()
 			result.Conditions = append(result.Conditions,
 				ModuleCondition{Type: ModuleReady, Status: apiextensions.ConditionFalse, LastTransitionTime: v1.Now()})
 			result.State = GenModuleState(ModuleTerminated, "", "")
@@ -313,58 +445,39 @@ func (m Module) DoRecover() (interface{}, error) {
 	result.Name = m.Name
 	result.Conditions = append(result.Conditions,
 		ModuleCondition{Type: ModuleReady, Status: apiextensions.ConditionFalse, LastTransitionTime: v1.Now()})
-	s := getModule(m.Name)
-	if s == ModuleRecovering {
-		// 正在恢复中
-		mLog.V(utils.Debug).Info("module already in recover mode", "module", m.Name)
-		return ModuleStatus{}, nil
-	}
 
-	if len(s) == 0 {
-		addModule(m.Name)
+	if !m.Recover.Retry && !reflect.DeepEqual(m.Recover.Recover, recover.Recover{}) {
+		mLog.V(utils.Warn).Info("do not retry to recover", "name", m.Name)
+		result.State = GenModuleState(ModuleAbnormal, "strategy is not retry", "")
+		return result, nil
 	}
-
-	if reflect.DeepEqual(m.Recover, Recover{}) {
+	// Failed attempts count in the recover budget as well.
+	now := v1.Now()
+	result.LastRecoverTime = &now
+	result.RecoverAttempts = 1
+	if !m.Recover.Retry {
 		mLog.V(utils.Debug).Info("do source recover", "name", m.Name, "source", m.Source)
 		if err := recoverFromSource(m.Source, m.Name, ""); err != nil {
-			if !updateModule(m.Name, ModuleAbnormal, nil) {
-				return nil, errors.New("update module to recovering failed")
-			}
 			result.State = GenModuleState(ModuleAbnormal, err.Error(), "update module to recovering failed")
 			return result, err
 		}
-	} else if m.Recover.Retry == false {
-		mLog.V(utils.Warn).Info("do not retry to recover", "name", m.Name)
-		if !updateModule(m.Name, ModuleAbnormal, nil) {
-			return nil, errors.New("update module to recovering failed")
-		}
-		result.State = GenModuleState(ModuleAbnormal, "strategy is not retry", "")
-		return result, nil
 	} else if reflect.DeepEqual(m.Recover.Recover, recover.Recover{}) {
 		mLog.V(utils.Warn).Info("do source recover", "name", m.Name, "source", m.Source)
 		if err := recoverFromSource(m.Source, m.Name, ""); err != nil {
-			if !updateModule(m.Name, ModuleAbnormal, nil) {
-				return nil, errors.New("update module to recovering failed")
-			}
 			result.State = GenModuleState(ModuleAbnormal, err.Error(), "update module to recovering failed")
 			return result, err
 		}
 	} else {
 		mLog.V(utils.Debug).Info("module recover", "module", m.Name, "recover", m.Recover)
 		if err := m.Recover.Recover.DoRecover(mLog); err != nil {
-			if !updateModule(m.Name, ModuleAbnormal, nil) {
-				return nil, errors.New("update module to recovering failed")
-			}
 			result.State = GenModuleState(ModuleAbnormal, err.Error(), "update module to recovering failed")
 			return result, err
 		}
 	}
 
 	mLog.V(utils.Debug).Info("recover from source success", "module", m.Name, "source", m.Source)
-	ch := make(chan bool)
-	updateModule(m.Name, ModuleRecovering, ch)
-	go readinessCheck(ch, m)
 	result.State = GenModuleState(ModuleRecovering, "", "")
+	result.Probe = &ModuleProbeStatus{}
 	result.RecoverCount = 1
 	return result, nil
 }
@@ -374,15 +487,10 @@ func (m Module) DoUpgrade() (interface{}, error) {
 	mLog.V(utils.Debug).Info("try to upgrade module", "module", m.Name)
 	result := ModuleStatus{}
 	result.Name = m.Name
-	s := getModule(m.Name)
-	if s == ModuleRecovering {
-		// 正在恢复中
-		mLog.V(utils.Debug).Info("module already in recover mode", "module", m.Name)
-		return ModuleStatus{}, nil
-	}
-	if reflect.DeepEqual(m.Source, Source{}) {
+	now := v1.Now()
+	result.UpgradeStartedAt = &now
+	if m.Source.empty() {
 		mLog.V(utils.Warn).Info("source not configured", "module", m.Name, "source", m.Source.Name)
-		deleteModule(m.Name)
 		result.Conditions = append(result.Conditions,
 			ModuleCondition{Type: ModuleSourceReady, Status: apiextensions.ConditionFalse, LastTransitionTime: v1.Now()})
 		return result, errors.New(utils.ModuleStateAbnormal)
@@ -394,19 +502,42 @@ func (m Module) DoUpgrade() (interface{}, error) {
 		if err := upgradeFromSource(m.Source, m.Name, ""); err != nil {
 			mLog.Error(err, "upgrade from source error", "name", m.Name)
 			result.State = GenModuleState(ModuleAbnormal, "upgrade from source failed", err.Error())
+			result.RecoverAttempts = RecoverAttemptsReset
 			return result, err
 			//return result, errors.New(utils.ModuleStateAbnormal)
 		} else {
 			mLog.V(utils.Debug).Info("upgrade from source success", "module", m.Name, "source", m.Source)
-			ch := make(chan bool)
-			updateModule(m.Name, ModuleInstalling, ch)
-			go readinessCheck(ch, m)
+			result.Outputs = m.outputs()
+			result.ValuesChecksum = m.valuesChecksum()
 			result.State = GenModuleState(ModuleInstalling, "", "")
+			result.Probe = &ModuleProbeStatus{}
+			result.RecoverAttempts = RecoverAttemptsReset
 			return result, nil
 		}
 	}
 }
 
+//DoRollback  rollback the module to the configuration before upgrade, the module recovers until readiness passes.
+func (m Module) DoRollback() (interface{}, error) {
+	mLog.V(utils.Debug).Info("try to rollback module", "module", m.Name)
+	result := ModuleStatus{}
+	result.Name = m.Name
+	result.Conditions = append(result.Conditions,
+		ModuleCondition{Type: ModuleReady, Status: apiextensions.ConditionFalse, LastTransitionTime: v1.Now()})
+	if err := rollbackFromSource(m.Source, m.Name, ""); err != nil {
+		mLog.Error(err, "rollback from source error", "name", m.Name)
+		result.State = GenModuleState(ModuleAbnormal, "rollback from source failed", err.Error())
+		return result, err
+	}
+	mLog.V(utils.Debug).Info("rollback from source success", "module", m.Name, "source", m.Source)
+	result.Outputs = m.outputs()
+	result.ValuesChecksum = m.valuesChecksum()
+	result.State = GenModuleState(ModuleRecovering, "rolled back", "")
+	result.Probe = &ModuleProbeStatus{}
+	result.RecoverAttempts = RecoverAttemptsReset
+	return result, nil
+}
+
 //Attributes  return the module name and version.
 func (m Module) Attributes() (name, version string) {
 	return m.Name, ""
@@ -426,8 +557,14 @@ func (m Module) ConvertStatus(modulePhase string, err error) (interface{}, error
 	case PreCheckWaiting:
 		mLog.V(utils.Info).Info("module preCheck waiting", "module", m.Name, "reason", reason)
 		s.Conditions = append(s.Conditions,
-			ModuleCondition{Type: ModulePreChecked, Status: apiextensions.ConditionFalse, LastTransitionTime: v1.Now()})
+			ModuleCondition{Type: ModulePreChecked, Status: apiextensions.ConditionFalse, LastTransitionTime: v1.Now(),
+				Message: reason})
 		return s, errors.New(utils.PreCheckWaiting)
+	case DependsOnWaiting:
+		mLog.V(utils.Info).Info("module dependsOn waiting", "module", m.Name, "dependsOn", m.DependsOn)
+		s.Conditions = append(s.Conditions,
+			ModuleCondition{Type: ModuleDependsOnReady, Status: apiextensions.ConditionFalse, LastTransitionTime: v1.Now()})
+		return s, errors.New(utils.DependsOnWaiting)
 	case ModuleInstalling:
 		s.Conditions = append(s.Conditions,
 			ModuleCondition{Type: ModuleReady, Status: apiextensions.ConditionFalse, LastTransitionTime: v1.Now()})
@@ -442,7 +579,13 @@ func (m Module) ConvertStatus(modulePhase string, err error) (interface{}, error
 	case ModuleTerminated:
 		s.State = GenModuleState(ModuleTerminated, "", reason)
 	case ModuleAbnormal:
+		s.Conditions = append(s.Conditions,
+			ModuleCondition{Type: ModuleReady, Status: apiextensions.ConditionFalse, LastTransitionTime: v1.Now()})
 		s.State = GenModuleState(ModuleAbnormal, "from convert status", reason)
+	case ModuleRecoveryExhausted:
+		s.Conditions = append(s.Conditions,
+			ModuleCondition{Type: ModuleReady, Status: apiextensions.ConditionFalse, LastTransitionTime: v1.Now()})
+		s.State = GenModuleState(ModuleRecoveryExhausted, "", reason)
 	default:
 		mLog.V(utils.Warn).Info("error module phase when convert to status", "module", m.Name,
 			"phase", modulePhase)
@@ -471,6 +614,37 @@ func (m ModuleStatus) UpdateStatus(new ModuleStatus) ModuleStatus {
 	}
 
 	result.RecoverCount = m.RecoverCount + new.RecoverCount
+	if new.RecoverAttempts == RecoverAttemptsReset {
+		result.RecoverAttempts = 0
+		result.LastRecoverTime = nil
+	} else {
+		result.RecoverAttempts = m.RecoverAttempts + new.RecoverAttempts
+		result.LastRecoverTime = m.LastRecoverTime
+		if new.LastRecoverTime != nil {
+			result.LastRecoverTime = new.LastRecoverTime
+		}
+	}
+	if new.UpgradeStartedAt != nil {
+		result.UpgradeStartedAt = new.UpgradeStartedAt
+	} else if result.State.Phase() != ModuleRunning {
+		result.UpgradeStartedAt = m.UpgradeStartedAt
+	}
+	if new.Outputs != nil {
+		result.Outputs = new.Outputs
+	} else {
+		result.Outputs = m.Outputs
+	}
+	if len(new.ValuesChecksum) > 0 {
+		result.ValuesChecksum = new.ValuesChecksum
+	} else {
+		result.ValuesChecksum = m.ValuesChecksum
+	}
+	// An empty probe status resets the probe results after install, upgrade or recover.
+	if new.Probe == nil {
+		result.Probe = m.Probe
+	} else if *new.Probe != (ModuleProbeStatus{}) {
+		result.Probe = new.Probe
+	}
 
 	for _, i := range m.Conditions {
 		result.Conditions = append(result.Conditions, i)
@@ -483,6 +657,7 @@ func (m ModuleStatus) UpdateStatus(new ModuleStatus) ModuleStatus {
 				if i.Status != j.Status {
 					result.Conditions[k].Status = i.Status
 				}
+				result.Conditions[k].Message = i.Message
 				find = true
 			}
 		}
@@ -518,6 +693,9 @@ func ModuleStateEqual(new, old *ModuleState) bool {
 	if !moduleStateInternalEqual(new.Abnormal, old.Abnormal) {
 		return false
 	}
+	if !moduleStateInternalEqual(new.RecoveryExhausted, old.RecoveryExhausted) {
+		return false
+	}
 	return true
 }
 
@@ -540,6 +718,45 @@ func moduleStateInternalEqual(new, old *ModuleStateInternal) bool {
 	return true
 }
 
+//Phase  return the module phase of the state.
+func (s *ModuleState) Phase() string {
+	switch {
+	case s == nil:
+		return ""
+	case s.RecoveryExhausted != nil:
+		return ModuleRecoveryExhausted
+	case s.Abnormal != nil:
+		return ModuleAbnormal
+	case s.Terminated != nil:
+		return ModuleTerminated
+	case s.Recovering != nil:
+		return ModuleRecovering
+	case s.Installing != nil:
+		return ModuleInstalling
+	case s.Running != nil:
+		return ModuleRunning
+	}
+	return ""
+}
+
+func (s *ModuleState) current() *ModuleStateInternal {
+	switch s.Phase() {
+	case ModuleRecoveryExhausted:
+		return s.RecoveryExhausted
+	case ModuleAbnormal:
+		return s.Abnormal
+	case ModuleTerminated:
+		return s.Terminated
+	case ModuleRecovering:
+		return s.Recovering
+	case ModuleInstalling:
+		return s.Installing
+	case ModuleRunning:
+		return s.Running
+	}
+	return nil
+}
+
 //GetConditionStatus  return the condition status of module condition type.
 func (m ModuleStatus) GetConditionStatus(t ModuleConditionType) apiextensions.ConditionStatus {
 	for _, c := range m.Conditions {
@@ -576,9 +793,7 @@ func GenerateImportedModuleStatus(m Module) ModuleStatus {
 		ModuleCondition{Type: ModuleSourceReady, Status: apiextensions.ConditionTrue, LastTransitionTime: v1.Now()})
 	status.Conditions = append(status.Conditions,
 		ModuleCondition{Type: ModuleReady, Status: apiextensions.ConditionTrue, LastTransitionTime: v1.Now()})
-	s := GenModuleState(ModuleRunning, "", "imported")
-	m.resetMemAfterReboot(s)
-	status.State = s
+	status.State = GenModuleState(ModuleRunning, "", "imported")
 	return status
 }
 
@@ -617,6 +832,14 @@ func GenModuleState(phase, message, reason string) *ModuleState {
 				Reason:    reason,
 			},
 		}
+	case ModuleRecoveryExhausted:
+		return &ModuleState{
+			RecoveryExhausted: &ModuleStateInternal{
+				StartedAt: v1.Now(),
+				Message:   message,
+				Reason:    reason,
+			},
+		}
 	case ModuleAbnormal:
 		fallthrough
 	default:
@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestSortModules(t *testing.T) {
+	modules := []Module{
+		{Name: "workload", DependsOn: []string{"webhook", "crd"}},
+		{Name: "webhook", DependsOn: []string{"crd"}},
+		{Name: "crd"},
+	}
+	sorted, err := SortModules(modules, false)
+	if err != nil {
+		t.Fatalf("sort modules failed: %v", err)
+	}
+	if sorted[0].Name != "crd" || sorted[1].Name != "webhook" || sorted[2].Name != "workload" {
+		t.Errorf("get wrong install order: %v", sorted)
+	}
+	sorted, err = SortModules(modules, true)
+	if err != nil {
+		t.Fatalf("sort modules failed: %v", err)
+	}
+	if sorted[0].Name != "workload" || sorted[1].Name != "webhook" || sorted[2].Name != "crd" {
+		t.Errorf("get wrong uninstall order: %v", sorted)
+	}
+
+	modules[2].DependsOn = []string{"workload"}
+	if _, err := SortModules(modules, false); err == nil {
+		t.Errorf("dependsOn cycle should fail")
+	}
+}
+
+func TestModule_DependsOnReady(t *testing.T) {
+	m := Module{Name: "workload", DependsOn: []string{"crd"}}
+	status := []ModuleStatus{{Name: "crd", State: GenModuleState(ModuleInstalling, "", "")}}
+	if m.DependsOnReady(status) {
+		t.Errorf("installing dependsOn module should not be ready")
+	}
+	status[0].Ready = true
+	status[0].State = GenModuleState(ModuleRunning, "", "")
+	if !m.DependsOnReady(status) {
+		t.Errorf("running dependsOn module should be ready")
+	}
+	if m.DependsOnReady(nil) {
+		t.Errorf("absent dependsOn module should not be ready")
+	}
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"cloudnativeapp/clm/pkg/plugin"
+	"cloudnativeapp/clm/pkg/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"strings"
+)
+
+// Max length of the manifest diff recorded in plan.
+const MaxPlanDiffLen = 8 * 1024
+
+type ReleasePlan struct {
+	// Time the plan changed last.
+	PlannedAt v1.Time `json:"plannedAt,omitempty"`
+	// Actions planned for the dependencies.
+	// +optional
+	Dependencies []PlanAction `json:"dependencies,omitempty"`
+	// Actions planned for the modules, in install order.
+	// +optional
+	Modules []PlanAction `json:"modules,omitempty"`
+}
+
+type PlanAction struct {
+	// Name of the dependency or module.
+	Name string `json:"name"`
+	// Version of the dependency.
+	// +optional
+	Version string `json:"version,omitempty"`
+	// Action to take, NeedInstall, NeedUpgrade, NeedUninstall, NeedRecover, NeedConvert or NeedNothing.
+	Action plugin.Action `json:"action"`
+	// Phase checked when nothing to do.
+	// +optional
+	Phase string `json:"phase,omitempty"`
+	// Error of the check or the manifest rendering.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Diff of the manifests rendered from source against the cluster, truncated.
+	// +optional
+	Diff string `json:"diff,omitempty"`
+}
+
+//Equal  compare the plans ignoring the time planned.
+func (p ReleasePlan) Equal(o ReleasePlan) bool {
+	return reflect.DeepEqual(p.Dependencies, o.Dependencies) && reflect.DeepEqual(p.Modules, o.Modules)
+}
+
+//Plan  render the manifests the action would apply and diff them against the cluster, without calling the source
+//to install. Empty when the action applies nothing.
+func (m Module) Plan(act plugin.Action) (string, error) {
+	switch act {
+	case plugin.NeedInstall, plugin.NeedUpgrade, plugin.NeedRecover:
+	default:
+		return "", nil
+	}
+	if reflect.DeepEqual(m.Source, Source{}) {
+		return "", nil
+	}
+	mLog.V(utils.Debug).Info("try to plan module", "module", m.Name, "action", act)
+	diff, err := planFromSource(m.Source, m.Name, "")
+	return truncateLines(diff, MaxPlanDiffLen), err
+}
+
+//truncateLines  cut the text to the whole lines within the max length.
+func truncateLines(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	if i := strings.LastIndexByte(s[:max], '\n'); i >= 0 {
+		return s[:i+1]
+	}
+	return ""
+}
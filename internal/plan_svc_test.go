@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"cloudnativeapp/clm/pkg/plugin"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"testing"
+	"time"
+)
+
+func TestReleasePlan_Equal(t *testing.T) {
+	p := ReleasePlan{PlannedAt: v1.Now(), Modules: []PlanAction{{Name: "m", Action: plugin.NeedInstall}}}
+	o := ReleasePlan{PlannedAt: v1.NewTime(time.Now().Add(-time.Minute)),
+		Modules: []PlanAction{{Name: "m", Action: plugin.NeedInstall}}}
+	if !p.Equal(o) {
+		t.Errorf("plans with the same actions should be equal")
+	}
+	o.Modules[0].Action = plugin.NeedUpgrade
+	if p.Equal(o) {
+		t.Errorf("plans with different actions should not be equal")
+	}
+}
+
+func TestModule_Plan(t *testing.T) {
+	m := Module{Name: "m", Source: Source{Name: "absent", Values: &runtime.RawExtension{Raw: []byte(`{}`)}}}
+	if d, err := m.Plan(plugin.NeedConvert); err != nil || len(d) > 0 {
+		t.Errorf("nothing should be planned for convert, got %q %v", d, err)
+	}
+	if _, err := m.Plan(plugin.NeedInstall); err == nil {
+		t.Errorf("plan from absent source should fail")
+	}
+}
+
+func TestTruncateLines(t *testing.T) {
+	cases := []struct {
+		s, expected string
+		max         int
+	}{
+		{"a\nb\n", "a\nb\n", 4},
+		{"a\nb\n", "a\n", 3},
+		{"ab\n", "", 2},
+		// Not cut in the middle of a rune.
+		{"a\n名字\n", "a\n", 5},
+	}
+	for _, c := range cases {
+		if r := truncateLines(c.s, c.max); r != c.expected {
+			t.Errorf("truncate %q to %d expected %q, got %q", c.s, c.max, c.expected, r)
+		}
+	}
+}
@@ -2,8 +2,12 @@ package internal
 
 import (
 	"cloudnativeapp/clm/pkg/probe"
+	"cloudnativeapp/clm/pkg/probe/workloads"
 	"cloudnativeapp/clm/pkg/prober"
 	"cloudnativeapp/clm/pkg/utils"
+	"errors"
+	"fmt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"reflect"
 	"strings"
 	"sync"
@@ -12,145 +16,238 @@ import (
 
 var Prober *prober.Prober
 
-type ModuleReadinessState struct {
-	State string
-	Ch    chan bool
+// Max length of the probe output recorded in module status.
+const MaxProbeOutputLen = 256
+
+// The probe time in module status is refreshed at this granularity when the probe result does not change.
+const ProbeStatusRefresh = 5 * time.Minute
+
+// Readiness probe results of the modules by crd release and module. Every probe is kept here, the module status is
+// updated when the result, the counters or the module phase change, or the probe time recorded is older than
+// ProbeStatusRefresh. The counters stop at the thresholds, so the module status is the source of truth of them and
+// the steady probes are not written.
+var probeRecords = struct {
+	sync.Mutex
+	m map[string]ModuleProbeStatus
+}{m: make(map[string]ModuleProbeStatus)}
+
+type ModuleProbeStatus struct {
+	// Last time the readiness probe ran.
+	// +optional
+	LastProbeTime *v1.Time `json:"lastProbeTime,omitempty"`
+	// Result of the last readiness probe.
+	LastResult probe.Result `json:"lastResult,omitempty"`
+	// Output or error of the last readiness probe, truncated.
+	LastOutput string `json:"lastOutput,omitempty"`
+	// Consecutive successful probes since the last failure.
+	ConsecutiveSuccesses int `json:"consecutiveSuccesses,omitempty"`
+	// Consecutive failed probes since the last success.
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+	// Revision of the helm release whose tests passed, the tests are not run again for it after controller restarts.
+	// +optional
+	TestedRevision int `json:"testedRevision,omitempty"`
 }
 
-var ModuleReadinessStateMap = struct {
-	m map[string]*ModuleReadinessState
-	sync.RWMutex
-}{
-	m: make(map[string]*ModuleReadinessState),
+//nextProbeTime  return the time when the next readiness probe is due, the first probe waits a period after the
+//module entered its current state.
+func (m Module) nextProbeTime(state *ModuleState, s ModuleProbeStatus) time.Time {
+	p := m.Readiness.DeepCopy()
+	p.Default()
+	var last time.Time
+	if s.LastProbeTime != nil {
+		last = s.LastProbeTime.Time
+	} else if i := state.current(); i != nil {
+		last = i.StartedAt.Time
+	}
+	return last.Add(time.Duration(p.PeriodSeconds) * time.Second)
 }
 
-//addModule  add the module to clm readiness map.
-func addModule(name string) bool {
-	defer ModuleReadinessStateMap.Unlock()
-	ModuleReadinessStateMap.Lock()
-	key := name
-	if _, ok := ModuleReadinessStateMap.m[key]; ok {
-		mLog.V(utils.Warn).Info("module exist already", "name", name)
-		return false
+//readinessCheck  run the readiness probe when due and return the module phase according to the probe results.
+//The probe results are recorded to s, the counters resume from the module status after controller restarts.
+func (m Module) readinessCheck(state *ModuleState, s *ModuleProbeStatus) (string, error) {
+	phase := state.Phase()
+	if reflect.DeepEqual(m.Readiness, probe.Probe{}) {
+		// update to running when no readiness setting.
+		return ModuleRunning, nil
 	}
-	ModuleReadinessStateMap.m[key] = &ModuleReadinessState{
-		State: ModuleInstalling,
+	if time.Now().Before(m.nextProbeTime(state, *s)) {
+		return phase, nil
 	}
-	return true
-}
+	p := m.Readiness.DeepCopy()
+	p.Default()
 
-//deleteModule  delete the module from the clm readiness map.
-func deleteModule(name string) bool {
-	defer ModuleReadinessStateMap.Unlock()
-	ModuleReadinessStateMap.Lock()
-	key := name
-	if v, ok := ModuleReadinessStateMap.m[key]; !ok {
-		mLog.V(utils.Warn).Info("module does not exist", "name", name)
-		return false
-	} else {
-		if v.Ch != nil {
-			close(v.Ch)
-			v.Ch = nil
+	result, out := probe.Unknown, ""
+	var err error
+	if p.Workloads != nil && p.Workloads.FromSource() {
+		p.Workloads.Objects, err = m.appliedWorkloads()
+	}
+	if p.Helm != nil && len(p.Helm.ReleaseName) == 0 {
+		p.Helm.ReleaseName, p.Helm.Namespace, err = releaseFromSource(m.Source)
+	}
+	if c := m.Source.Context; p.Exec != nil && c != nil && len(c.Release.Namespace) > 0 &&
+		p.Exec.Namespace != c.Release.Namespace {
+		err = fmt.Errorf("exec namespace %s is not the crd release namespace %s", p.Exec.Namespace,
+			c.Release.Namespace)
+	}
+	if err == nil {
+		if p.Helm != nil && p.Helm.Test {
+			Prober.SetHelmTested(p.Helm, s.TestedRevision)
 		}
-		delete(ModuleReadinessStateMap.m, key)
-		return true
+		result, out, err = Prober.RunProbeWithRetries(3, p)
+		if p.Helm != nil && p.Helm.Test {
+			s.TestedRevision = Prober.HelmTested(p.Helm)
+		}
+	}
+	mLog.V(utils.Info).Info("readiness output", "module", m.Name, "out", out)
+	now := v1.Now()
+	s.LastProbeTime = &now
+	s.LastResult = result
+	if err != nil {
+		out = err.Error()
+	}
+	if len(out) > MaxProbeOutputLen {
+		out = out[:MaxProbeOutputLen]
+	}
+	s.LastOutput = out
+	if err == nil && result == probe.Success {
+		s.ConsecutiveFailures = 0
+		if s.ConsecutiveSuccesses < p.SuccessThreshold {
+			s.ConsecutiveSuccesses++
+		}
+		if s.ConsecutiveSuccesses >= p.SuccessThreshold {
+			return ModuleRunning, nil
+		}
+		return phase, nil
+	}
+	if err == nil && result == probe.Unknown {
+		// Pending like the helm tests running, neither success nor failure.
+		mLog.V(utils.Debug).Info("readiness pending", "name", m.Name, "out", out)
+		return phase, nil
+	}
+
+	if err != nil {
+		mLog.Error(err, "readiness failed", "name", m.Name)
+	} else { // result != probe.Success
+		mLog.V(utils.Warn).Info("readiness warning", "name", m.Name, "result", result)
+	}
+	s.ConsecutiveSuccesses = 0
+	if s.ConsecutiveFailures < p.FailureThreshold || s.ConsecutiveFailures < p.RecoverThreshold {
+		s.ConsecutiveFailures++
+	}
+	if (phase == ModuleRecovering && s.ConsecutiveFailures >= p.RecoverThreshold) ||
+		(phase == ModuleRunning && s.ConsecutiveFailures >= p.FailureThreshold) {
+		mLog.V(utils.Info).Info("readiness update module", "name", m.Name, "from", phase, "to", ModuleAbnormal)
+		return ModuleAbnormal, errors.New("readiness probe failed: " + s.LastOutput)
 	}
+	return phase, nil
 }
 
-//updateModule  update the module state to the clm readiness map.
-func updateModule(name, s string, ch chan bool) bool {
-	defer ModuleReadinessStateMap.Unlock()
-	ModuleReadinessStateMap.Lock()
-	key := name
-	if v, ok := ModuleReadinessStateMap.m[key]; !ok {
-		mLog.V(utils.Warn).Info("module does not exist", "name", name)
-		return false
-	} else {
-		v.State = s
-		if ch != nil {
-			if v.Ch != nil && v.Ch != ch {
-				close(v.Ch)
-			}
-			v.Ch = ch
+//forgetHelmTests  drop the helm tests of the module uninstalled kept by the prober.
+func (m Module) forgetHelmTests() {
+	p := m.Readiness.Helm
+	if p == nil || !p.Test {
+		return
+	}
+	p = p.DeepCopy()
+	if len(p.ReleaseName) == 0 {
+		var err error
+		if p.ReleaseName, p.Namespace, err = releaseFromSource(m.Source); err != nil {
+			return
 		}
-		mLog.V(utils.Info).Info("update module status", "name", name, "status", v.State)
-		return true
 	}
+	Prober.ForgetHelm(p)
 }
 
-func getModule(name string) string {
-	defer ModuleReadinessStateMap.RUnlock()
-	ModuleReadinessStateMap.RLock()
-	key := name
-	if v, ok := ModuleReadinessStateMap.m[key]; !ok {
-		mLog.V(utils.Warn).Info("module does not exist", "name", name)
-		return ""
-	} else {
-		mLog.V(utils.Info).Info("module status got", "name", name, "status", v.State)
-		return v.State
-	}
+//probeKey  return the key of the module probe results, the namespace is empty for the cluster scoped crd releases.
+func probeKey(namespace, release, module string) string {
+	return namespace + "/" + release + "/" + module
 }
 
-func readinessCheck(ch chan bool, m Module) {
-	if reflect.DeepEqual(m.Readiness, probe.Probe{}) {
-		// update to running when no readiness setting.
-		updateModule(m.Name, ModuleRunning, nil)
-		return
+//lastProbe  return the probe results of the module, those kept in memory win over the module status unless the
+//status is newer, like written by another replica after failover. The results in memory are dropped when the status
+//was reset, like after upgrade.
+func lastProbe(key string, status *ModuleProbeStatus) ModuleProbeStatus {
+	var persisted ModuleProbeStatus
+	if status != nil {
+		persisted = *status
 	}
-	interval := m.Readiness.PeriodSeconds
-	if interval < 3 {
-		interval = 3
+	probeRecords.Lock()
+	defer probeRecords.Unlock()
+	if persisted.LastProbeTime == nil {
+		delete(probeRecords.m, key)
+		return persisted
 	}
-	failureThreshold := m.Readiness.FailureThreshold
-	if failureThreshold < 1 {
-		failureThreshold = 1
+	if s, ok := probeRecords.m[key]; ok && s.LastProbeTime != nil && s.LastProbeTime.After(persisted.LastProbeTime.Time) {
+		return s
 	}
-	successThreshold := m.Readiness.SuccessThreshold
-	if successThreshold < 1 {
-		successThreshold = 1
+	delete(probeRecords.m, key)
+	return persisted
+}
+
+//recordProbe  keep the probe results in memory, return true when the module status should be updated.
+func recordProbe(key string, status *ModuleProbeStatus, s ModuleProbeStatus, phaseChanged bool) bool {
+	probeRecords.Lock()
+	probeRecords.m[key] = s
+	probeRecords.Unlock()
+	if status == nil || status.LastProbeTime == nil {
+		return s != ModuleProbeStatus{}
 	}
-	recoverThreshold := m.Readiness.RecoverThreshold
-	if recoverThreshold < 1 {
-		recoverThreshold = 1
+	if s.LastProbeTime == nil || s.LastProbeTime.Equal(status.LastProbeTime) {
+		return false
 	}
+	return phaseChanged || s.LastResult != status.LastResult || s.TestedRevision != status.TestedRevision ||
+		s.ConsecutiveSuccesses != status.ConsecutiveSuccesses || s.ConsecutiveFailures != status.ConsecutiveFailures ||
+		s.LastProbeTime.Sub(status.LastProbeTime.Time) >= ProbeStatusRefresh
+}
 
-	successCount := 0
-	failureCount := 0
-	ticker := time.NewTicker(time.Second * time.Duration(interval))
+//DeleteProbeRecords  drop the probe results of the crd release kept in memory.
+func DeleteProbeRecords(namespace, release string) {
+	prefix := probeKey(namespace, release, "")
+	probeRecords.Lock()
+	defer probeRecords.Unlock()
+	for k := range probeRecords.m {
+		if strings.HasPrefix(k, prefix) {
+			delete(probeRecords.m, k)
+		}
+	}
+}
 
-	for {
-		select {
-		case <-ch:
-			return
-		case <-ticker.C:
-			result, out, err := Prober.RunProbeWithRetries(3, &m.Readiness)
-			mLog.V(utils.Info).Info("readiness output", "out", out)
-			if err == nil && result == probe.Success {
-				if strings.ToLower(out) == "ready" {
-					failureCount = 0
-					successCount++
-					if successCount >= m.Readiness.SuccessThreshold {
-						updateModule(m.Name, ModuleRunning, nil)
-						continue
-					}
-				}
-			}
+//appliedWorkloads  return the workloads in the objects applied by the module source.
+func (m Module) appliedWorkloads() ([]probe.WorkloadReference, error) {
+	manifest, namespace, err := manifestFromSource(m.Source, m.Name, "")
+	if err != nil {
+		return nil, err
+	}
+	return workloads.ObjectsFromManifest(manifest, namespace)
+}
 
-			if err != nil {
-				mLog.Error(err, "readiness failed", "name", m.Name)
-			} else { // result != probe.Success
-				mLog.V(utils.Warn).Info("readiness warning", "name", m.Name)
+//NextProbeAfter  return the duration until the earliest readiness probe of the modules of the crd release is due.
+//Return false when no module is waiting for readiness probe.
+func NextProbeAfter(namespace, release string, modules []Module, status []ModuleStatus) (time.Duration, bool) {
+	var next time.Duration
+	found := false
+	for _, m := range modules {
+		if reflect.DeepEqual(m.Readiness, probe.Probe{}) {
+			continue
+		}
+		for _, s := range status {
+			if s.Name != m.Name || s.State == nil {
+				continue
+			}
+			if phase := s.State.Phase(); phase != ModuleInstalling && phase != ModuleRunning &&
+				phase != ModuleRecovering {
+				continue
+			}
+			ps := lastProbe(probeKey(namespace, release, m.Name), s.Probe)
+			after := time.Until(m.nextProbeTime(s.State, ps))
+			if after < 0 {
+				after = 0
 			}
-			s := getModule(m.Name)
-			successCount = 0
-			failureCount++
-			if s == ModuleRecovering && failureCount >= recoverThreshold {
-				mLog.V(utils.Info).Info("readiness update module", "from", s, "to", ModuleAbnormal)
-				updateModule(m.Name, ModuleAbnormal, nil)
-			} else if s == ModuleRunning && failureCount >= failureThreshold {
-				mLog.V(utils.Info).Info("readiness update module", "from", s, "to", ModuleAbnormal)
-				updateModule(m.Name, ModuleAbnormal, nil)
+			if !found || after < next {
+				next = after
+				found = true
 			}
 		}
 	}
+	return next, found
 }
@@ -0,0 +1,207 @@
+package internal
+
+import (
+	"cloudnativeapp/clm/pkg/plugin"
+	"cloudnativeapp/clm/pkg/probe"
+	"cloudnativeapp/clm/pkg/prober"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func initProbeModule() Module {
+	return Module{
+		Name: "m",
+		Readiness: probe.Probe{
+			Handler: probe.Handler{
+				TCPSocket: &probe.TCPSocketAction{Host: "svc", Port: intstr.FromInt(80)},
+			},
+			PeriodSeconds: 10,
+		},
+	}
+}
+
+func TestModule_readinessCheck(t *testing.T) {
+	m := initProbeModule()
+	state := GenModuleState(ModuleRunning, "", "")
+	last := v1.Now()
+	s := ModuleProbeStatus{LastProbeTime: &last, ConsecutiveFailures: 2}
+	phase, err := m.readinessCheck(state, &s)
+	if err != nil || phase != ModuleRunning {
+		t.Errorf("probe not due should keep phase, got %s %v", phase, err)
+	}
+	if s.LastProbeTime != &last || s.ConsecutiveFailures != 2 {
+		t.Errorf("probe not due should keep probe status, got %v", s)
+	}
+
+	phase, err = Module{Name: "m"}.readinessCheck(GenModuleState(ModuleInstalling, "", ""), &s)
+	if err != nil || phase != ModuleRunning {
+		t.Errorf("module without readiness should be running, got %s %v", phase, err)
+	}
+}
+
+// A tcp probe is ready once the socket opens, as it was before the http success criteria.
+func TestModule_readinessCheckTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	old := Prober
+	Prober = prober.NewProber()
+	defer func() { Prober = old }()
+
+	m := initProbeModule()
+	m.Readiness.TCPSocket = &probe.TCPSocketAction{Host: "127.0.0.1", Port: intstr.FromInt(l.Addr().(*net.TCPAddr).Port)}
+	m.Readiness.SuccessThreshold = 1
+	// Probe due.
+	last := v1.NewTime(time.Now().Add(-time.Minute))
+	s := ModuleProbeStatus{LastProbeTime: &last}
+	phase, err := m.readinessCheck(GenModuleState(ModuleInstalling, "", ""), &s)
+	if err != nil || phase != ModuleRunning || s.LastOutput != "ready" {
+		t.Errorf("tcp probe connected should be running, got %s %v %+v", phase, err, s)
+	}
+
+	l.Close()
+	s = ModuleProbeStatus{LastProbeTime: &last}
+	phase, err = m.readinessCheck(GenModuleState(ModuleInstalling, "", ""), &s)
+	if phase == ModuleRunning || s.LastResult != probe.Failure {
+		t.Errorf("tcp probe refused should not be running, got %s %v %+v", phase, err, s)
+	}
+}
+
+func TestNextProbeAfter(t *testing.T) {
+	m := initProbeModule()
+	last := v1.NewTime(time.Now().Add(-4 * time.Second))
+	status := []ModuleStatus{{Name: "m", State: GenModuleState(ModuleRunning, "", ""),
+		Probe: &ModuleProbeStatus{LastProbeTime: &last}}}
+	after, ok := NextProbeAfter("apps", "r", []Module{m}, status)
+	if !ok || after > 6*time.Second || after < 5*time.Second {
+		t.Errorf("unexpected next probe after %v %v", after, ok)
+	}
+
+	status[0].State = GenModuleState(ModuleAbnormal, "", "")
+	if _, ok := NextProbeAfter("apps", "r", []Module{m}, status); ok {
+		t.Errorf("abnormal module should not wait for probe")
+	}
+}
+
+func TestRecordProbe(t *testing.T) {
+	key := probeKey("apps", "r", "m")
+	defer DeleteProbeRecords("apps", "r")
+	first := v1.NewTime(time.Now().Add(-time.Minute))
+	s := ModuleProbeStatus{LastProbeTime: &first, LastResult: probe.Success, ConsecutiveSuccesses: 1}
+	if !recordProbe(key, nil, s, false) {
+		t.Error("first probe not persisted")
+	}
+	persisted := s
+
+	next := v1.Now()
+	s = ModuleProbeStatus{LastProbeTime: &next, LastResult: probe.Success, ConsecutiveSuccesses: 1}
+	if recordProbe(key, &persisted, s, false) {
+		t.Error("unchanged result persisted")
+	}
+	if last := lastProbe(key, &persisted); last != s {
+		t.Errorf("probe in memory expected %v, got %v", s, last)
+	}
+	if !recordProbe(key, &persisted, s, true) {
+		t.Error("phase change not persisted")
+	}
+	s.ConsecutiveSuccesses = 2
+	if !recordProbe(key, &persisted, s, false) {
+		t.Error("counter change not persisted")
+	}
+	s.ConsecutiveSuccesses = 1
+	s.LastResult = probe.Failure
+	if !recordProbe(key, &persisted, s, false) {
+		t.Error("result change not persisted")
+	}
+	stale := v1.NewTime(next.Add(-ProbeStatusRefresh))
+	persisted.LastProbeTime = &stale
+	s.LastResult = probe.Success
+	if !recordProbe(key, &persisted, s, false) {
+		t.Error("stale probe time not refreshed")
+	}
+
+	// Reset by upgrade.
+	if last := lastProbe(key, &ModuleProbeStatus{}); last != (ModuleProbeStatus{}) {
+		t.Errorf("probe in memory not dropped, got %v", last)
+	}
+	// Newer status written by another replica wins.
+	recordProbe(key, &persisted, s, false)
+	newer := v1.NewTime(next.Add(time.Second))
+	failover := ModuleProbeStatus{LastProbeTime: &newer, LastResult: probe.Failure, ConsecutiveFailures: 1}
+	if last := lastProbe(key, &failover); last != failover {
+		t.Errorf("expected newer status %v, got %v", failover, last)
+	}
+
+	// The records of the cluster scoped crd release do not share the prefix with those of the namespace.
+	clusterKey := probeKey("", "apps", "m")
+	recordProbe(clusterKey, nil, s, false)
+	defer DeleteProbeRecords("", "apps")
+	recordProbe(key, &persisted, s, false)
+	DeleteProbeRecords("", "apps")
+	if last := lastProbe(key, &persisted); last != s {
+		t.Errorf("probe of namespace apps dropped with cluster scoped release apps, got %v", last)
+	}
+	DeleteProbeRecords("apps", "r")
+	if last := lastProbe(key, &persisted); last != persisted {
+		t.Errorf("expected persisted probe %v, got %v", persisted, last)
+	}
+}
+
+func TestModuleStatus_UpdateStatus(t *testing.T) {
+	last := v1.Now()
+	s := ModuleStatus{Name: "m", Probe: &ModuleProbeStatus{LastProbeTime: &last, ConsecutiveSuccesses: 1}}
+	if r := s.UpdateStatus(ModuleStatus{Name: "m"}); r.Probe == nil || r.Probe.ConsecutiveSuccesses != 1 {
+		t.Errorf("probe status should be kept, got %v", r.Probe)
+	}
+	if r := s.UpdateStatus(ModuleStatus{Name: "m", Probe: &ModuleProbeStatus{}}); r.Probe != nil {
+		t.Errorf("probe status should be reset, got %v", r.Probe)
+	}
+
+	started := v1.Now()
+	s = ModuleStatus{Name: "m", UpgradeStartedAt: &started, State: GenModuleState(ModuleInstalling, "", "")}
+	if r := s.UpdateStatus(ModuleStatus{Name: "m"}); r.UpgradeStartedAt == nil {
+		t.Errorf("upgrade start time should be kept before running")
+	}
+	r := s.UpdateStatus(ModuleStatus{Name: "m", State: GenModuleState(ModuleRunning, "", "")})
+	if r.UpgradeStartedAt != nil {
+		t.Errorf("upgrade start time should be cleared after running, got %v", r.UpgradeStartedAt)
+	}
+}
+
+func TestModule_CheckStatusPlan(t *testing.T) {
+	m := initProbeModule()
+	m.Readiness.TCPSocket = &probe.TCPSocketAction{Host: "127.0.0.1", Port: intstr.FromInt(1)}
+	probed := false
+	noCondition := func(ModuleCondition, string) {}
+	updateProbe := func(ModuleProbeStatus, string) { probed = true }
+	status := ModuleStatus{Name: "m", State: GenModuleState(ModuleRunning, "", "")}
+	act, phase, err := m.CheckStatus(m, status, false, false, true, noCondition, updateProbe)
+	if err != nil || act != plugin.NeedNothing || phase != ModuleRunning || probed {
+		t.Errorf("plan should not probe running module: %v %s %v %v", act, phase, err, probed)
+	}
+	status.State = GenModuleState(ModuleAbnormal, "", "")
+	act, phase, err = m.CheckStatus(m, status, false, false, true, noCondition, updateProbe)
+	if err != nil || act != plugin.NeedNothing || phase != ModuleAbnormal {
+		t.Errorf("plan should not check recover of abnormal module: %v %s %v", act, phase, err)
+	}
+}
+
+func TestModule_readinessCheckExecNamespace(t *testing.T) {
+	m := initProbeModule()
+	m.Readiness.TCPSocket = nil
+	m.Readiness.Exec = &probe.ExecAction{Namespace: "kube-system", Selector: map[string]string{"app": "web"},
+		Command: []string{"true"}}
+	m.Source.Context = &ValuesContext{Release: ReleaseContext{Name: "r", Namespace: "tenant"}}
+	last := v1.NewTime(time.Now().Add(-time.Minute))
+	s := ModuleProbeStatus{LastProbeTime: &last}
+	m.readinessCheck(GenModuleState(ModuleInstalling, "", ""), &s)
+	if s.LastResult != probe.Unknown || !strings.Contains(s.LastOutput, "not the crd release namespace") {
+		t.Errorf("exec in the other namespace should not run, got %v %q", s.LastResult, s.LastOutput)
+	}
+}
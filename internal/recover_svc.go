@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"cloudnativeapp/clm/pkg/plugin"
+	"cloudnativeapp/clm/pkg/utils"
+	"errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"time"
+)
+
+const (
+	DefaultRecoverInitialDelaySeconds = 10
+	DefaultRecoverBackoffFactor       = 2
+	DefaultRecoverMaxDelaySeconds     = 600
+)
+
+type RecoverPolicy struct {
+	// Seconds to wait after the module turns abnormal before the first recover attempt, defaults to 10 when unset.
+	// 0 recovers at once.
+	// +optional
+	InitialDelaySeconds *int32 `json:"initialDelaySeconds,omitempty"`
+	// The delay is multiplied by the factor after each recover attempt, defaults to 2.
+	// +optional
+	BackoffFactor int32 `json:"backoffFactor,omitempty"`
+	// Max seconds to wait between recover attempts, defaults to 600.
+	// +optional
+	MaxDelaySeconds int32 `json:"maxDelaySeconds,omitempty"`
+	// Max recover attempts, the module turns RecoveryExhausted after that. 0 means no limit.
+	// +optional
+	MaxAttempts int32 `json:"maxAttempts,omitempty"`
+}
+
+//Default  set the recover policy defaults.
+func (p *RecoverPolicy) Default() {
+	if p.InitialDelaySeconds == nil {
+		delay := int32(DefaultRecoverInitialDelaySeconds)
+		p.InitialDelaySeconds = &delay
+	}
+	if p.BackoffFactor == 0 {
+		p.BackoffFactor = DefaultRecoverBackoffFactor
+	}
+	if p.MaxDelaySeconds == 0 {
+		p.MaxDelaySeconds = DefaultRecoverMaxDelaySeconds
+	}
+}
+
+//Validate  check the recover policy settings.
+func (p RecoverPolicy) Validate(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if p.InitialDelaySeconds != nil && *p.InitialDelaySeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("initialDelaySeconds"), *p.InitialDelaySeconds,
+			"must be greater than or equal to 0"))
+	}
+	if p.BackoffFactor < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("backoffFactor"), p.BackoffFactor,
+			"must be greater than or equal to 0"))
+	}
+	if p.MaxDelaySeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxDelaySeconds"), p.MaxDelaySeconds,
+			"must be greater than or equal to 0"))
+	} else if p.MaxDelaySeconds > 0 && p.InitialDelaySeconds != nil && p.MaxDelaySeconds < *p.InitialDelaySeconds {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxDelaySeconds"), p.MaxDelaySeconds,
+			"must be greater than or equal to initialDelaySeconds"))
+	}
+	if p.MaxAttempts < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxAttempts"), p.MaxAttempts,
+			"must be greater than or equal to 0"))
+	}
+	return allErrs
+}
+
+//Delay  return the delay before the next recover attempt after the attempts made.
+func (p RecoverPolicy) Delay(attempts int) time.Duration {
+	p.Default()
+	max := time.Duration(p.MaxDelaySeconds) * time.Second
+	delay := time.Duration(*p.InitialDelaySeconds) * time.Second
+	for i := 0; i < attempts && delay < max; i++ {
+		delay *= time.Duration(p.BackoffFactor)
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+//nextRecoverTime  return the time when the next recover attempt of the abnormal module is due.
+func (m Module) nextRecoverTime(status ModuleStatus) time.Time {
+	var last time.Time
+	if status.LastRecoverTime != nil {
+		last = status.LastRecoverTime.Time
+	} else if status.State != nil && status.State.Abnormal != nil {
+		last = status.State.Abnormal.StartedAt.Time
+	}
+	return last.Add(m.Recover.Policy.Delay(status.RecoverAttempts))
+}
+
+//recoverCheck  return the action for the abnormal module, recover when the backoff elapsed and the recover budget
+//remains, or turn RecoveryExhausted when the budget is used up.
+func (m Module) recoverCheck(status ModuleStatus) (plugin.Action, string, error) {
+	p := m.Recover.Policy
+	if p == nil {
+		return plugin.NeedRecover, ModuleDontCare, nil
+	}
+	if p.MaxAttempts > 0 && status.RecoverAttempts >= int(p.MaxAttempts) {
+		mLog.V(utils.Warn).Info("module recover attempts exhausted", "module", m.Name,
+			"attempts", status.RecoverAttempts)
+		return plugin.NeedConvert, ModuleRecoveryExhausted, errors.New(utils.RecoveryExhausted)
+	}
+	if next := m.nextRecoverTime(status); time.Now().Before(next) {
+		mLog.V(utils.Debug).Info("module recover backoff", "module", m.Name, "next", next)
+		return plugin.NeedConvert, ModuleAbnormal, errors.New(status.State.Abnormal.Reason)
+	}
+	return plugin.NeedRecover, ModuleDontCare, nil
+}
+
+//ResetRecovery  renew the recover budget of the module, the module RecoveryExhausted turns abnormal to recover again.
+func (m *ModuleStatus) ResetRecovery() {
+	m.RecoverAttempts = 0
+	m.LastRecoverTime = nil
+	if m.State != nil && m.State.RecoveryExhausted != nil {
+		m.LastState = m.State
+		m.State = GenModuleState(ModuleAbnormal, "recovery reset", m.State.RecoveryExhausted.Reason)
+	}
+}
+
+//NextRecoverAfter  return the duration until the earliest recover attempt of the abnormal modules is due.
+//Return false when no module is waiting for recover backoff.
+func NextRecoverAfter(modules []Module, status []ModuleStatus) (time.Duration, bool) {
+	var next time.Duration
+	found := false
+	for _, m := range modules {
+		if m.Recover.Policy == nil {
+			continue
+		}
+		for _, s := range status {
+			if s.Name != m.Name || s.State.Phase() != ModuleAbnormal {
+				continue
+			}
+			after := time.Until(m.nextRecoverTime(s))
+			if after < 0 {
+				after = 0
+			}
+			if !found || after < next {
+				next = after
+				found = true
+			}
+		}
+	}
+	return next, found
+}
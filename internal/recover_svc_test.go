@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"cloudnativeapp/clm/pkg/plugin"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func TestRecoverPolicy_Delay(t *testing.T) {
+	initial := int32(5)
+	p := RecoverPolicy{InitialDelaySeconds: &initial, BackoffFactor: 3, MaxDelaySeconds: 60}
+	for attempts, want := range []time.Duration{5 * time.Second, 15 * time.Second, 45 * time.Second,
+		60 * time.Second, 60 * time.Second} {
+		if d := p.Delay(attempts); d != want {
+			t.Errorf("delay after %d attempts: want %v, got %v", attempts, want, d)
+		}
+	}
+	if d := (RecoverPolicy{}).Delay(0); d != DefaultRecoverInitialDelaySeconds*time.Second {
+		t.Errorf("default initial delay: got %v", d)
+	}
+	initial = 0
+	if d := p.Delay(2); d != 0 {
+		t.Errorf("zero initial delay should recover at once, got %v", d)
+	}
+}
+
+func TestModule_recoverCheck(t *testing.T) {
+	initial := int32(30)
+	m := Module{Name: "m", Recover: Recover{Policy: &RecoverPolicy{InitialDelaySeconds: &initial, MaxAttempts: 2}}}
+	status := ModuleStatus{Name: "m", State: GenModuleState(ModuleAbnormal, "", "probe failed")}
+	if act, phase, _ := m.recoverCheck(status); act != plugin.NeedConvert || phase != ModuleAbnormal {
+		t.Errorf("recover should back off, got %v %v", act, phase)
+	}
+	last := v1.NewTime(time.Now().Add(-time.Minute))
+	status.LastRecoverTime = &last
+	status.RecoverAttempts = 1
+	if act, _, _ := m.recoverCheck(status); act != plugin.NeedRecover {
+		t.Errorf("recover should be due, got %v", act)
+	}
+	status.RecoverAttempts = 2
+	if act, phase, err := m.recoverCheck(status); act != plugin.NeedConvert || phase != ModuleRecoveryExhausted ||
+		err == nil {
+		t.Errorf("recover attempts should be exhausted, got %v %v %v", act, phase, err)
+	}
+	if act, _, _ := (Module{Name: "m"}).recoverCheck(status); act != plugin.NeedRecover {
+		t.Errorf("module without recover policy should recover at once, got %v", act)
+	}
+}
+
+func TestModuleStatus_RecoverAttempts(t *testing.T) {
+	now := v1.Now()
+	s := ModuleStatus{Name: "m", State: GenModuleState(ModuleAbnormal, "", "probe failed")}
+	s = s.UpdateStatus(ModuleStatus{State: GenModuleState(ModuleRecovering, "", ""), RecoverAttempts: 1,
+		LastRecoverTime: &now, RecoverCount: 1})
+	s = s.UpdateStatus(ModuleStatus{State: GenModuleState(ModuleAbnormal, "", "failed"), RecoverAttempts: 1,
+		LastRecoverTime: &now})
+	if s.RecoverAttempts != 2 || s.RecoverCount != 1 || s.LastRecoverTime == nil {
+		t.Errorf("recover attempts should be counted, got %+v", s)
+	}
+	s = s.UpdateStatus(ModuleStatus{State: GenModuleState(ModuleRecoveryExhausted, "", "exhausted")})
+	s.ResetRecovery()
+	if s.RecoverAttempts != 0 || s.LastRecoverTime != nil || s.State.Phase() != ModuleAbnormal {
+		t.Errorf("recovery should be reset, got %+v", s)
+	}
+	s.RecoverAttempts = 2
+	s = s.UpdateStatus(ModuleStatus{State: GenModuleState(ModuleInstalling, "", ""),
+		RecoverAttempts: RecoverAttemptsReset})
+	if s.RecoverAttempts != 0 || s.RecoverCount != 1 {
+		t.Errorf("recover budget should be renewed after upgrade, got %+v", s)
+	}
+	s = ModuleStatus{}.UpdateStatus(ModuleStatus{Name: "m", RecoverAttempts: RecoverAttemptsReset})
+	if s.RecoverAttempts != 0 {
+		t.Errorf("recover budget reset should not be kept in status, got %+v", s)
+	}
+}
+
+func TestModule_CheckStatusRecoveryExhausted(t *testing.T) {
+	m := Module{Name: "m", Source: Source{Name: "s2"}}
+	status := ModuleStatus{Name: "m", State: GenModuleState(ModuleRecoveryExhausted, "", "")}
+	noCondition := func(ModuleCondition, string) {}
+	noProbe := func(ModuleProbeStatus, string) {}
+	if act, phase, _ := m.CheckStatus(m, status, false, false, false, noCondition, noProbe); act != plugin.NeedConvert ||
+		phase != ModuleRecoveryExhausted {
+		t.Errorf("unchanged module should stay exhausted, got %v %v", act, phase)
+	}
+	last := Module{Name: "m", Source: Source{Name: "s1"}}
+	if act, _, _ := m.CheckStatus(last, status, false, false, false, noCondition, noProbe); act != plugin.NeedUpgrade {
+		t.Errorf("changed module should upgrade to renew the recover budget, got %v", act)
+	}
+}
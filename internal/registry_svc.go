@@ -9,7 +9,9 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/yaml"
+	"strconv"
 	"strings"
 	"text/template"
 )
@@ -35,6 +37,31 @@ const (
 	Https RegistryProtocol = "https"
 )
 
+//Validate  check the registry host and protocol before pulling from it.
+func (r Registry) Validate(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	switch r.Protocol {
+	case "", Http, Https:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("protocol"), r.Protocol,
+			[]string{string(Http), string(Https)}))
+	}
+	if len(r.Host) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("host"), ""))
+		return allErrs
+	}
+	ip, port, err := decodeHost(r.Host)
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("host"), r.Host, err.Error()))
+	} else if len(ip) == 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("host"), r.Host, "ip or hostname needed"))
+	} else if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("host"), r.Host,
+			"port must be a number in the range 1 to 65535"))
+	}
+	return allErrs
+}
+
 //Pull  pull crd release from registry and apply with cli-runtime.
 func (r Registry) Pull(name, version string) (interface{}, error) {
 	dLog.V(utils.Debug).Info("start pull crd release from registry", "name", name, "version", version)
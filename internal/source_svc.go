@@ -3,14 +3,17 @@ package internal
 import (
 	"cloudnativeapp/clm/pkg/implement"
 	"cloudnativeapp/clm/pkg/utils"
-	"encoding/json"
 	"errors"
+	"fmt"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sync"
 )
 
 var sLog = ctrl.Log.WithName("source")
 
+// Sources in this namespace are resolvable from all namespaces as the cluster-scoped Sources.
+var ClusterSourceNamespace string
+
 var SourcesRegistered = struct {
 	m map[string]implement.Implement
 	sync.RWMutex
@@ -46,31 +49,40 @@ func DeleteSource(name string) bool {
 	return true
 }
 
-//GetSource   get the source configuration from clm.
-func GetSource(name string) (implement.Implement, bool) {
+//SourceKey  return the key to register the source, cluster-scoped sources and sources in ClusterSourceNamespace
+//are keyed by name.
+func SourceKey(namespace, name string) string {
+	if namespace == ClusterSourceNamespace {
+		return name
+	}
+	return utils.NamespacedKey(namespace, name)
+}
+
+//GetSource   get the source configuration from clm, the source in namespace first and then the cluster-scoped one.
+func GetSource(namespace, name string) (implement.Implement, bool) {
 	defer SourcesRegistered.RUnlock()
 	SourcesRegistered.RLock()
-	if s, ok := SourcesRegistered.m[name]; !ok {
-		sLog.V(utils.Warn).Info("source does not exist", "name", name)
-		return implement.Implement{}, false
-	} else {
+	if s, ok := SourcesRegistered.m[SourceKey(namespace, name)]; ok {
 		return s, true
 	}
+	if s, ok := SourcesRegistered.m[name]; ok {
+		return s, true
+	}
+	sLog.V(utils.Warn).Info("source does not exist", "namespace", namespace, "name", name)
+	return implement.Implement{}, false
 }
 
 func installFromSource(source Source, targetName, targetVersion string) error {
 	sLog.V(utils.Debug).Info("try to install from source", "source", source, "target name", targetName)
-	if s, ok := GetSource(source.Name); !ok {
+	if s, ok := GetSource(source.Namespace, source.Name); !ok {
 		err := errors.New(utils.ImplementNotFound)
 		sLog.Error(err, "can not find source", "sourceName", source.Name)
 		return err
 	} else {
-		var values map[string]interface{}
-		if source.Values != nil && len(source.Values.Raw) != 0 {
-			if err := json.Unmarshal(source.Values.Raw, &values); err != nil {
-				sLog.Error(err, "can not unmarshal source value", "sourceName", source.Name)
-				return err
-			}
+		values, err := source.values()
+		if err != nil {
+			sLog.Error(err, "can not get source values", "sourceName", source.Name)
+			return err
 		}
 		if err := s.Install(targetName, targetVersion, values); err != nil {
 			sLog.Error(err, "install by implement failed", "sourceName", source.Name)
@@ -83,17 +95,15 @@ func installFromSource(source Source, targetName, targetVersion string) error {
 
 func uninstallFromSource(source Source, targetName, targetVersion string) error {
 	sLog.V(utils.Debug).Info("try to uninstall from source", "source", source, "target name", targetName)
-	if s, ok := GetSource(source.Name); !ok {
+	if s, ok := GetSource(source.Namespace, source.Name); !ok {
 		err := errors.New(utils.ImplementNotFound)
 		sLog.Error(err, "can not find source", "sourceName", source.Name)
 		return err
 	} else {
-		var values map[string]interface{}
-		if source.Values != nil && len(source.Values.Raw) != 0 {
-			if err := json.Unmarshal(source.Values.Raw, &values); err != nil {
-				sLog.Error(err, "can not unmarshal source value", "sourceName", source.Name)
-				return err
-			}
+		values, err := source.values()
+		if err != nil {
+			sLog.Error(err, "can not get source values", "sourceName", source.Name)
+			return err
 		}
 		if err := s.Uninstall(targetName, targetVersion, values); err != nil {
 			return err
@@ -105,17 +115,15 @@ func uninstallFromSource(source Source, targetName, targetVersion string) error
 
 func recoverFromSource(source Source, targetName, targetVersion string) error {
 	sLog.V(utils.Debug).Info("try to recover from source", "source", source, "target name", targetName)
-	if s, ok := GetSource(source.Name); !ok {
+	if s, ok := GetSource(source.Namespace, source.Name); !ok {
 		err := errors.New(utils.ImplementNotFound)
 		sLog.Error(err, "can not find source", "sourceName", source.Name)
 		return err
 	} else {
-		var values map[string]interface{}
-		if source.Values != nil && len(source.Values.Raw) != 0 {
-			if err := json.Unmarshal(source.Values.Raw, &values); err != nil {
-				sLog.Error(err, "can not unmarshal source value", "sourceName", source.Name)
-				return err
-			}
+		values, err := source.values()
+		if err != nil {
+			sLog.Error(err, "can not get source values", "sourceName", source.Name)
+			return err
 		}
 		if err := s.Recover(targetName, targetVersion, values); err != nil {
 			sLog.Error(err, "recover by implement failed", "sourceName", source.Name)
@@ -126,19 +134,101 @@ func recoverFromSource(source Source, targetName, targetVersion string) error {
 	}
 }
 
+func rollbackFromSource(source Source, targetName, targetVersion string) error {
+	sLog.V(utils.Debug).Info("try to rollback from source", "source", source, "target name", targetName)
+	if s, ok := GetSource(source.Namespace, source.Name); !ok {
+		err := errors.New(utils.ImplementNotFound)
+		sLog.Error(err, "can not find source", "sourceName", source.Name)
+		return err
+	} else {
+		values, err := source.values()
+		if err != nil {
+			sLog.Error(err, "can not get source values", "sourceName", source.Name)
+			return err
+		}
+		if err := s.Rollback(targetName, targetVersion, values); err != nil {
+			sLog.Error(err, "rollback by implement failed", "sourceName", source.Name)
+			return err
+		} else {
+			return nil
+		}
+	}
+}
+
+func planFromSource(source Source, targetName, targetVersion string) (string, error) {
+	sLog.V(utils.Debug).Info("try to plan from source", "source", source, "target name", targetName)
+	if s, ok := GetSource(source.Namespace, source.Name); !ok {
+		err := errors.New(utils.ImplementNotFound)
+		sLog.Error(err, "can not find source", "sourceName", source.Name)
+		return "", err
+	} else {
+		values, err := source.values()
+		if err != nil {
+			sLog.Error(err, "can not get source values", "sourceName", source.Name)
+			return "", err
+		}
+		return s.Plan(targetName, targetVersion, values)
+	}
+}
+
+//manifestFromSource  return the manifest of the objects applied by the source and the namespace of the objects
+//without one.
+func manifestFromSource(source Source, targetName, targetVersion string) (string, string, error) {
+	sLog.V(utils.Debug).Info("try to get manifest from source", "source", source, "target name", targetName)
+	s, ok := GetSource(source.Namespace, source.Name)
+	if !ok {
+		err := errors.New(utils.ImplementNotFound)
+		sLog.Error(err, "can not find source", "sourceName", source.Name)
+		return "", "", err
+	}
+	values, err := source.values()
+	if err != nil {
+		sLog.Error(err, "can not get source values", "sourceName", source.Name)
+		return "", "", err
+	}
+	namespace, _ := values["namespace"].(string)
+	if len(namespace) == 0 {
+		namespace = "default"
+	}
+	manifest, err := s.Manifest(targetName, targetVersion, values)
+	return manifest, namespace, err
+}
+
+//releaseFromSource  return the name and namespace of the helm release installed by the source.
+func releaseFromSource(source Source) (string, string, error) {
+	s, ok := GetSource(source.Namespace, source.Name)
+	if !ok {
+		err := errors.New(utils.ImplementNotFound)
+		sLog.Error(err, "can not find source", "sourceName", source.Name)
+		return "", "", err
+	}
+	if s.Type() != implement.HelmType {
+		return "", "", fmt.Errorf("source %s is not a helm source", source.Name)
+	}
+	values, err := source.values()
+	if err != nil {
+		sLog.Error(err, "can not get source values", "sourceName", source.Name)
+		return "", "", err
+	}
+	releaseName, _ := values["releaseName"].(string)
+	if len(releaseName) == 0 {
+		return "", "", fmt.Errorf("source %s values have no releaseName", source.Name)
+	}
+	namespace, _ := values["namespace"].(string)
+	return releaseName, namespace, nil
+}
+
 func upgradeFromSource(source Source, targetName, targetVersion string) error {
 	sLog.V(utils.Debug).Info("try to upgrade from source", "source", source, "target name", targetName)
-	if s, ok := GetSource(source.Name); !ok {
+	if s, ok := GetSource(source.Namespace, source.Name); !ok {
 		err := errors.New(utils.ImplementNotFound)
 		sLog.Error(err, "can not find source", "sourceName", source.Name)
 		return err
 	} else {
-		var values map[string]interface{}
-		if source.Values != nil && len(source.Values.Raw) != 0 {
-			if err := json.Unmarshal(source.Values.Raw, &values); err != nil {
-				sLog.Error(err, "can not unmarshal source value", "sourceName", source.Name)
-				return err
-			}
+		values, err := source.values()
+		if err != nil {
+			sLog.Error(err, "can not get source values", "sourceName", source.Name)
+			return err
 		}
 		if err := s.Upgrade(targetName, targetVersion, values); err != nil {
 			sLog.Error(err, "upgrade by implement failed", "sourceName", source.Name)
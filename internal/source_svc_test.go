@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"cloudnativeapp/clm/pkg/implement"
+	"cloudnativeapp/clm/pkg/implement/helm"
+	"testing"
+)
+
+func TestGetSource(t *testing.T) {
+	ClusterSourceNamespace = "clm-system"
+	defer func() { ClusterSourceNamespace = "" }()
+	shared := implement.Implement{Helm: &helm.Implement{}}
+	local := implement.Implement{Helm: &helm.Implement{Wait: true}}
+	AddSource(SourceKey("clm-system", "shared"), shared)
+	AddSource(SourceKey("", "shared-local"), shared)
+	AddSource(SourceKey("team-a", "shared-local"), local)
+	defer func() {
+		DeleteSource("shared")
+		DeleteSource("shared-local")
+		DeleteSource("team-a/shared-local")
+	}()
+
+	if s, ok := GetSource("team-b", "shared"); !ok || s.Helm == nil || s.Helm.Wait {
+		t.Errorf("source in cluster source namespace should be resolved from all namespaces")
+	}
+	if s, ok := GetSource("team-a", "shared-local"); !ok || s.Helm == nil || !s.Helm.Wait {
+		t.Errorf("source in namespace should be resolved first")
+	}
+	if s, ok := GetSource("team-b", "shared-local"); !ok || s.Helm == nil || s.Helm.Wait {
+		t.Errorf("cluster-scoped source should be resolved when absent in namespace")
+	}
+	if _, ok := GetSource("team-b", "absent"); ok {
+		t.Errorf("absent source should not be resolved")
+	}
+}
@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"bytes"
+	"cloudnativeapp/clm/pkg/check/precheck"
+	"cloudnativeapp/clm/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"strings"
+	"text/template"
+)
+
+// ValuesContext is the context the module values and outputs are rendered with, set by controller.
+type ValuesContext struct {
+	// The crd release of the module.
+	Release ReleaseContext `json:"release"`
+	// Parameters of the crd release.
+	// +optional
+	Parameters *runtime.RawExtension `json:"parameters,omitempty"`
+	// Outputs exported by the modules of the crd release, by module name.
+	// +optional
+	Outputs map[string]map[string]string `json:"outputs,omitempty"`
+}
+
+type ReleaseContext struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   string `json:"version"`
+}
+
+// Data the templates in module values are rendered with.
+type valuesData struct {
+	Release    ReleaseContext
+	Cluster    clusterData
+	Parameters map[string]interface{}
+	Modules    map[string]map[string]string
+}
+
+// Cluster info, queried only when the templates refer to it.
+type clusterData struct{}
+
+//Version  return the git version of the kubernetes server, like v1.18.8.
+func (clusterData) Version() (string, error) {
+	return precheck.ServerVersion()
+}
+
+//Templated  return true when the source values have templates to render.
+func (s Source) Templated() bool {
+	return s.Values != nil && bytes.Contains(s.Values.Raw, []byte("{{"))
+}
+
+//empty  return true when no source configured.
+func (s Source) empty() bool {
+	return len(s.Name) == 0 && s.Values == nil
+}
+
+//renderValues  unmarshal the source values and render the templates in the string values with the values context.
+func (s Source) renderValues() (map[string]interface{}, error) {
+	var values map[string]interface{}
+	if s.Values == nil || len(s.Values.Raw) == 0 {
+		return values, nil
+	}
+	if err := json.Unmarshal(s.Values.Raw, &values); err != nil {
+		return nil, err
+	}
+	if !s.Templated() {
+		return values, nil
+	}
+	data, err := s.Context.data()
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := renderValue(values, data)
+	if err != nil {
+		return nil, err
+	}
+	sLog.V(utils.Debug).Info("source values rendered", "sourceName", s.Name, "values", rendered)
+	return rendered.(map[string]interface{}), nil
+}
+
+//renderOutputs  render the outputs exported by the module, an empty map when none.
+func (m Module) renderOutputs() (map[string]string, error) {
+	outputs := make(map[string]string, len(m.Outputs))
+	if len(m.Outputs) == 0 {
+		return outputs, nil
+	}
+	data, err := m.Source.Context.data()
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range m.Outputs {
+		if outputs[k], err = renderString(v, data); err != nil {
+			return nil, fmt.Errorf("output %s: %v", k, err)
+		}
+	}
+	return outputs, nil
+}
+
+//outputs  return the outputs rendered, an empty map when the rendering fails so the stale outputs are not kept.
+func (m Module) outputs() map[string]string {
+	outputs, err := m.renderOutputs()
+	if err != nil {
+		mLog.Error(err, "render module outputs failed", "module", m.Name)
+		return map[string]string{}
+	}
+	return outputs
+}
+
+//validateTemplates  check the templates in the source values and outputs parse.
+func (m Module) validateTemplates(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if m.Source.Templated() {
+		var values interface{}
+		if err := json.Unmarshal(m.Source.Values.Raw, &values); err == nil {
+			allErrs = append(allErrs, validateValue(values, fldPath.Child("source", "values"))...)
+		}
+	}
+	for k, v := range m.Outputs {
+		if _, err := parseTemplate(v); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("outputs").Key(k), v, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+func validateValue(v interface{}, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	switch t := v.(type) {
+	case string:
+		if _, err := parseTemplate(t); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath, t, err.Error()))
+		}
+	case map[string]interface{}:
+		for k, i := range t {
+			allErrs = append(allErrs, validateValue(i, fldPath.Key(k))...)
+		}
+	case []interface{}:
+		for k, i := range t {
+			allErrs = append(allErrs, validateValue(i, fldPath.Index(k))...)
+		}
+	}
+	return allErrs
+}
+
+func (c *ValuesContext) data() (*valuesData, error) {
+	data := &valuesData{}
+	if c == nil {
+		return data, nil
+	}
+	data.Release = c.Release
+	data.Modules = c.Outputs
+	if c.Parameters != nil && len(c.Parameters.Raw) != 0 {
+		if err := json.Unmarshal(c.Parameters.Raw, &data.Parameters); err != nil {
+			return nil, fmt.Errorf("invalid parameters: %v", err)
+		}
+	}
+	return data, nil
+}
+
+//renderValue  render the templates in the strings of the value recursively.
+func renderValue(v interface{}, data *valuesData) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		return renderString(t, data)
+	case map[string]interface{}:
+		for k, i := range t {
+			r, err := renderValue(i, data)
+			if err != nil {
+				return nil, err
+			}
+			t[k] = r
+		}
+		return t, nil
+	case []interface{}:
+		for k, i := range t {
+			r, err := renderValue(i, data)
+			if err != nil {
+				return nil, err
+			}
+			t[k] = r
+		}
+		return t, nil
+	}
+	return v, nil
+}
+
+func renderString(s string, data *valuesData) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	tmpl, err := parseTemplate(s)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func parseTemplate(s string) (*template.Template, error) {
+	return template.New("values").Option("missingkey=error").Parse(s)
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"reflect"
+	"testing"
+)
+
+func valuesContextForTest() *ValuesContext {
+	return &ValuesContext{
+		Release:    ReleaseContext{Name: "app", Namespace: "apps", Version: "1.2.0"},
+		Parameters: &runtime.RawExtension{Raw: []byte(`{"replicas":3,"db":{"host":"mysql"}}`)},
+		Outputs:    map[string]map[string]string{"db": {"endpoint": "mysql.apps:3306"}},
+	}
+}
+
+func TestSource_RenderValues(t *testing.T) {
+	s := Source{
+		Name: "chart",
+		Values: &runtime.RawExtension{Raw: []byte(`{"name":"{{ .Release.Name }}-web","replicas":2,` +
+			`"env":["{{ .Release.Namespace }}","{{ .Parameters.db.host }}"],` +
+			`"db":{"endpoint":"{{ index .Modules.db \"endpoint\" }}"}}`)},
+		Context: valuesContextForTest(),
+	}
+	if !s.Templated() {
+		t.Fatal("templated values not detected")
+	}
+	values, err := s.renderValues()
+	if err != nil {
+		t.Fatalf("render values error: %v", err)
+	}
+	expected := map[string]interface{}{
+		"name":     "app-web",
+		"replicas": float64(2),
+		"env":      []interface{}{"apps", "mysql"},
+		"db":       map[string]interface{}{"endpoint": "mysql.apps:3306"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+
+	s.Values = &runtime.RawExtension{Raw: []byte(`{"host":"{{ .Parameters.missing }}"}`)}
+	if _, err := s.renderValues(); err == nil {
+		t.Error("missing key rendered")
+	}
+
+	s = Source{Name: "chart", Values: &runtime.RawExtension{Raw: []byte(`{"a":"b"}`)}}
+	if s.Templated() {
+		t.Error("plain values taken as templated")
+	}
+	if values, err := s.renderValues(); err != nil || values["a"] != "b" {
+		t.Errorf("plain values rendered %v %v", values, err)
+	}
+}
+
+func TestModule_RenderOutputs(t *testing.T) {
+	m := Module{
+		Name: "web",
+		Source: Source{
+			Context: valuesContextForTest(),
+		},
+		Outputs: map[string]string{
+			"url":  "http://{{ .Release.Name }}-web.{{ .Release.Namespace }}",
+			"port": "8080",
+		},
+	}
+	outputs, err := m.renderOutputs()
+	if err != nil {
+		t.Fatalf("render outputs error: %v", err)
+	}
+	expected := map[string]string{"url": "http://app-web.apps", "port": "8080"}
+	if !reflect.DeepEqual(outputs, expected) {
+		t.Errorf("expected %v, got %v", expected, outputs)
+	}
+
+	m.Outputs = map[string]string{"url": "{{ .Modules.none.url }}"}
+	if _, err := m.renderOutputs(); err == nil {
+		t.Error("missing module output rendered")
+	}
+	if outputs := m.outputs(); len(outputs) != 0 {
+		t.Errorf("outputs kept on render error: %v", outputs)
+	}
+}
+
+func TestModule_ValidateTemplates(t *testing.T) {
+	m := Module{
+		Name:    "web",
+		Source:  Source{Values: &runtime.RawExtension{Raw: []byte(`{"a":["{{ .Release.Name }}","{{ .Bad "]}`)}},
+		Outputs: map[string]string{"url": "{{ .Release.Name }", "ok": "{{ .Release.Name }}"},
+	}
+	if errs := m.validateTemplates(nil); len(errs) != 2 {
+		t.Errorf("expected 2 errors, got %v", errs)
+	}
+}
@@ -0,0 +1,268 @@
+package internal
+
+import (
+	"cloudnativeapp/clm/pkg/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	ValuesFromSecret    = "Secret"
+	ValuesFromConfigMap = "ConfigMap"
+)
+
+// ValuesReference refers to the data of a secret or config map merged into the source values.
+type ValuesReference struct {
+	// Kind of the object, Secret or ConfigMap.
+	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	Kind string `json:"kind"`
+	// Name of the object, in the namespace of the crd release.
+	Name string `json:"name"`
+	// Key of the data to take. The data of the key is a yaml document merged into the values, or the string value
+	// at targetPath when set. All the data is taken as a map of string values when omitted.
+	// +optional
+	Key string `json:"key,omitempty"`
+	// Dot separated path in the values to put the data at, e.g. chartValues.auth.password. The root when omitted.
+	// +optional
+	TargetPath string `json:"targetPath,omitempty"`
+	// Ignore the object or the key not found.
+	// +optional
+	Optional bool `json:"optional,omitempty"`
+}
+
+//valuesClient  return the client to read the objects the values are referred from, created from the controller
+//config on the first use.
+var valuesClient = func() func() (kubernetes.Interface, error) {
+	var once sync.Once
+	var client kubernetes.Interface
+	var err error
+	return func() (kubernetes.Interface, error) {
+		once.Do(func() {
+			config, e := ctrl.GetConfig()
+			if e != nil {
+				err = e
+				return
+			}
+			client, err = kubernetes.NewForConfig(config)
+		})
+		return client, err
+	}
+}()
+
+//String  return the reference like Secret/name[key].
+func (r ValuesReference) String() string {
+	s := r.Kind + "/" + r.Name
+	if len(r.Key) > 0 {
+		s += "[" + r.Key + "]"
+	}
+	return s
+}
+
+//Validate  check the reference settings.
+func (r ValuesReference) Validate(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if r.Kind != ValuesFromSecret && r.Kind != ValuesFromConfigMap {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("kind"), r.Kind,
+			[]string{ValuesFromSecret, ValuesFromConfigMap}))
+	}
+	if len(r.Name) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("name"), ""))
+	}
+	if len(r.TargetPath) > 0 {
+		for _, p := range strings.Split(r.TargetPath, ".") {
+			if len(p) == 0 {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child("targetPath"), r.TargetPath,
+					"empty path element"))
+				break
+			}
+		}
+	}
+	return allErrs
+}
+
+//data  return the data of the object referred, nil when it is optional and not found.
+func (r ValuesReference) data(client kubernetes.Interface, namespace string) (map[string]string, error) {
+	data := make(map[string]string)
+	var err error
+	switch r.Kind {
+	case ValuesFromSecret:
+		secret, e := client.CoreV1().Secrets(namespace).Get(context.Background(), r.Name, metav1.GetOptions{})
+		if err = e; err == nil {
+			for k, v := range secret.Data {
+				data[k] = string(v)
+			}
+		}
+	case ValuesFromConfigMap:
+		cm, e := client.CoreV1().ConfigMaps(namespace).Get(context.Background(), r.Name, metav1.GetOptions{})
+		if err = e; err == nil {
+			for k, v := range cm.Data {
+				data[k] = v
+			}
+			for k, v := range cm.BinaryData {
+				data[k] = string(v)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported values reference kind %s", r.Kind)
+	}
+	if err != nil {
+		if apierrors.IsNotFound(err) && r.Optional {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get %s: %v", r.String(), err)
+	}
+	if len(r.Key) == 0 {
+		return data, nil
+	}
+	v, ok := data[r.Key]
+	if !ok {
+		if r.Optional {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("key %s not found in %s/%s", r.Key, r.Kind, r.Name)
+	}
+	return map[string]string{r.Key: v}, nil
+}
+
+//mergeInto  merge the data referred into the values.
+func (r ValuesReference) mergeInto(values map[string]interface{}, data map[string]string) error {
+	var v interface{}
+	if len(r.Key) == 0 {
+		m := make(map[string]interface{}, len(data))
+		for k, i := range data {
+			m[k] = i
+		}
+		v = m
+	} else if len(r.TargetPath) > 0 {
+		v = data[r.Key]
+	} else {
+		var m map[string]interface{}
+		if err := yaml.Unmarshal([]byte(data[r.Key]), &m); err != nil {
+			return fmt.Errorf("%s is not a yaml map: %v", r.String(), err)
+		}
+		v = m
+	}
+	if len(r.TargetPath) == 0 {
+		mergeValues(values, v.(map[string]interface{}))
+		return nil
+	}
+	path := strings.Split(r.TargetPath, ".")
+	parent := values
+	for _, p := range path[:len(path)-1] {
+		child, ok := parent[p].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			parent[p] = child
+		}
+		parent = child
+	}
+	last := path[len(path)-1]
+	if m, ok := v.(map[string]interface{}); ok {
+		if dst, ok := parent[last].(map[string]interface{}); ok {
+			mergeValues(dst, m)
+			return nil
+		}
+	}
+	parent[last] = v
+	return nil
+}
+
+//mergeValues  merge src into dst recursively, the values of src win.
+func mergeValues(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if m, ok := v.(map[string]interface{}); ok {
+			if d, ok := dst[k].(map[string]interface{}); ok {
+				mergeValues(d, m)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+//values  return the source values rendered, with the data referred by valuesFrom merged in order, the inline
+//values win.
+func (s Source) values() (map[string]interface{}, error) {
+	values, err := s.renderValues()
+	if err != nil || len(s.ValuesFrom) == 0 {
+		return values, err
+	}
+	client, err := valuesClient()
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]interface{})
+	for _, r := range s.ValuesFrom {
+		data, err := r.data(client, s.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			sLog.V(utils.Debug).Info("optional values reference not found", "sourceName", s.Name,
+				"reference", r.String())
+			continue
+		}
+		if err := r.mergeInto(merged, data); err != nil {
+			return nil, err
+		}
+	}
+	mergeValues(merged, values)
+	return merged, nil
+}
+
+//valuesFromChecksum  return the checksum of the data referred by valuesFrom, empty when none referred.
+func (s Source) valuesFromChecksum() (string, error) {
+	if len(s.ValuesFrom) == 0 {
+		return "", nil
+	}
+	client, err := valuesClient()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, r := range s.ValuesFrom {
+		data, err := r.data(client, s.Namespace)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\n", r.String())
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(h, "%s=%d:%s\n", k, len(data[k]), data[k])
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+//valuesChecksum  return the checksum of the data referred by the source valuesFrom, empty when it fails.
+func (m Module) valuesChecksum() string {
+	checksum, err := m.Source.valuesFromChecksum()
+	if err != nil {
+		mLog.Error(err, "checksum values referred failed", "module", m.Name)
+	}
+	return checksum
+}
+
+//valuesFromChanged  return true when the data referred by the source valuesFrom changed since the module applied.
+func (m Module) valuesFromChanged(status ModuleStatus) bool {
+	if len(m.Source.ValuesFrom) == 0 || len(status.ValuesChecksum) == 0 {
+		return false
+	}
+	checksum := m.valuesChecksum()
+	return len(checksum) > 0 && checksum != status.ValuesChecksum
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"reflect"
+	"testing"
+)
+
+//fakeValuesClient  replace the values client with a fake one, return it and the function to restore.
+func fakeValuesClient(objects ...runtime.Object) (*fake.Clientset, func()) {
+	client := fake.NewSimpleClientset(objects...)
+	old := valuesClient
+	valuesClient = func() (kubernetes.Interface, error) {
+		return client, nil
+	}
+	return client, func() {
+		valuesClient = old
+	}
+}
+
+func TestSource_ValuesFrom(t *testing.T) {
+	_, restore := fakeValuesClient(
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "apps"},
+			Data: map[string][]byte{"password": []byte("s3cret"), "user": []byte("admin")}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "values", Namespace: "apps"},
+			Data: map[string]string{"values.yaml": "chartValues:\n  replicas: 2\n  image:\n    tag: \"1.0\"\n"}},
+	)
+	defer restore()
+	s := Source{
+		Name:      "chart",
+		Namespace: "apps",
+		Values: &runtime.RawExtension{Raw: []byte(`{"releaseName":"web",` +
+			`"chartValues":{"image":{"repository":"nginx"},"replicas":3}}`)},
+		ValuesFrom: []ValuesReference{
+			{Kind: ValuesFromConfigMap, Name: "values", Key: "values.yaml"},
+			{Kind: ValuesFromSecret, Name: "db", Key: "password", TargetPath: "chartValues.db.password"},
+			{Kind: ValuesFromSecret, Name: "db", TargetPath: "chartValues.auth"},
+			{Kind: ValuesFromSecret, Name: "absent", Optional: true},
+			{Kind: ValuesFromSecret, Name: "db", Key: "absent", Optional: true},
+		},
+	}
+	values, err := s.values()
+	if err != nil {
+		t.Fatalf("get values error: %v", err)
+	}
+	expected := map[string]interface{}{
+		"releaseName": "web",
+		"chartValues": map[string]interface{}{
+			"replicas": float64(3),
+			"image":    map[string]interface{}{"repository": "nginx", "tag": "1.0"},
+			"db":       map[string]interface{}{"password": "s3cret"},
+			"auth":     map[string]interface{}{"password": "s3cret", "user": "admin"},
+		},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+
+	s.ValuesFrom = []ValuesReference{{Kind: ValuesFromSecret, Name: "absent"}}
+	if _, err := s.values(); err == nil {
+		t.Error("absent secret not reported")
+	}
+	s.ValuesFrom = []ValuesReference{{Kind: ValuesFromSecret, Name: "db", Key: "absent"}}
+	if _, err := s.values(); err == nil {
+		t.Error("absent key not reported")
+	}
+	s.ValuesFrom = []ValuesReference{{Kind: ValuesFromSecret, Name: "db", Key: "password"}}
+	if _, err := s.values(); err == nil {
+		t.Error("non map data merged at the root")
+	}
+}
+
+func TestModule_ValuesFromChanged(t *testing.T) {
+	client, restore := fakeValuesClient(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "apps"},
+		Data: map[string][]byte{"password": []byte("s3cret")}})
+	defer restore()
+	m := Module{Name: "web", Source: Source{Name: "chart", Namespace: "apps",
+		ValuesFrom: []ValuesReference{{Kind: ValuesFromSecret, Name: "db", Key: "password"}}}}
+	checksum := m.valuesChecksum()
+	if len(checksum) == 0 {
+		t.Fatal("empty checksum")
+	}
+	status := ModuleStatus{Name: "web", ValuesChecksum: checksum}
+	if m.valuesFromChanged(status) {
+		t.Error("unchanged data taken as changed")
+	}
+	if m.valuesFromChanged(ModuleStatus{Name: "web"}) {
+		t.Error("changed without checksum recorded")
+	}
+	secret, _ := client.CoreV1().Secrets("apps").Get(context.Background(), "db", metav1.GetOptions{})
+	secret.Data["password"] = []byte("changed")
+	_, err := client.CoreV1().Secrets("apps").Update(context.Background(), secret, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.valuesFromChanged(status) {
+		t.Error("changed data not detected")
+	}
+}
+
+func TestValuesReference_Validate(t *testing.T) {
+	if errs := (ValuesReference{Kind: ValuesFromSecret, Name: "db", TargetPath: "a.b"}).Validate(nil); len(errs) > 0 {
+		t.Errorf("valid reference rejected: %v", errs)
+	}
+	if errs := (ValuesReference{Kind: "Pod", TargetPath: "a..b"}).Validate(nil); len(errs) != 3 {
+		t.Errorf("expected 3 errors, got %v", errs)
+	}
+}
@@ -78,7 +78,19 @@ func (in *Module) DeepCopyInto(out *Module) {
 	in.PreCheck.DeepCopyInto(&out.PreCheck)
 	in.Source.DeepCopyInto(&out.Source)
 	in.Readiness.DeepCopyInto(&out.Readiness)
-	out.Recover = in.Recover
+	in.Recover.DeepCopyInto(&out.Recover)
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Module.
@@ -107,6 +119,25 @@ func (in *ModuleCondition) DeepCopy() *ModuleCondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModuleProbeStatus) DeepCopyInto(out *ModuleProbeStatus) {
+	*out = *in
+	if in.LastProbeTime != nil {
+		in, out := &in.LastProbeTime, &out.LastProbeTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModuleProbeStatus.
+func (in *ModuleProbeStatus) DeepCopy() *ModuleProbeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ModuleProbeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ModuleState) DeepCopyInto(out *ModuleState) {
 	*out = *in
@@ -130,6 +161,11 @@ func (in *ModuleState) DeepCopyInto(out *ModuleState) {
 		*out = new(ModuleStateInternal)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.RecoveryExhausted != nil {
+		in, out := &in.RecoveryExhausted, &out.RecoveryExhausted
+		*out = new(ModuleStateInternal)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Terminated != nil {
 		in, out := &in.Terminated, &out.Terminated
 		*out = new(ModuleStateInternal)
@@ -173,6 +209,10 @@ func (in *ModuleStatus) DeepCopyInto(out *ModuleStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.LastRecoverTime != nil {
+		in, out := &in.LastRecoverTime, &out.LastRecoverTime
+		*out = (*in).DeepCopy()
+	}
 	if in.State != nil {
 		in, out := &in.State, &out.State
 		*out = new(ModuleState)
@@ -183,6 +223,22 @@ func (in *ModuleStatus) DeepCopyInto(out *ModuleStatus) {
 		*out = new(ModuleState)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Probe != nil {
+		in, out := &in.Probe, &out.Probe
+		*out = new(ModuleProbeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UpgradeStartedAt != nil {
+		in, out := &in.UpgradeStartedAt, &out.UpgradeStartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModuleStatus.
@@ -195,10 +251,30 @@ func (in *ModuleStatus) DeepCopy() *ModuleStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlanAction) DeepCopyInto(out *PlanAction) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlanAction.
+func (in *PlanAction) DeepCopy() *PlanAction {
+	if in == nil {
+		return nil
+	}
+	out := new(PlanAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Recover) DeepCopyInto(out *Recover) {
 	*out = *in
 	out.Recover = in.Recover
+	if in.Policy != nil {
+		in, out := &in.Policy, &out.Policy
+		*out = new(RecoverPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Recover.
@@ -211,6 +287,26 @@ func (in *Recover) DeepCopy() *Recover {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RecoverPolicy) DeepCopyInto(out *RecoverPolicy) {
+	*out = *in
+	if in.InitialDelaySeconds != nil {
+		in, out := &in.InitialDelaySeconds, &out.InitialDelaySeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecoverPolicy.
+func (in *RecoverPolicy) DeepCopy() *RecoverPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RecoverPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Registry) DeepCopyInto(out *Registry) {
 	*out = *in
@@ -233,6 +329,47 @@ func (in *Registry) DeepCopy() *Registry {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReleaseContext) DeepCopyInto(out *ReleaseContext) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReleaseContext.
+func (in *ReleaseContext) DeepCopy() *ReleaseContext {
+	if in == nil {
+		return nil
+	}
+	out := new(ReleaseContext)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReleasePlan) DeepCopyInto(out *ReleasePlan) {
+	*out = *in
+	in.PlannedAt.DeepCopyInto(&out.PlannedAt)
+	if in.Dependencies != nil {
+		in, out := &in.Dependencies, &out.Dependencies
+		*out = make([]PlanAction, len(*in))
+		copy(*out, *in)
+	}
+	if in.Modules != nil {
+		in, out := &in.Modules, &out.Modules
+		*out = make([]PlanAction, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReleasePlan.
+func (in *ReleasePlan) DeepCopy() *ReleasePlan {
+	if in == nil {
+		return nil
+	}
+	out := new(ReleasePlan)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Source) DeepCopyInto(out *Source) {
 	*out = *in
@@ -241,6 +378,16 @@ func (in *Source) DeepCopyInto(out *Source) {
 		*out = new(runtime.RawExtension)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ValuesFrom != nil {
+		in, out := &in.ValuesFrom, &out.ValuesFrom
+		*out = make([]ValuesReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Context != nil {
+		in, out := &in.Context, &out.Context
+		*out = new(ValuesContext)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Source.
@@ -252,3 +399,56 @@ func (in *Source) DeepCopy() *Source {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValuesContext) DeepCopyInto(out *ValuesContext) {
+	*out = *in
+	out.Release = in.Release
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make(map[string]map[string]string, len(*in))
+		for key, val := range *in {
+			var outVal map[string]string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValuesContext.
+func (in *ValuesContext) DeepCopy() *ValuesContext {
+	if in == nil {
+		return nil
+	}
+	out := new(ValuesContext)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValuesReference) DeepCopyInto(out *ValuesReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValuesReference.
+func (in *ValuesReference) DeepCopy() *ValuesReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ValuesReference)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -18,6 +18,7 @@ package main
 
 import (
 	"cloudnativeapp/clm/internal"
+	"cloudnativeapp/clm/pkg/download"
 	"cloudnativeapp/clm/pkg/prober"
 	"flag"
 	zap1 "go.uber.org/zap"
@@ -53,15 +54,30 @@ func init() {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var enableWebhook bool
 	var logToFile bool
 	var logLevel string
 	var logFilePath string
 	var logFileMaxSize int
 	var logFileMaxBackups int
+	flag.StringVar(&internal.ClusterSourceNamespace, "cluster-source-namespace", "",
+		"Namespace of the sources shared by crd releases in all namespaces. "+
+			"Sources without namespace are always shared.")
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableWebhook, "enable-webhook", false,
+		"Enable the validating and defaulting webhooks of CRDRelease and Source. "+
+			"Serving certificates should be mounted to the webhook server.")
+	flag.StringVar(&download.DefaultCache.Dir, "download-cache-dir", download.DefaultCache.Dir,
+		"The directory the charts are downloaded to.")
+	flag.Int64Var(&download.DefaultCache.MaxSize, "download-cache-maxsize", download.DefaultCache.MaxSize,
+		"The max bytes of the charts kept in download cache, the least recently used evicted beyond. 0 means no limit.")
+	flag.DurationVar(&download.DefaultCache.MaxAge, "download-cache-maxage", download.DefaultCache.MaxAge,
+		"The charts unused longer are evicted from download cache. 0 means no limit.")
+	flag.DurationVar(&download.DefaultCache.Timeout, "download-timeout", download.DefaultCache.Timeout,
+		"The timeout of a chart download.")
 	// logger related setting
 	flag.BoolVar(&logToFile, "enable-log-file", false, "Enable to write log to file.")
 	flag.StringVar(&logLevel, "log-level", "info", "The log level. Available: info, debug")
@@ -119,6 +135,16 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Source")
 		os.Exit(1)
 	}
+	if enableWebhook {
+		if err = (&clmv1beta1.CRDRelease{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "CRDRelease")
+			os.Exit(1)
+		}
+		if err = (&clmv1beta1.Source{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Source")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	controllers.MGRClient = mgr.GetClient()
@@ -4,7 +4,9 @@ import (
 	"cloudnativeapp/clm/pkg/check/resource"
 	"cloudnativeapp/clm/pkg/utils"
 	"github.com/go-logr/logr"
+	"fmt"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 type Condition struct {
@@ -25,34 +27,48 @@ const (
 	Import Strategy = "Import"
 )
 
-//Check  do condition check
-func (c Condition) Check(log logr.Logger) (bool, error) {
+//Check  do condition check, return the failing clause when the condition is not met.
+func (c Condition) Check(log logr.Logger) (bool, string, error) {
 	log.V(utils.Debug).Info("try to check condition", "condition", c)
-	if len(c.ResourceNotExist) != 0 {
-		for _, r := range c.ResourceNotExist {
-			if ok, err := r.Check(log, false); err != nil {
-				log.Error(err, "condition check error")
-				return false, err
-			} else if !ok {
-				log.Error(errors.New("resource exists"), "condition check failed",
-					"name", r.Name, "type", r.Type)
-				return false, nil
-			}
+	for i, r := range c.ResourceNotExist {
+		if ok, reason, err := r.Check(log, false); err != nil {
+			log.Error(err, "condition check error")
+			return false, "", err
+		} else if !ok {
+			clause := fmt.Sprintf("resourceNotExist[%d] %s: %s", i, r.String(), reason)
+			log.Error(errors.New("resource exists"), "condition check failed", "clause", clause)
+			return false, clause, nil
 		}
 	}
 
-	if len(c.ResourceExist) != 0 {
-		for _, r := range c.ResourceExist {
-			if ok, err := r.Check(log, true); err != nil {
-				log.Error(err, "condition check error")
-				return false, err
-			} else if !ok {
-				log.Error(errors.New("resource does not exist"), "condition check failed",
-					"name", r.Name, "type", r.Type)
-				return false, nil
-			}
+	for i, r := range c.ResourceExist {
+		if ok, reason, err := r.Check(log, true); err != nil {
+			log.Error(err, "condition check error")
+			return false, "", err
+		} else if !ok {
+			clause := fmt.Sprintf("resourceExist[%d] %s: %s", i, r.String(), reason)
+			log.Error(errors.New("resource does not exist"), "condition check failed", "clause", clause)
+			return false, clause, nil
 		}
 	}
 
-	return true, nil
+	return true, "", nil
+}
+
+//Validate  check the resources of the condition.
+func (c Condition) Validate(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, r := range c.ResourceNotExist {
+		allErrs = append(allErrs, r.Validate(fldPath.Child("resourceNotExist").Index(i), false)...)
+	}
+	for i, r := range c.ResourceExist {
+		allErrs = append(allErrs, r.Validate(fldPath.Child("resourceExist").Index(i), true)...)
+	}
+	switch c.Strategy {
+	case "", External, Import:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("strategy"), c.Strategy,
+			[]string{string(External), string(Import)}))
+	}
+	return allErrs
 }
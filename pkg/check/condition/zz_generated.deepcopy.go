@@ -30,12 +30,16 @@ func (in *Condition) DeepCopyInto(out *Condition) {
 	if in.ResourceNotExist != nil {
 		in, out := &in.ResourceNotExist, &out.ResourceNotExist
 		*out = make([]resource.Resource, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.ResourceExist != nil {
 		in, out := &in.ResourceExist, &out.ResourceExist
 		*out = make([]resource.Resource, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
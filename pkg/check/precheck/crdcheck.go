@@ -2,6 +2,7 @@ package precheck
 
 import (
 	"context"
+	"fmt"
 	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -11,7 +12,9 @@ import (
 )
 
 type CRDCheck struct {
+	// CRDs which should not exist.
 	Conflict []CRD `json:"conflict,omitempty"`
+	// CRDs which should exist and be established, with the version served.
 	Required []CRD `json:"required,omitempty"`
 }
 
@@ -20,71 +23,63 @@ type CRD struct {
 	Version string `json:"version,omitempty"`
 }
 
-//Check 为true才能继续安装
-func (c *CRDCheck) Check() (bool, error) {
+//String  return the crd name with the version if any.
+func (c CRD) String() string {
+	if len(c.Version) == 0 {
+		return c.Name
+	}
+	return c.Name + "/" + c.Version
+}
+
+//Check  return the conflicting crds existing and the required crds missing.
+func (c *CRDCheck) Check() ([]string, error) {
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, err
+	}
 	scheme := runtime.NewScheme()
-	k8sclient, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	v1.AddToScheme(scheme)
+	v1beta1.AddToScheme(scheme)
+	k8sclient, err := client.New(config, client.Options{Scheme: scheme})
 	if err != nil {
 		log.Printf("new k8s client err %v", err)
-		return false, err
+		return nil, err
 	}
 
 	o := &client.ListOptions{}
 	var list v1.CustomResourceDefinitionList
-	v1.AddToScheme(scheme)
 	if err = k8sclient.List(context.Background(), &list, o); err != nil {
 		log.Printf("list crd err %v", err)
 		var list v1beta1.CustomResourceDefinitionList
-		v1beta1.AddToScheme(scheme)
 		if err = k8sclient.List(context.Background(), &list, o); err != nil {
 			log.Printf("list crd err %v", err)
-			return false, err
+			return nil, err
 		}
-		_, e := doCheckV1beta1(list.Items, c.Conflict, c.Required)
-		return e, nil
+		return doCheck(func(name, version string) bool {
+			return v1beta1CRDExist(list.Items, name, version)
+		}, c.Conflict, c.Required), nil
 	}
-	_, e := doCheckV1(list.Items, c.Conflict, c.Required)
-	return e, nil
+	return doCheck(func(name, version string) bool {
+		return v1CRDExist(list.Items, name, version)
+	}, c.Conflict, c.Required), nil
 }
 
-func doCheckV1beta1(crds []v1beta1.CustomResourceDefinition, conflicts, exists []CRD) (bool, bool) {
-	conflict := true
-	exist := true
+//doCheck  return the conflicting crds existing and the required crds missing.
+func doCheck(exist func(name, version string) bool, conflicts, required []CRD) []string {
+	var unmet []string
 	for _, c := range conflicts {
-		if v1beta1CRDExist(crds, c.Name, c.Version) {
-			conflict = false
-			break
+		if exist(c.Name, c.Version) {
+			log.Printf("crd %s conflicts", c.Name)
+			unmet = append(unmet, fmt.Sprintf("crd %s conflicts", c.String()))
 		}
 	}
-
-	for _, c := range exists {
-		if !v1beta1CRDExist(crds, c.Name, c.Version) {
-			log.Printf("crd %s is needed", c.Name)
-			exist = false
-			break
-		}
-	}
-	return conflict, exist
-}
-
-func doCheckV1(crds []v1.CustomResourceDefinition, conflicts, exists []CRD) (bool, bool) {
-	conflict := true
-	exist := true
-	for _, c := range conflicts {
-		if v1CRDExist(crds, c.Name, c.Version) {
-			conflict = false
-			break
-		}
-	}
-
-	for _, c := range exists {
-		if !v1CRDExist(crds, c.Name, c.Version) {
+	for _, c := range required {
+		if !exist(c.Name, c.Version) {
 			log.Printf("crd %s is needed", c.Name)
-			exist = false
-			break
+			unmet = append(unmet, fmt.Sprintf("crd %s is required", c.String()))
 		}
 	}
-	return conflict, exist
+	return unmet
 }
 
 func v1CRDExist(crds []v1.CustomResourceDefinition, name, version string) bool {
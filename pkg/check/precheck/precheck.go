@@ -1,10 +1,137 @@
 package precheck
 
-import "cloudnativeapp/clm/pkg/check/resource"
+import (
+	"cloudnativeapp/clm/pkg/check/resource"
+	"cloudnativeapp/clm/pkg/utils"
+	"fmt"
+	"github.com/Masterminds/semver/v3"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/discovery"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"strings"
+)
 
 type Precheck struct {
 	// All resources should not exist.
 	ResourceNotExist []resource.Resource `json:"resourceNotExist,omitempty"`
 	// All resources should exist.
 	ResourceExist []resource.Resource `json:"resourceExist,omitempty"`
+	// CRDs conflicting with or required by the module.
+	// +optional
+	CRD *CRDCheck `json:"crd,omitempty"`
+	// Semver range the kubernetes server version should satisfy, e.g. ">= 1.16, < 1.23".
+	// +optional
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+}
+
+//Check  check all the requirements, return the requirements unmet joined by semicolons.
+func (p Precheck) Check(log logr.Logger) (bool, string, error) {
+	log.V(utils.Debug).Info("try to do precheck", "precheck", p)
+	var unmet []string
+	for i, r := range p.ResourceNotExist {
+		if ok, reason, err := r.Check(log, false); err != nil {
+			return false, "", err
+		} else if !ok {
+			unmet = append(unmet, fmt.Sprintf("resourceNotExist[%d] %s: %s", i, r.String(), reason))
+		}
+	}
+	for i, r := range p.ResourceExist {
+		if ok, reason, err := r.Check(log, true); err != nil {
+			return false, "", err
+		} else if !ok {
+			unmet = append(unmet, fmt.Sprintf("resourceExist[%d] %s: %s", i, r.String(), reason))
+		}
+	}
+	if p.CRD != nil {
+		u, err := p.CRD.Check()
+		if err != nil {
+			return false, "", err
+		}
+		unmet = append(unmet, u...)
+	}
+	if len(p.KubernetesVersion) > 0 {
+		v, err := ServerVersion()
+		if err != nil {
+			return false, "", err
+		}
+		if reason, err := checkVersion(v, p.KubernetesVersion); err != nil {
+			return false, "", err
+		} else if len(reason) > 0 {
+			unmet = append(unmet, reason)
+		}
+	}
+	if len(unmet) > 0 {
+		log.V(utils.Warn).Info("precheck unmet", "requirements", unmet)
+		return false, strings.Join(unmet, "; "), nil
+	}
+	return true, "", nil
+}
+
+//Validate  check the resources, CRDs and version constraint of the precheck.
+func (p Precheck) Validate(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, r := range p.ResourceNotExist {
+		allErrs = append(allErrs, r.Validate(fldPath.Child("resourceNotExist").Index(i), false)...)
+	}
+	for i, r := range p.ResourceExist {
+		allErrs = append(allErrs, r.Validate(fldPath.Child("resourceExist").Index(i), true)...)
+	}
+	if p.CRD != nil {
+		for i, c := range p.CRD.Conflict {
+			if len(c.Name) == 0 {
+				allErrs = append(allErrs, field.Required(fldPath.Child("crd", "conflict").Index(i).Child("name"), ""))
+			}
+		}
+		for i, c := range p.CRD.Required {
+			if len(c.Name) == 0 {
+				allErrs = append(allErrs, field.Required(fldPath.Child("crd", "required").Index(i).Child("name"), ""))
+			}
+		}
+	}
+	if len(p.KubernetesVersion) > 0 {
+		if _, err := semver.NewConstraint(p.KubernetesVersion); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("kubernetesVersion"), p.KubernetesVersion,
+				err.Error()))
+		}
+	}
+	return allErrs
+}
+
+//ServerVersion  return the git version of the kubernetes server by discovery.
+func ServerVersion() (string, error) {
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return "", err
+	}
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return "", err
+	}
+	info, err := client.ServerVersion()
+	if err != nil {
+		return "", err
+	}
+	return info.GitVersion, nil
+}
+
+//checkVersion  return the reason when the server version does not satisfy the constraint. The pre-release and build
+//metadata of the server version like v1.18.8-eks-7c9bda are ignored.
+func checkVersion(gitVersion, constraint string) (string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+	v, err := semver.NewVersion(gitVersion)
+	if err != nil {
+		return "", fmt.Errorf("invalid kubernetes server version %s: %v", gitVersion, err)
+	}
+	release, err := semver.NewVersion(fmt.Sprintf("%d.%d.%d", v.Major(), v.Minor(), v.Patch()))
+	if err != nil {
+		return "", err
+	}
+	if !c.Check(release) {
+		return fmt.Sprintf("kubernetes version %s does not satisfy %s", gitVersion, constraint), nil
+	}
+	return "", nil
 }
@@ -0,0 +1,43 @@
+package precheck
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckVersion(t *testing.T) {
+	for _, c := range []struct {
+		version    string
+		constraint string
+		met        bool
+	}{
+		{"v1.18.8", ">= 1.16, < 1.23", true},
+		{"v1.18.8-eks-7c9bda", ">= 1.18", true},
+		{"v1.15.12", ">= 1.16", false},
+		{"v1.23.0+k3s1", "< 1.23", false},
+	} {
+		reason, err := checkVersion(c.version, c.constraint)
+		if err != nil || (len(reason) == 0) != c.met {
+			t.Errorf("version %s constraint %s expected met %v, got %q %v", c.version, c.constraint, c.met,
+				reason, err)
+		}
+	}
+	if _, err := checkVersion("unknown", ">= 1.16"); err == nil {
+		t.Errorf("invalid server version should fail")
+	}
+}
+
+func TestDoCheck(t *testing.T) {
+	crds := map[string]string{"applications.app.k8s.io": "v1beta1"}
+	exist := func(name, version string) bool {
+		v, ok := crds[name]
+		return ok && (len(version) == 0 || v == version)
+	}
+	unmet := doCheck(exist,
+		[]CRD{{Name: "applications.app.k8s.io"}, {Name: "workloads.core.oam.dev"}},
+		[]CRD{{Name: "applications.app.k8s.io", Version: "v1beta1"}, {Name: "applications.app.k8s.io", Version: "v1"}})
+	expected := []string{"crd applications.app.k8s.io conflicts", "crd applications.app.k8s.io/v1 is required"}
+	if !reflect.DeepEqual(unmet, expected) {
+		t.Errorf("expected %v, got %v", expected, unmet)
+	}
+}
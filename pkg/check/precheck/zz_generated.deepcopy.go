@@ -0,0 +1,99 @@
+// +build !ignore_autogenerated
+
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package precheck
+
+import (
+	"cloudnativeapp/clm/pkg/check/resource"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CRD) DeepCopyInto(out *CRD) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CRD.
+func (in *CRD) DeepCopy() *CRD {
+	if in == nil {
+		return nil
+	}
+	out := new(CRD)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CRDCheck) DeepCopyInto(out *CRDCheck) {
+	*out = *in
+	if in.Conflict != nil {
+		in, out := &in.Conflict, &out.Conflict
+		*out = make([]CRD, len(*in))
+		copy(*out, *in)
+	}
+	if in.Required != nil {
+		in, out := &in.Required, &out.Required
+		*out = make([]CRD, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CRDCheck.
+func (in *CRDCheck) DeepCopy() *CRDCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(CRDCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Precheck) DeepCopyInto(out *Precheck) {
+	*out = *in
+	if in.ResourceNotExist != nil {
+		in, out := &in.ResourceNotExist, &out.ResourceNotExist
+		*out = make([]resource.Resource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ResourceExist != nil {
+		in, out := &in.ResourceExist, &out.ResourceExist
+		*out = make([]resource.Resource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CRD != nil {
+		in, out := &in.CRD, &out.CRD
+		*out = new(CRDCheck)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Precheck.
+func (in *Precheck) DeepCopy() *Precheck {
+	if in == nil {
+		return nil
+	}
+	out := new(Precheck)
+	in.DeepCopyInto(out)
+	return out
+}
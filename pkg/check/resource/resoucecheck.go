@@ -1,37 +1,178 @@
 package resource
 
 import (
+	"bytes"
 	"cloudnativeapp/clm/pkg/cliruntime"
 	"cloudnativeapp/clm/pkg/utils"
+	"fmt"
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/util/jsonpath"
+	"strings"
 )
 
 type Resource struct {
 	Type      string `json:"type,omitempty"`
 	Name      string `json:"name,omitempty"`
 	Namespace string `json:"namespace,omitempty"`
+	// Label selector like kubectl -l, all the resources of the type matched are checked. May not set with name.
+	// +optional
+	Selector string `json:"selector,omitempty"`
+	// Min count of the resources matched, defaults to 1. resourceExist only.
+	// +optional
+	MinCount *int `json:"minCount,omitempty"`
+	// Max count of the resources matched, no limit when omitted. resourceExist only.
+	// +optional
+	MaxCount *int `json:"maxCount,omitempty"`
+	// JSONPath expression evaluated on each resource, e.g. {.data.key}. Only the resources whose result equals to
+	// value, or is non-empty when value is omitted, are matched.
+	// +optional
+	JSONPath string `json:"jsonPath,omitempty"`
+	// Expected result of the JSONPath expression.
+	// +optional
+	Value string `json:"value,omitempty"`
 }
 
-//Check  Check the resource.
-func (res Resource) Check(log logr.Logger, exist bool) (bool, error) {
+//Check  Check the resource, return the reason when the check fails.
+func (res Resource) Check(log logr.Logger, exist bool) (bool, string, error) {
 	log.V(utils.Debug).Info("try to check resource", "resource", res, "exist", exist)
 	allNamespace := false
 	if len(res.Namespace) == 0 {
 		allNamespace = true
 	}
 	g := cliruntime.NewGetOption(false, res.Namespace, allNamespace)
-	_, err := g.Run([]string{res.Type, res.Name})
+	g.Selector = res.Selector
+	args := []string{res.Type}
+	if len(res.Name) > 0 {
+		args = append(args, res.Name)
+	}
+	objects, err := g.Objects(args)
 	if err != nil {
 		log.V(utils.Debug).Info("find resource error", "resource", res, "error", err.Error())
-		if errors.IsNotFound(err) && !exist {
-			return true, nil
+		if !errors.IsNotFound(err) {
+			return false, "", err
 		}
-		return false, err
+		objects = nil
+	}
+	return res.evaluate(objects, exist)
+}
+
+//evaluate  count the objects matching the JSONPath expression and check the count.
+func (res Resource) evaluate(objects []*unstructured.Unstructured, exist bool) (bool, string, error) {
+	matched := 0
+	for _, o := range objects {
+		if ok, err := res.match(o); err != nil {
+			return false, "", err
+		} else if ok {
+			matched++
+		}
+	}
+	if !exist {
+		if matched > 0 {
+			return false, fmt.Sprintf("%d matched, none allowed", matched), nil
+		}
+		return true, "", nil
+	}
+	min := 1
+	if res.MinCount != nil {
+		min = *res.MinCount
+	}
+	if matched < min {
+		return false, fmt.Sprintf("%d matched, at least %d required", matched, min), nil
 	}
-	if exist {
+	if res.MaxCount != nil && matched > *res.MaxCount {
+		return false, fmt.Sprintf("%d matched, at most %d allowed", matched, *res.MaxCount), nil
+	}
+	return true, "", nil
+}
+
+func (res Resource) match(o *unstructured.Unstructured) (bool, error) {
+	if len(res.JSONPath) == 0 {
 		return true, nil
 	}
+	j := jsonpath.New("resource").AllowMissingKeys(true)
+	if err := j.Parse(res.jsonPathTemplate()); err != nil {
+		return false, err
+	}
+	buf := new(bytes.Buffer)
+	if err := j.Execute(buf, o.Object); err != nil {
+		return false, err
+	}
+	got := buf.String()
+	if len(res.Value) == 0 {
+		return len(got) > 0, nil
+	}
+	return got == res.Value, nil
+}
 
-	return false, nil
+//jsonPathTemplate  return the JSONPath expression in braces as the kubectl jsonpath template.
+func (res Resource) jsonPathTemplate() string {
+	if strings.HasPrefix(res.JSONPath, "{") {
+		return res.JSONPath
+	}
+	return "{" + res.JSONPath + "}"
+}
+
+//String  return the resource in the kubectl get style, with the JSONPath expression.
+func (res Resource) String() string {
+	s := res.Type
+	if len(res.Name) > 0 {
+		s += "/" + res.Name
+	}
+	if len(res.Selector) > 0 {
+		s += " -l " + res.Selector
+	}
+	if len(res.Namespace) > 0 {
+		s += " -n " + res.Namespace
+	}
+	if len(res.JSONPath) > 0 {
+		s += " " + res.jsonPathTemplate()
+		if len(res.Value) > 0 {
+			s += "=" + res.Value
+		}
+	}
+	return s
+}
+
+//Validate  check the resource settings, counts are allowed only when the resource should exist.
+func (res Resource) Validate(fldPath *field.Path, exist bool) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(res.Type) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("type"), ""))
+	}
+	if len(res.Selector) > 0 {
+		if len(res.Name) > 0 {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("selector"), "may not specify with name"))
+		}
+		if _, err := labels.Parse(res.Selector); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("selector"), res.Selector, err.Error()))
+		}
+	}
+	if !exist && (res.MinCount != nil || res.MaxCount != nil) {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "counts may only be set when the resource should exist"))
+	}
+	if res.MinCount != nil && *res.MinCount < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("minCount"), *res.MinCount,
+			"must be greater than or equal to 0"))
+	}
+	if res.MaxCount != nil {
+		if *res.MaxCount < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("maxCount"), *res.MaxCount,
+				"must be greater than or equal to 0"))
+		} else if res.MinCount != nil && *res.MaxCount < *res.MinCount {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("maxCount"), *res.MaxCount,
+				"must be greater than or equal to minCount"))
+		}
+	}
+	if len(res.JSONPath) > 0 {
+		if err := jsonpath.New("resource").Parse(res.jsonPathTemplate()); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("jsonPath"), res.JSONPath, err.Error()))
+		}
+	} else if len(res.Value) > 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("jsonPath"), "value is set"))
+	}
+	return allErrs
 }
@@ -0,0 +1,60 @@
+package resource
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"strings"
+	"testing"
+)
+
+func storageClass(name string, isDefault bool) *unstructured.Unstructured {
+	o := &unstructured.Unstructured{Object: map[string]interface{}{"kind": "StorageClass"}}
+	o.SetName(name)
+	if isDefault {
+		o.SetAnnotations(map[string]string{"storageclass.kubernetes.io/is-default-class": "true"})
+	}
+	return o
+}
+
+func TestEvaluate(t *testing.T) {
+	classes := []*unstructured.Unstructured{storageClass("local", false), storageClass("ssd", true)}
+	res := Resource{Type: "storageclass",
+		JSONPath: `{.metadata.annotations.storageclass\.kubernetes\.io/is-default-class}`, Value: "true"}
+	if ok, reason, err := res.evaluate(classes, true); !ok || err != nil {
+		t.Errorf("default storage class should exist, got %q %v", reason, err)
+	}
+	if ok, reason, _ := res.evaluate(classes[:1], true); ok || reason != "0 matched, at least 1 required" {
+		t.Errorf("no default storage class should fail, got %q", reason)
+	}
+	if ok, reason, _ := res.evaluate(classes, false); ok || reason != "1 matched, none allowed" {
+		t.Errorf("default storage class should not exist, got %q", reason)
+	}
+
+	min, max := 3, 3
+	nodes := Resource{Type: "nodes", Selector: "node-role.kubernetes.io/worker", MinCount: &min}
+	if ok, reason, _ := nodes.evaluate(classes, true); ok || reason != "2 matched, at least 3 required" {
+		t.Errorf("too few nodes should fail, got %q", reason)
+	}
+	nodes.MinCount, nodes.MaxCount = nil, &max
+	if ok, reason, _ := nodes.evaluate(append(classes, classes...), true); ok ||
+		reason != "4 matched, at most 3 allowed" {
+		t.Errorf("too many nodes should fail, got %q", reason)
+	}
+	if s := res.String(); !strings.HasPrefix(s, "storageclass {.metadata") || !strings.HasSuffix(s, "=true") {
+		t.Errorf("unexpected resource string %q", s)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	min, max := 3, 1
+	res := Resource{Type: "nodes", Name: "node-1", Selector: "role in (", MinCount: &min, MaxCount: &max,
+		Value: "true"}
+	errs := res.Validate(field.NewPath("resourceExist").Index(0), true)
+	if len(errs) != 4 {
+		t.Errorf("expected 4 errors, got %v", errs)
+	}
+	res = Resource{Type: "configmap", Name: "settings", Namespace: "default", JSONPath: "{.data.mode", MinCount: &min}
+	if errs := res.Validate(field.NewPath("resourceNotExist").Index(0), false); len(errs) != 2 {
+		t.Errorf("expected 2 errors, got %v", errs)
+	}
+}
@@ -0,0 +1,97 @@
+package cliruntime
+
+import (
+	"cloudnativeapp/clm/pkg/utils"
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+//Diff  diff the objects to apply against the live objects in cluster without changing them.
+//Only the fields set in the objects to apply are compared, absent objects are diffed against empty. The values of the
+//secrets are redacted.
+func (n *ApplyOptions) Diff() (string, error) {
+	cLog.V(utils.Debug).Info("start diff")
+	infos, err := n.GetObjects()
+	if err != nil {
+		return "", err
+	}
+	var diffs []string
+	for _, info := range infos {
+		d, err := diffOneObject(info)
+		if err != nil {
+			return "", err
+		}
+		if len(d) > 0 {
+			diffs = append(diffs, d)
+		}
+	}
+	return strings.Join(diffs, ""), nil
+}
+
+func diffOneObject(info *resource.Info) (string, error) {
+	desired, err := runtime.DefaultUnstructuredConverter.ToUnstructured(info.Object)
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s %s", info.Mapping.GroupVersionKind.Kind, info.Name)
+	if info.Namespaced() {
+		name = fmt.Sprintf("%s %s/%s", info.Mapping.GroupVersionKind.Kind, info.Namespace, info.Name)
+	}
+	var live string
+	obj, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return "", err
+		}
+	} else if u, ok := obj.(*unstructured.Unstructured); ok {
+		b, err := yaml.Marshal(prune(u.Object, desired))
+		if err != nil {
+			return "", err
+		}
+		live = string(b)
+	}
+	b, err := yaml.Marshal(desired)
+	if err != nil {
+		return "", err
+	}
+	return utils.LineDiff("live "+name, "desired "+name, utils.RedactSecrets(live), utils.RedactSecrets(string(b))), nil
+}
+
+//prune  keep the fields of live object which are set in the desired object.
+func prune(live, desired interface{}) interface{} {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return live
+		}
+		result := make(map[string]interface{})
+		for k, v := range d {
+			if lv, ok := l[k]; ok {
+				result[k] = prune(lv, v)
+			}
+		}
+		return result
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok {
+			return live
+		}
+		result := make([]interface{}, len(l))
+		for i := range l {
+			if i < len(d) {
+				result[i] = prune(l[i], d[i])
+			} else {
+				result[i] = l[i]
+			}
+		}
+		return result
+	default:
+		return live
+	}
+}
@@ -3,6 +3,7 @@ package cliruntime
 import (
 	"cloudnativeapp/clm/pkg/utils"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/resource"
 )
@@ -11,7 +12,9 @@ type GetOption struct {
 	AllNamespaces  bool
 	Namespace      string
 	IgnoreNotFound bool
-	Builder        *resource.Builder
+	// Label selector of the resources, only when no name is given.
+	Selector string
+	Builder  *resource.Builder
 }
 
 func NewGetOption(ignoreNotFound bool, namespace string, allNamespace bool) *GetOption {
@@ -25,10 +28,17 @@ func NewGetOption(ignoreNotFound bool, namespace string, allNamespace bool) *Get
 }
 
 func (g *GetOption) Run(args []string) (int, error) {
+	objects, err := g.Objects(args)
+	return len(objects), err
+}
+
+//Objects  return the resources got by the type and name args.
+func (g *GetOption) Objects(args []string) ([]*unstructured.Unstructured, error) {
 	cLog.V(utils.Debug).Info("start get")
 	r := g.Builder.
 		Unstructured().
 		NamespaceParam(g.Namespace).DefaultNamespace().AllNamespaces(g.AllNamespaces).
+		LabelSelectorParam(g.Selector).
 		ResourceTypeOrNameArgs(true, args...).
 		ContinueOnError().
 		Latest().
@@ -39,14 +49,20 @@ func (g *GetOption) Run(args []string) (int, error) {
 		r.IgnoreErrors(apierrors.IsNotFound)
 	}
 	if err := r.Err(); err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	rs, err := r.Infos()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	cLog.V(utils.Debug).Info("resource got", "result", rs)
 
-	return len(rs), nil
+	var objects []*unstructured.Unstructured
+	for _, i := range rs {
+		if o, ok := i.Object.(*unstructured.Unstructured); ok {
+			objects = append(objects, o)
+		}
+	}
+	return objects, nil
 }
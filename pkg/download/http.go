@@ -1,53 +1,365 @@
 package download
 
 import (
-	"errors"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
-func HttpGet(p string) (string, error) {
+const (
+	defaultTimeout = 10 * time.Minute
+	defaultMaxSize = 1 << 30
+	defaultMaxAge  = 7 * 24 * time.Hour
+	// Prefix of the files being downloaded.
+	tempPrefix = ".download-"
+)
+
+var digestRegexp = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+//DefaultCache  the cache HttpGet downloads to.
+var DefaultCache = &Cache{
+	Dir:     filepath.Join(os.TempDir(), "clm", "download"),
+	MaxSize: defaultMaxSize,
+	MaxAge:  defaultMaxAge,
+	Timeout: defaultTimeout,
+}
+
+// Cache of the downloaded files. The content is stored by its sha256 digest under blobs/sha256/<hex>/<file name>,
+// and each url refers to the content it downloaded last under urls/<sha256 of url>.json, so the http url is
+// downloaded again only when the server reports it modified.
+type Cache struct {
+	Dir string
+	// Total size of the content kept, the least recently used content is evicted beyond. 0 means no limit.
+	MaxSize int64
+	// The content unused longer is evicted. 0 means no limit.
+	MaxAge time.Duration
+	// Timeout of a download.
+	Timeout time.Duration
+
+	// Serialize the downloads of a url.
+	locks sync.Map
+	// Exclude the evictions from the content stored.
+	evictLock sync.RWMutex
+}
+
+// entry records the content an url downloaded last.
+type entry struct {
+	URL          string `json:"url"`
+	Digest       string `json:"digest"`
+	Name         string `json:"name"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+//HttpGet  download the http, https or file url to the default cache and return the local path, empty when p is
+//not an url. The content is checked against the sha256 digest when set.
+func HttpGet(p, digest string) (string, error) {
 	u, err := url.Parse(p)
 	if err != nil || u.Scheme == "" {
 		return "", nil
-	} else {
-		return HttpAndFileSchemeDownload(p, u)
 	}
+	return DefaultCache.Get(p, digest)
+}
 
+//ParseDigest  return the hex of the sha256 digest, in the form sha256:<hex> or <hex>.
+func ParseDigest(digest string) (string, error) {
+	h := strings.ToLower(strings.TrimPrefix(digest, "sha256:"))
+	if !digestRegexp.MatchString(h) {
+		return "", fmt.Errorf("invalid sha256 digest %s", digest)
+	}
+	return h, nil
 }
 
-func HttpAndFileSchemeDownload(p string, url *url.URL) (string, error) {
-	tr := &http.Transport{}
-	tr.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
-	c := &http.Client{Transport: tr}
-	c.Timeout = 3600 * time.Second
-	resp, err := c.Get(p)
+//VerifyDigest  check the sha256 digest of the file.
+func VerifyDigest(file, digest string) error {
+	expected, err := ParseDigest(digest)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != expected {
+		return fmt.Errorf("sha256 digest mismatch of %s, expected %s, got %s", file, expected, actual)
+	}
+	return nil
+}
+
+//Get  download the url to the cache and return the local path. The content cached is returned when the digest is
+//set and cached, or when the server reports the url not modified. The download is checked against the digest when
+//set.
+func (c *Cache) Get(rawurl, digest string) (string, error) {
+	u, err := url.Parse(rawurl)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
+	if len(digest) > 0 {
+		if digest, err = ParseDigest(digest); err != nil {
+			return "", err
+		}
+	}
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		name = "download"
+	}
+	key := hash(rawurl)
+	lock, _ := c.locks.LoadOrStore(key, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
 
-	if resp.StatusCode == http.StatusOK {
-		_, f := filepath.Split(url.Path)
+	if len(digest) > 0 {
+		if p, ok := c.cached(digest, name); ok {
+			return p, nil
+		}
+	}
+	e := c.entry(key)
+	if e != nil && len(digest) > 0 && e.Digest != digest {
+		e = nil
+	}
 
-		path := filepath.Join(os.TempDir(), f)
-		out, err := os.Create(path)
-		if err != nil {
+	resp, err := c.fetch(rawurl, e)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		if p, ok := c.cached(e.Digest, e.Name); ok {
+			return p, nil
+		}
+		// Evicted meanwhile.
+		resp.Body.Close()
+		if resp, err = c.fetch(rawurl, nil); err != nil {
 			return "", err
 		}
-		defer out.Close()
-		_, err = io.Copy(out, resp.Body)
+		defer resp.Body.Close()
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download failed, http response:%s.", resp.Status)
+	}
+
+	actual, p, err := c.store(resp.Body, name)
+	if err != nil {
+		return "", err
+	}
+	if len(digest) > 0 && actual != digest {
+		return "", fmt.Errorf("sha256 digest mismatch of %s, expected %s, got %s", rawurl, digest, actual)
+	}
+	e = &entry{URL: rawurl, Digest: actual, Name: name, ETag: resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified")}
+	if err := c.writeEntry(key, e); err != nil {
+		return "", err
+	}
+	if err := c.Evict(p); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+//fetch  get the url, conditionally on the entry when set.
+func (c *Cache) fetch(rawurl string, e *entry) (*http.Response, error) {
+	client := &http.Client{Timeout: c.timeout(), Transport: transport()}
+	req, err := http.NewRequest(http.MethodGet, rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if e != nil {
+		if len(e.ETag) > 0 {
+			req.Header.Set("If-None-Match", e.ETag)
+		}
+		if len(e.LastModified) > 0 {
+			req.Header.Set("If-Modified-Since", e.LastModified)
+		}
+	}
+	return client.Do(req)
+}
+
+//Evict  remove the content unused longer than MaxAge, then the least recently used content until the total size
+//is within MaxSize. The paths to keep are not evicted, nor the content stored or used within the download timeout,
+//which the callers may be loading.
+func (c *Cache) Evict(keep ...string) error {
+	c.evictLock.Lock()
+	defer c.evictLock.Unlock()
+	type blob struct {
+		path string
+		info os.FileInfo
+	}
+	var blobs []blob
+	var total int64
+	now := time.Now()
+	root := filepath.Join(c.Dir, "blobs")
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
 		if err != nil {
-			return "", err
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), tempPrefix) {
+			// Left by the downloads interrupted.
+			if now.Sub(info.ModTime()) > c.timeout() {
+				return removeFile(p)
+			}
+			return nil
+		}
+		total += info.Size()
+		for _, k := range keep {
+			if k == p {
+				return nil
+			}
+		}
+		if now.Sub(info.ModTime()) < c.timeout() {
+			return nil
+		}
+		if c.MaxAge > 0 && now.Sub(info.ModTime()) > c.MaxAge {
+			total -= info.Size()
+			return removeFile(p)
+		}
+		blobs = append(blobs, blob{path: p, info: info})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].info.ModTime().Before(blobs[j].info.ModTime())
+	})
+	for i := 0; c.MaxSize > 0 && total > c.MaxSize && i < len(blobs); i++ {
+		if err := removeFile(blobs[i].path); err != nil {
+			return err
 		}
-		return path, nil
+		total -= blobs[i].info.Size()
+	}
+	return nil
+}
 
-	} else {
-		return "", errors.New(fmt.Sprintf("download failed, http response:%s.", resp.Status))
+//cached  return the path of the content and whether it is cached, the content is marked used.
+func (c *Cache) cached(digest, name string) (string, bool) {
+	p := filepath.Join(c.Dir, "blobs", "sha256", digest, name)
+	if _, err := os.Stat(p); err != nil {
+		return p, false
 	}
+	now := time.Now()
+	os.Chtimes(p, now, now)
+	return p, true
+}
+
+//store  write the content to the cache, return its digest and path.
+func (c *Cache) store(r io.Reader, name string) (string, string, error) {
+	dir := filepath.Join(c.Dir, "blobs", "sha256")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", err
+	}
+	tmp, err := ioutil.TempFile(dir, tempPrefix)
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), r); err != nil {
+		tmp.Close()
+		return "", "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", "", err
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+	p := filepath.Join(dir, digest, name)
+	c.evictLock.RLock()
+	defer c.evictLock.RUnlock()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return "", "", err
+	}
+	return digest, p, os.Rename(tmp.Name(), p)
+}
+
+//entry  return the entry of the url key, nil when absent or invalid.
+func (c *Cache) entry(key string) *entry {
+	data, err := ioutil.ReadFile(filepath.Join(c.Dir, "urls", key+".json"))
+	if err != nil {
+		return nil
+	}
+	e := &entry{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil
+	}
+	return e
+}
+
+func (c *Cache) writeEntry(key string, e *entry) error {
+	dir := filepath.Join(c.Dir, "urls")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, tempPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, key+".json"))
+}
+
+func (c *Cache) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return defaultTimeout
+}
+
+//removeFile  remove the file and its digest directory when empty.
+func removeFile(p string) error {
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if dir := filepath.Dir(p); digestRegexp.MatchString(filepath.Base(dir)) {
+		// Fails when not empty.
+		os.Remove(dir)
+	}
+	return nil
+}
+
+func transport() http.RoundTripper {
+	tr := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	tr.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	return tr
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,214 @@
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func digestOf(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// server serves the charts by path with ETags, the full responses are counted by path.
+type server struct {
+	*httptest.Server
+	lock      sync.Mutex
+	charts    map[string]string
+	downloads map[string]int
+}
+
+func newServer(charts map[string]string) *server {
+	s := &server{charts: charts, downloads: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		chart, ok := s.charts[req.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		etag := `"` + digestOf(chart) + `"`
+		w.Header().Set("ETag", etag)
+		if req.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		s.downloads[req.URL.Path]++
+		fmt.Fprint(w, chart)
+	}))
+	return s
+}
+
+func (s *server) set(path, chart string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.charts[path] = chart
+}
+
+func newCache(t *testing.T) (*Cache, func()) {
+	dir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Cache{Dir: dir}, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCache_Get(t *testing.T) {
+	s := newServer(map[string]string{"/a/nginx-1.0.0.tgz": "chart a", "/b/nginx-1.0.0.tgz": "chart b"})
+	defer s.Close()
+	c, cleanup := newCache(t)
+	defer cleanup()
+
+	p, err := c.Get(s.URL+"/a/nginx-1.0.0.tgz", "")
+	if err != nil {
+		t.Fatalf("get error: %v", err)
+	}
+	if p != filepath.Join(c.Dir, "blobs", "sha256", digestOf("chart a"), "nginx-1.0.0.tgz") {
+		t.Errorf("unexpected path %s", p)
+	}
+	if again, err := c.Get(s.URL+"/a/nginx-1.0.0.tgz", ""); err != nil || again != p {
+		t.Errorf("expected %s, got %s %v", p, again, err)
+	}
+	if s.downloads["/a/nginx-1.0.0.tgz"] != 1 {
+		t.Errorf("not modified chart downloaded again: %v", s.downloads)
+	}
+
+	// Charts of the same file name do not overwrite each other.
+	b, err := c.Get(s.URL+"/b/nginx-1.0.0.tgz", "")
+	if err != nil || b == p {
+		t.Fatalf("unexpected path %s %v", b, err)
+	}
+	if data, _ := ioutil.ReadFile(p); string(data) != "chart a" {
+		t.Errorf("unexpected content %q", data)
+	}
+
+	s.set("/a/nginx-1.0.0.tgz", "chart a changed")
+	if changed, err := c.Get(s.URL+"/a/nginx-1.0.0.tgz", ""); err != nil || changed == p {
+		t.Errorf("changed chart not downloaded: %s %v", changed, err)
+	}
+
+	// The content evicted is downloaded again though not modified.
+	os.RemoveAll(filepath.Join(c.Dir, "blobs"))
+	if again, err := c.Get(s.URL+"/b/nginx-1.0.0.tgz", ""); err != nil || again != b {
+		t.Errorf("expected %s, got %s %v", b, again, err)
+	}
+
+	if _, err := c.Get(s.URL+"/absent.tgz", ""); err == nil {
+		t.Error("absent chart downloaded")
+	}
+}
+
+func TestCache_GetDigest(t *testing.T) {
+	s := newServer(map[string]string{"/nginx-1.0.0.tgz": "chart"})
+	defer s.Close()
+	c, cleanup := newCache(t)
+	defer cleanup()
+
+	if _, err := c.Get(s.URL+"/nginx-1.0.0.tgz", digestOf("other")); err == nil ||
+		!strings.Contains(err.Error(), "digest mismatch") {
+		t.Errorf("digest mismatch not reported: %v", err)
+	}
+	if _, err := c.Get(s.URL+"/nginx-1.0.0.tgz", "sha256:bad"); err == nil {
+		t.Error("invalid digest accepted")
+	}
+	p, err := c.Get(s.URL+"/nginx-1.0.0.tgz", "sha256:"+digestOf("chart"))
+	if err != nil {
+		t.Fatalf("get error: %v", err)
+	}
+	// Served from the cache even the server is gone.
+	s.Close()
+	if again, err := c.Get(s.URL+"/nginx-1.0.0.tgz", digestOf("chart")); err != nil || again != p {
+		t.Errorf("expected %s, got %s %v", p, again, err)
+	}
+	if err := VerifyDigest(p, digestOf("chart")); err != nil {
+		t.Errorf("verify digest error: %v", err)
+	}
+	if err := VerifyDigest(p, digestOf("other")); err == nil {
+		t.Error("digest mismatch not reported")
+	}
+}
+
+func TestCache_GetFile(t *testing.T) {
+	c, cleanup := newCache(t)
+	defer cleanup()
+	src := filepath.Join(c.Dir, "nginx-1.0.0.tgz")
+	if err := ioutil.WriteFile(src, []byte("chart"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := c.Get("file://"+src, digestOf("chart"))
+	if err != nil {
+		t.Fatalf("get error: %v", err)
+	}
+	if data, _ := ioutil.ReadFile(p); string(data) != "chart" {
+		t.Errorf("unexpected content %q", data)
+	}
+}
+
+func TestCache_Evict(t *testing.T) {
+	c, cleanup := newCache(t)
+	defer cleanup()
+	now := time.Now()
+	write := func(dir, name, data string, used time.Time) string {
+		p := filepath.Join(c.Dir, "blobs", "sha256", dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, used, used); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	stale := write(digestOf("stale"), "stale.tgz", "stale", now.Add(-48*time.Hour))
+	old := write(digestOf("0123456789"), "old.tgz", "0123456789", now.Add(-2*time.Hour))
+	kept := write(digestOf("0123456789"), "kept.tgz", "0123456789", now.Add(-3*time.Hour))
+	recent := write(digestOf("9876543210"), "recent.tgz", "9876543210", now.Add(-time.Hour))
+	tmp := write("", tempPrefix+"1", "interrupted", now.Add(-time.Hour))
+
+	c.MaxAge = 24 * time.Hour
+	c.MaxSize = 20
+	c.Timeout = 2 * time.Hour
+	if err := c.Evict(kept); err != nil {
+		t.Fatalf("evict error: %v", err)
+	}
+	for p, exist := range map[string]bool{stale: false, old: false, kept: true, recent: true, tmp: true} {
+		if _, err := os.Stat(p); (err == nil) != exist {
+			t.Errorf("%s expected exist %v, got %v", p, exist, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Dir(stale)); !os.IsNotExist(err) {
+		t.Errorf("empty digest directory not removed: %v", err)
+	}
+
+	// The content returned just now by another call is not evicted.
+	used := write(digestOf("used"), "used.tgz", "0123456789abcdefghijklmnopqrstuvwxyz", now)
+	if err := c.Evict(kept); err != nil {
+		t.Fatalf("evict error: %v", err)
+	}
+	if _, err := os.Stat(used); err != nil {
+		t.Errorf("content in use evicted: %v", err)
+	}
+
+	c.Timeout = time.Minute
+	if err := c.Evict(); err != nil {
+		t.Fatalf("evict error: %v", err)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("interrupted download not removed: %v", err)
+	}
+}
@@ -1,13 +1,14 @@
 package helmsdk
 
 import (
-	"cloudnativeapp/clm/pkg/download"
 	"cloudnativeapp/clm/pkg/utils"
+	"errors"
 	"fmt"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
 	"os"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"time"
@@ -15,9 +16,25 @@ import (
 
 var hLog = ctrl.Log.WithName("helm-sdk")
 
-func Install(chartPath, releaseName, namespace string, vals map[string]interface{}, wait bool, timeouts time.Duration) (string, error) {
+//Options  options of the helm install, upgrade and rollback.
+type Options struct {
+	Wait    bool
+	Timeout time.Duration
+	// Roll back the failed upgrade, or purge the failed install.
+	Atomic bool
+	// Create the release namespace if not present.
+	CreateNamespace bool
+	// Values on upgrade, reset to the chart values or reuse the values of the last release and merge. The values of
+	// the last release are reused and merged when neither set, as before the options.
+	ResetValues bool
+	ReuseValues bool
+	// Revisions kept per release on upgrade and rollback, 0 means no limit.
+	MaxHistory int
+}
+
+func Install(chartPath, releaseName, namespace string, vals map[string]interface{}, opts Options) (string, error) {
 	hLog.V(utils.Debug).Info("try to install", "chartPath", chartPath, "releaseName", releaseName,
-		"namespace", namespace, "values", vals, "wait", wait, "timeouts", timeouts)
+		"namespace", namespace, "values", vals, "options", opts)
 	actionConfig, err := getActionConfig(namespace)
 	if err != nil {
 		return "", err
@@ -25,10 +42,13 @@ func Install(chartPath, releaseName, namespace string, vals map[string]interface
 	client := action.NewInstall(actionConfig)
 	client.Namespace = namespace
 	client.ReleaseName = releaseName
-	if wait {
-		client.Wait = true
-		client.Timeout = timeouts
-	}
+	// Reuse the name of the release uninstalled with history kept.
+	client.Replace = true
+	client.CreateNamespace = opts.CreateNamespace
+	client.Atomic = opts.Atomic
+	// Atomic implies wait.
+	client.Wait = opts.Wait || opts.Atomic
+	client.Timeout = opts.Timeout
 	charts, err := loader.Load(chartPath)
 	if err != nil {
 		return "", err
@@ -42,24 +62,13 @@ func Install(chartPath, releaseName, namespace string, vals map[string]interface
 	return results.Name, nil
 }
 
-func Uninstall(chartPath, releaseName, namespace string) (string, error) {
-	hLog.V(utils.Debug).Info("try to uninstall", "chartPath", chartPath,
-		"releaseName", releaseName, "namespace", namespace)
-	chartPathLocal, err := download.HttpGet(chartPath)
-	if err != nil {
-		return "", err
-	}
-	if len(chartPathLocal) == 0 {
-		chartPathLocal, err = LocateChart(chartPath, namespace)
-		if err != nil {
-			return "", err
-		}
-	}
-	if exist, err := Exist(chartPathLocal, namespace); err != nil {
+//Uninstall  uninstall the release, nothing to do when it is not installed.
+func Uninstall(releaseName, namespace string) (string, error) {
+	hLog.V(utils.Debug).Info("try to uninstall", "releaseName", releaseName, "namespace", namespace)
+	if exist, err := ReleaseExist(releaseName, namespace); err != nil {
 		return "", err
 	} else if !exist {
-		hLog.V(utils.Info).Info("no chart found", "chartPath", chartPath,
-			"releaseName", releaseName, "namespace", namespace)
+		hLog.V(utils.Info).Info("no release found", "releaseName", releaseName, "namespace", namespace)
 		return "", nil
 	}
 	actionConfig, err := getActionConfig(namespace)
@@ -104,9 +113,9 @@ func List(namespace string) ([]*release.Release, error) {
 	return r, nil
 }
 
-func Upgrade(chartPath, releaseName, namespace string, vals map[string]interface{}, wait bool, timeouts time.Duration) (string, error) {
+func Upgrade(chartPath, releaseName, namespace string, vals map[string]interface{}, opts Options) (string, error) {
 	hLog.V(utils.Debug).Info("try to upgrade", "chartPath", chartPath, "releaseName", releaseName,
-		"namespace", namespace, "values", vals, "wait", wait, "timeouts", timeouts)
+		"namespace", namespace, "values", vals, "options", opts)
 	actionConfig, err := getActionConfig(namespace)
 	if err != nil {
 		return "", err
@@ -117,11 +126,12 @@ func Upgrade(chartPath, releaseName, namespace string, vals map[string]interface
 	}
 	hLog.V(utils.Debug).Info("charts load", "charts", charts)
 	client := action.NewUpgrade(actionConfig)
-	client.ReuseValues = true
-	if wait {
-		client.Wait = true
-		client.Timeout = timeouts
-	}
+	client.Namespace = namespace
+	setValuesOptions(client, opts)
+	client.Atomic = opts.Atomic
+	client.Wait = opts.Wait || opts.Atomic
+	client.Timeout = opts.Timeout
+	client.MaxHistory = opts.MaxHistory
 	r, err := client.Run(releaseName, charts, vals)
 	if err != nil {
 		return "", err
@@ -130,24 +140,155 @@ func Upgrade(chartPath, releaseName, namespace string, vals map[string]interface
 	return r.Name, nil
 }
 
-func Exist(chartPath, namespace string) (bool, error) {
-	target, err := loader.Load(chartPath)
+//setValuesOptions  pass the values options of upgrade as given, helm decides the values when neither is set.
+func setValuesOptions(client *action.Upgrade, opts Options) {
+	client.ResetValues = opts.ResetValues
+	client.ReuseValues = opts.ReuseValues || !opts.ResetValues
+}
+
+//Rollback  rollback the release to the revision, 0 means the previous revision.
+func Rollback(releaseName, namespace string, version int, opts Options) (string, error) {
+	hLog.V(utils.Debug).Info("try to rollback", "releaseName", releaseName, "namespace", namespace,
+		"version", version, "options", opts)
+	actionConfig, err := getActionConfig(namespace)
 	if err != nil {
-		return false, err
+		return "", err
+	}
+	client := action.NewRollback(actionConfig)
+	client.Version = version
+	client.Wait = opts.Wait || opts.Atomic
+	client.Timeout = opts.Timeout
+	client.MaxHistory = opts.MaxHistory
+	if err := client.Run(releaseName); err != nil {
+		return "", err
 	}
-	hLog.V(utils.Debug).Info("charts load", "charts", target)
-	charts, err := List(namespace)
+	hLog.V(utils.Debug).Info("charts rollback", "releaseName", releaseName)
+	return releaseName, nil
+}
+
+//ReleaseExist  return true when the release is installed, the release uninstalled with history kept is not.
+func ReleaseExist(releaseName, namespace string) (bool, error) {
+	history, err := History(releaseName, namespace)
 	if err != nil {
 		return false, err
 	}
-	for _, chart := range charts {
-		if chart.Chart.Metadata.Name == target.Metadata.Name &&
-			chart.Chart.Metadata.Version == target.Metadata.Version {
-			return true, nil
+	last := lastRelease(history)
+	return last != nil && last.Info.Status != release.StatusUninstalled, nil
+}
+
+//DeployedRevision  return the last revision deployed successfully to recover the release to, 0 when none.
+func DeployedRevision(releaseName, namespace string) (int, error) {
+	history, err := History(releaseName, namespace)
+	if err != nil {
+		return 0, err
+	}
+	return deployedRevision(history), nil
+}
+
+//History  return the revisions of the release, empty when it is not found.
+func History(releaseName, namespace string) ([]*release.Release, error) {
+	actionConfig, err := getActionConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+	history, err := action.NewHistory(actionConfig).Run(releaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, nil
+	}
+	return history, err
+}
+
+func lastRelease(history []*release.Release) *release.Release {
+	var last *release.Release
+	for _, r := range history {
+		if last == nil || r.Version > last.Version {
+			last = r
+		}
+	}
+	return last
+}
+
+//deployedRevision  return the revision deployed, or the last superseded one when the deployed one is absent.
+func deployedRevision(history []*release.Release) int {
+	deployed, superseded := 0, 0
+	for _, r := range history {
+		if r.Info == nil {
+			continue
 		}
+		switch r.Info.Status {
+		case release.StatusDeployed:
+			if r.Version > deployed {
+				deployed = r.Version
+			}
+		case release.StatusSuperseded:
+			if r.Version > superseded {
+				superseded = r.Version
+			}
+		}
+	}
+	if deployed > 0 {
+		return deployed
+	}
+	return superseded
+}
+
+//Test  run the test hooks of the release deployed and return the release with the test results.
+func Test(releaseName, namespace string, timeouts time.Duration) (*release.Release, error) {
+	hLog.V(utils.Debug).Info("try to test", "releaseName", releaseName, "namespace", namespace,
+		"timeouts", timeouts)
+	actionConfig, err := getActionConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+	client := action.NewReleaseTesting(actionConfig)
+	client.Namespace = namespace
+	client.Timeout = timeouts
+	r, err := client.Run(releaseName)
+	if err != nil {
+		return r, err
 	}
+	hLog.V(utils.Debug).Info("charts tested", "releaseName", releaseName)
+	return r, nil
+}
 
-	return false, nil
+//Diff  render the chart without installing it and diff the manifest against the manifest of the release deployed.
+//The values of the secrets are redacted.
+func Diff(chartPath, releaseName, namespace string, vals map[string]interface{}, opts Options) (string, error) {
+	hLog.V(utils.Debug).Info("try to diff", "chartPath", chartPath, "releaseName", releaseName,
+		"namespace", namespace, "values", vals)
+	actionConfig, err := getActionConfig(namespace)
+	if err != nil {
+		return "", err
+	}
+	charts, err := loader.Load(chartPath)
+	if err != nil {
+		return "", err
+	}
+	var deployed string
+	if r, err := action.NewGet(actionConfig).Run(releaseName); err == nil {
+		deployed = r.Manifest
+	} else if !errors.Is(err, driver.ErrReleaseNotFound) {
+		return "", err
+	}
+	var rendered *release.Release
+	if len(deployed) > 0 {
+		client := action.NewUpgrade(actionConfig)
+		client.Namespace = namespace
+		setValuesOptions(client, opts)
+		client.DryRun = true
+		rendered, err = client.Run(releaseName, charts, vals)
+	} else {
+		client := action.NewInstall(actionConfig)
+		client.Namespace = namespace
+		client.ReleaseName = releaseName
+		client.DryRun = true
+		rendered, err = client.Run(charts, vals)
+	}
+	if err != nil {
+		return "", err
+	}
+	return utils.LineDiff("deployed "+releaseName, "rendered "+releaseName, utils.RedactSecrets(deployed),
+		utils.RedactSecrets(rendered.Manifest)), nil
 }
 
 func getSdkLog() func(format string, v ...interface{}) {
@@ -167,9 +308,9 @@ func getActionConfig(namespace string) (*action.Configuration, error) {
 	return actionConfig, nil
 }
 
-// Locate charts and download
-func LocateChart(chart, namespace string) (string, error) {
-	ch := action.ChartPathOptions{}
+// Locate charts and download, the chart is verified by its provenance against the keyring when set.
+func LocateChart(chart, namespace, keyring string) (string, error) {
+	ch := action.ChartPathOptions{Verify: len(keyring) > 0, Keyring: keyring}
 	os.Setenv("HELM_NAMESPACE", namespace)
 	cp, err := ch.LocateChart(chart, cli.New())
 	if err != nil {
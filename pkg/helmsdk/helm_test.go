@@ -0,0 +1,64 @@
+package helmsdk
+
+import (
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	"testing"
+)
+
+func releases(statuses ...release.Status) []*release.Release {
+	var history []*release.Release
+	// Unordered like the history of the storage.
+	for i := len(statuses) - 1; i >= 0; i-- {
+		history = append(history, &release.Release{Name: "r", Version: i + 1,
+			Info: &release.Info{Status: statuses[i]}})
+	}
+	return history
+}
+
+func TestDeployedRevision(t *testing.T) {
+	cases := []struct {
+		history  []*release.Release
+		expected int
+	}{
+		{nil, 0},
+		{releases(release.StatusFailed), 0},
+		{releases(release.StatusSuperseded, release.StatusDeployed, release.StatusFailed), 2},
+		{releases(release.StatusSuperseded, release.StatusSuperseded, release.StatusFailed), 2},
+		{releases(release.StatusDeployed), 1},
+	}
+	for i, c := range cases {
+		if r := deployedRevision(c.history); r != c.expected {
+			t.Errorf("case %d expected revision %d, got %d", i, c.expected, r)
+		}
+	}
+}
+
+func TestLastRelease(t *testing.T) {
+	if r := lastRelease(nil); r != nil {
+		t.Errorf("unexpected last release %v", r)
+	}
+	r := lastRelease(releases(release.StatusSuperseded, release.StatusDeployed, release.StatusUninstalled))
+	if r == nil || r.Version != 3 || r.Info.Status != release.StatusUninstalled {
+		t.Errorf("unexpected last release %v", r)
+	}
+}
+
+func TestSetValuesOptions(t *testing.T) {
+	cases := []struct {
+		opts         Options
+		reset, reuse bool
+	}{
+		{Options{}, false, true},
+		{Options{ResetValues: true}, true, false},
+		{Options{ReuseValues: true}, false, true},
+	}
+	for _, c := range cases {
+		client := &action.Upgrade{}
+		setValuesOptions(client, c.opts)
+		if client.ResetValues != c.reset || client.ReuseValues != c.reuse {
+			t.Errorf("options %+v expected reset %v reuse %v, got %v %v", c.opts, c.reset, c.reuse,
+				client.ResetValues, client.ReuseValues)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package helmsdk
+
+import (
+	"cloudnativeapp/clm/pkg/utils"
+	"errors"
+	"fmt"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/provenance"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const keyringFile = "pubring.gpg"
+
+//KeyringPath  return the path of the keyring loaded from the secret.
+func KeyringPath(namespace, name string) string {
+	return filepath.Join(filepath.Dir(cli.New().RepositoryConfig), "credentials", "keyrings", namespace, name,
+		keyringFile)
+}
+
+//WriteKeyring  write the keyring loaded from the secret for the charts verified later.
+func WriteKeyring(namespace, name string, data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("no keyring in secret %s/%s", namespace, name)
+	}
+	_, err := writeCredential(filepath.Dir(KeyringPath(namespace, name)), keyringFile, data)
+	return err
+}
+
+//VerifyChart  verify the chart archive by its provenance file against the keyring, the signature and the chart
+//digest recorded are checked.
+func VerifyChart(chartPath, provenancePath, keyring string) error {
+	if len(provenancePath) == 0 {
+		return errors.New("chart not signed, no provenance found")
+	}
+	sig, err := provenance.NewFromKeyring(keyring, "")
+	if err != nil {
+		return fmt.Errorf("load keyring %s failed: %v", keyring, err)
+	}
+	// The provenance records the digest by the archive name, which the charts cached by digest are not named.
+	name, err := archiveName(chartPath)
+	if err != nil {
+		return err
+	}
+	signed := chartPath
+	if filepath.Base(chartPath) != name {
+		dir, err := ioutil.TempDir("", "verify-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		signed = filepath.Join(dir, name)
+		if err := copyFile(chartPath, signed); err != nil {
+			return err
+		}
+	}
+	v, err := sig.Verify(signed, provenancePath)
+	if err != nil {
+		return fmt.Errorf("verify chart %s failed: %v", chartPath, err)
+	}
+	for name := range v.SignedBy.Identities {
+		hLog.V(utils.Debug).Info("chart verified", "chart", chartPath, "signedBy", name)
+	}
+	return nil
+}
+
+//archiveName  return the name of the chart archive helm packages and signs, <name>-<version>.tgz.
+func archiveName(chartPath string) (string, error) {
+	c, err := loader.LoadFile(chartPath)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s.tgz", c.Metadata.Name, c.Metadata.Version), nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
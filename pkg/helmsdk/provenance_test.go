@@ -0,0 +1,75 @@
+package helmsdk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The chart, its provenance and the keyring signing it are copied from the helm provenance testdata.
+const (
+	signedChart = "testdata/hashtest-1.2.3.tgz"
+	keyring     = "testdata/helm-test-key.pub"
+)
+
+func TestVerifyChart(t *testing.T) {
+	if err := VerifyChart(signedChart, signedChart+".prov", keyring); err != nil {
+		t.Errorf("signed chart not verified: %v", err)
+	}
+	if err := VerifyChart(signedChart, "", keyring); err == nil {
+		t.Error("unsigned chart verified")
+	}
+
+	dir, err := ioutil.TempDir("", "verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// Cached by digest like the charts pulled from the OCI registries.
+	cached := filepath.Join(dir, "b2d8ab5e4fab5fdeb0c6b5c0e9bdb3d7e0e6c3b7e25a9e1b8d5ac3e4c1e2f3a4")
+	if err := copyFile(signedChart, cached); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyChart(cached, signedChart+".prov", keyring); err != nil {
+		t.Errorf("chart cached by digest not verified: %v", err)
+	}
+
+	tampered := filepath.Join(dir, "hashtest-1.2.3.tgz.prov")
+	data, err := ioutil.ReadFile(signedChart + ".prov")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 1
+	if err := ioutil.WriteFile(tampered, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyChart(signedChart, tampered, keyring); err == nil {
+		t.Error("chart verified by tampered provenance")
+	}
+}
+
+func TestWriteKeyring(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keyring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	old := os.Getenv("HELM_REPOSITORY_CONFIG")
+	os.Setenv("HELM_REPOSITORY_CONFIG", filepath.Join(dir, "repositories.yaml"))
+	defer os.Setenv("HELM_REPOSITORY_CONFIG", old)
+
+	if err := WriteKeyring("apps", "keys", nil); err == nil {
+		t.Error("empty keyring written")
+	}
+	if err := WriteKeyring("apps", "keys", []byte("keyring")); err != nil {
+		t.Fatalf("write keyring error: %v", err)
+	}
+	path := KeyringPath("apps", "keys")
+	if path != filepath.Join(dir, "credentials", "keyrings", "apps", "keys", "pubring.gpg") {
+		t.Errorf("unexpected keyring path %s", path)
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != "keyring" {
+		t.Errorf("unexpected keyring %q", data)
+	}
+}
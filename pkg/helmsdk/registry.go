@@ -0,0 +1,184 @@
+package helmsdk
+
+import (
+	"cloudnativeapp/clm/pkg/oci"
+	"cloudnativeapp/clm/pkg/utils"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/go-logr/logr"
+	"helm.sh/helm/v3/pkg/cli"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+//RegistryOptions  credentials and TLS settings of the OCI registry.
+type RegistryOptions struct {
+	Username string
+	Password string
+	// PEM encoded CA bundle, written to a file for the charts pulled later.
+	CAData []byte
+
+	PlainHTTP             bool
+	InsecureSkipTLSVerify bool
+}
+
+// Docker config format of the registry credentials saved by login.
+type registryConfig struct {
+	Auths map[string]registryAuth `json:"auths"`
+}
+
+type registryAuth struct {
+	Auth string `json:"auth"`
+}
+
+// Serialize the updates of the registry config.
+var registryLock sync.Mutex
+
+//Login  check the credentials with the OCI registry and save them for the source owning them, the charts pulled for
+//the source only use them.
+func Login(owner, registry string, opts RegistryOptions, log logr.Logger) error {
+	if err := oci.Ping(registry, ociOptions(opts)); err != nil {
+		return err
+	}
+	if err := saveRegistryCredentials(owner, registry, opts); err != nil {
+		return err
+	}
+	log.V(utils.Info).Info(registry + " login succeeded")
+	return nil
+}
+
+//Logout  remove the credentials of the OCI registry saved for the source.
+func Logout(owner, registry string) error {
+	return saveRegistryCredentials(owner, registry, RegistryOptions{})
+}
+
+//RemoveRegistries  remove the credentials of the OCI registries and the charts pulled for the source.
+func RemoveRegistries(owner string) error {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if err := os.RemoveAll(registryOwnerDir(owner)); err != nil {
+		return err
+	}
+	return os.RemoveAll(ociCacheDir(owner))
+}
+
+func saveRegistryCredentials(owner, registry string, opts RegistryOptions) error {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, err := writeCredential(registryCredentialsDir(owner, registry), "ca.pem", opts.CAData); err != nil {
+		return err
+	}
+	path := registryConfigPath(owner)
+	c, err := readRegistryConfig(path)
+	if err != nil {
+		return err
+	}
+	if len(opts.Username) == 0 {
+		delete(c.Auths, registry)
+	} else {
+		auth := base64.StdEncoding.EncodeToString([]byte(opts.Username + ":" + opts.Password))
+		c.Auths[registry] = registryAuth{Auth: auth}
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+//PullChart  pull the oci:// chart and its provenance to the chart cache of the source, the credentials and CA saved
+//by the login of the source are used when not set.
+func PullChart(owner, chart string, opts RegistryOptions) (oci.Chart, error) {
+	ref, err := oci.ParseReference(chart)
+	if err != nil {
+		return oci.Chart{}, err
+	}
+	if len(opts.Username) == 0 {
+		registryLock.Lock()
+		c, err := readRegistryConfig(registryConfigPath(owner))
+		registryLock.Unlock()
+		if err != nil {
+			return oci.Chart{}, err
+		}
+		if a, ok := c.Auths[ref.Registry]; ok {
+			if opts.Username, opts.Password, err = decodeAuth(a.Auth); err != nil {
+				return oci.Chart{}, fmt.Errorf("invalid auth of registry %s: %v", ref.Registry, err)
+			}
+		}
+	}
+	if len(opts.CAData) == 0 {
+		data, err := ioutil.ReadFile(filepath.Join(registryCredentialsDir(owner, ref.Registry), "ca.pem"))
+		if err != nil && !os.IsNotExist(err) {
+			return oci.Chart{}, err
+		}
+		opts.CAData = data
+	}
+	hLog.V(utils.Debug).Info("try to pull chart", "chart", ref.String(), "source", owner)
+	// The charts cached are pulled with the credentials of the source, not shared with the other sources.
+	client := oci.Client{CacheDir: ociCacheDir(owner)}
+	return client.Pull(ref, ociOptions(opts))
+}
+
+func ociOptions(opts RegistryOptions) oci.Options {
+	return oci.Options{
+		Username:              opts.Username,
+		Password:              opts.Password,
+		PlainHTTP:             opts.PlainHTTP,
+		InsecureSkipTLSVerify: opts.InsecureSkipTLSVerify,
+		CAData:                opts.CAData,
+	}
+}
+
+//registryOwnerDir  return the directory of the registry credentials saved for the source, the source key has no "_".
+func registryOwnerDir(owner string) string {
+	return filepath.Join(filepath.Dir(cli.New().RepositoryConfig), "credentials", "registries",
+		strings.Replace(owner, "/", "_", -1))
+}
+
+func registryConfigPath(owner string) string {
+	return filepath.Join(registryOwnerDir(owner), "config.json")
+}
+
+func registryCredentialsDir(owner, registry string) string {
+	return filepath.Join(registryOwnerDir(owner), strings.Replace(registry, ":", "_", -1))
+}
+
+func ociCacheDir(owner string) string {
+	return filepath.Join(filepath.Dir(cli.New().RepositoryCache), "oci", strings.Replace(owner, "/", "_", -1))
+}
+
+func readRegistryConfig(path string) (*registryConfig, error) {
+	c := &registryConfig{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("invalid registry config %s: %v", path, err)
+		}
+	}
+	if c.Auths == nil {
+		c.Auths = make(map[string]registryAuth)
+	}
+	return c, nil
+}
+
+func decodeAuth(auth string) (string, string, error) {
+	data, err := base64.StdEncoding.DecodeString(auth)
+	if err != nil {
+		return "", "", err
+	}
+	parts := strings.SplitN(string(data), ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("auth is not username:password")
+	}
+	return parts[0], parts[1], nil
+}
@@ -0,0 +1,92 @@
+package helmsdk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"strings"
+	"testing"
+)
+
+func TestLoginAndPullChart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmsdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for k, v := range map[string]string{
+		"HELM_REPOSITORY_CONFIG": filepath.Join(dir, "config", "repositories.yaml"),
+		"HELM_REPOSITORY_CACHE":  filepath.Join(dir, "cache", "repository"),
+	} {
+		old, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		if ok {
+			defer os.Setenv(k, old)
+		} else {
+			defer os.Unsetenv(k)
+		}
+	}
+
+	chart := []byte("chart archive")
+	sum := sha256.Sum256(chart)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	// Local registry stand-in with basic auth.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if u, p, ok := req.BasicAuth(); !ok || u != "user" || p != "pass" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch req.URL.Path {
+		case "/v2/":
+		case "/v2/charts/nginx/manifests/1.0.0":
+			fmt.Fprintf(w, `{"schemaVersion":2,"layers":[{"mediaType":"%s","digest":"%s","size":%d}]}`,
+				"application/vnd.cncf.helm.chart.content.v1.tar+gzip", digest, len(chart))
+		case "/v2/charts/nginx/blobs/" + digest:
+			w.Write(chart)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	log := ctrl.Log.WithName("test")
+
+	chartRef := "oci://" + host + "/charts/nginx:1.0.0"
+	if err := Login("a", host, RegistryOptions{Username: "user", Password: "wrong", PlainHTTP: true}, log); err == nil {
+		t.Error("wrong credentials logged in")
+	}
+	if _, err := PullChart("a", chartRef, RegistryOptions{PlainHTTP: true}); err == nil {
+		t.Error("chart pulled without login")
+	}
+	if err := Login("a", host, RegistryOptions{Username: "user", Password: "pass", PlainHTTP: true}, log); err != nil {
+		t.Fatalf("login error: %v", err)
+	}
+	pulled, err := PullChart("a", chartRef, RegistryOptions{PlainHTTP: true})
+	if err != nil {
+		t.Fatalf("pull chart error: %v", err)
+	}
+	if !strings.HasPrefix(pulled.Path, filepath.Join(dir, "cache", "oci", "a")) {
+		t.Errorf("chart not in the cache of the source: %s", pulled.Path)
+	}
+	if data, _ := ioutil.ReadFile(pulled.Path); string(data) != string(chart) {
+		t.Errorf("unexpected chart pulled: %q", data)
+	}
+	// The credentials saved by login are not used by the other sources.
+	if _, err := PullChart("team/b", chartRef, RegistryOptions{PlainHTTP: true}); err == nil {
+		t.Error("chart pulled with the credentials of another source")
+	}
+
+	if err := RemoveRegistries("a"); err != nil {
+		t.Fatalf("remove registries error: %v", err)
+	}
+	if _, err := PullChart("a", chartRef, RegistryOptions{PlainHTTP: true}); err == nil {
+		t.Error("chart pulled after the credentials removed")
+	}
+}
@@ -1,6 +1,7 @@
 package helmsdk
 
 import (
+	"bytes"
 	"cloudnativeapp/clm/pkg/utils"
 	"context"
 	"github.com/go-logr/logr"
@@ -35,39 +36,158 @@ type repoAddOptions struct {
 
 	// Deprecated, but cannot be removed until Helm 4
 	deprecatedNoUpdate bool
+
+	// The source adding the repository, it updates the repository only when no other source shares it.
+	owner string
+	opts  RepoOptions
+}
+
+//RepoOptions  credentials and TLS settings of the helm repository.
+type RepoOptions struct {
+	Username string
+	Password string
+	// PEM encoded client certificate, key and CA bundle, written to files for helm.
+	CertData []byte
+	KeyData  []byte
+	CAData   []byte
+
+	InsecureSkipTLSVerify bool
 }
 
-func Add(name, url, username, password string, log logr.Logger) error {
+//Add  add the helm repository for the source, or update it when the configuration or the credentials changed. The
+//repository names are global, the sources adding the repository of the same configuration share it, the repository
+//is updated only when the source is its only owner.
+func Add(name, url, owner string, opts RepoOptions, log logr.Logger) error {
 	config := cli.New()
 	o := &repoAddOptions{
-		name:      name,
-		url:       url,
-		username:  username,
-		password:  password,
-		repoFile:  config.RepositoryConfig,
-		repoCache: config.RepositoryCache,
+		name:                  name,
+		url:                   url,
+		username:              opts.Username,
+		password:              opts.Password,
+		insecureSkipTLSverify: opts.InsecureSkipTLSVerify,
+		repoFile:              config.RepositoryConfig,
+		repoCache:             config.RepositoryCache,
+		owner:                 owner,
+		opts:                  opts,
 	}
 	return o.run(log)
 }
 
-func (o *repoAddOptions) run(log logr.Logger) error {
-	// Ensure the file directory exists as it is required for file locking
-	err := os.MkdirAll(filepath.Dir(o.repoFile), os.ModePerm)
-	if err != nil && !os.IsExist(err) {
+//RemoveRepos  release the helm repositories owned by the source, those not owned by other sources are removed.
+func RemoveRepos(owner string, log logr.Logger) error {
+	config := cli.New()
+	unlock, err := lockRepoFile(config.RepositoryConfig)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	owners, err := readOwners(config.RepositoryConfig)
+	if err != nil {
+		return err
+	}
+	f, err := repo.LoadFile(config.RepositoryConfig)
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return err
+	}
+	for name, o := range owners {
+		if !utils.Contains(o, owner) {
+			continue
+		}
+		if o = utils.Remove(o, owner); len(o) > 0 {
+			owners[name] = o
+			continue
+		}
+		if f.Remove(name) {
+			log.V(utils.Info).Info(name + " has been removed from repositories")
+		}
+		delete(owners, name)
+		if err := os.RemoveAll(credentialsDir(config.RepositoryConfig, name)); err != nil {
+			return err
+		}
+	}
+	if err := f.WriteFile(config.RepositoryConfig, 0644); err != nil {
+		return err
+	}
+	return writeOwners(config.RepositoryConfig, owners)
+}
+
+func credentialsDir(repoFile, name string) string {
+	return filepath.Join(filepath.Dir(repoFile), "credentials", name)
+}
+
+//ownersFile  return the file recording the sources owning each repository, next to the repository file.
+func ownersFile(repoFile string) string {
+	return filepath.Join(filepath.Dir(repoFile), "repository-owners.yaml")
+}
+
+func readOwners(repoFile string) (map[string][]string, error) {
+	owners := make(map[string][]string)
+	b, err := ioutil.ReadFile(ownersFile(repoFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return owners, nil
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, &owners); err != nil {
+		return nil, err
+	}
+	return owners, nil
+}
+
+func writeOwners(repoFile string, owners map[string][]string) error {
+	b, err := yaml.Marshal(owners)
+	if err != nil {
 		return err
 	}
+	return ioutil.WriteFile(ownersFile(repoFile), b, 0644)
+}
 
-	// Acquire a file lock for process synchronization
-	fileLock := flock.New(strings.Replace(o.repoFile, filepath.Ext(o.repoFile), ".lock", 1))
+//lockRepoFile  acquire the file lock of the repository file for process synchronization, return the unlock.
+func lockRepoFile(repoFile string) (func(), error) {
+	// Ensure the file directory exists as it is required for file locking
+	err := os.MkdirAll(filepath.Dir(repoFile), os.ModePerm)
+	if err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+	fileLock := flock.New(strings.Replace(repoFile, filepath.Ext(repoFile), ".lock", 1))
 	lockCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	locked, err := fileLock.TryLockContext(lockCtx, time.Second)
-	if err == nil && locked {
-		defer fileLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return func() {}, nil
+	}
+	return func() { fileLock.Unlock() }, nil
+}
+
+//writeCredential  write the credential data to the file in dir readable by owner only, return the file path or
+//empty when no data, the stale file is removed.
+func writeCredential(dir, file string, data []byte) (string, error) {
+	path := filepath.Join(dir, file)
+	if len(data) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		return "", nil
 	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (o *repoAddOptions) run(log logr.Logger) error {
+	unlock, err := lockRepoFile(o.repoFile)
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
 	b, err := ioutil.ReadFile(o.repoFile)
 	if err != nil && !os.IsNotExist(err) {
@@ -78,7 +198,48 @@ func (o *repoAddOptions) run(log logr.Logger) error {
 	if err := yaml.Unmarshal(b, &f); err != nil {
 		return err
 	}
+	owners, err := readOwners(o.repoFile)
+	if err != nil {
+		return err
+	}
+	if f.Has(o.name) {
+		if o.sameAs(f.Get(o.name)) {
+			// The add is idempotent, the sources of the same configuration share the repository.
+			log.V(utils.Info).Info(o.name + " already exists with the same configuration, skipping")
+			if !utils.Contains(owners[o.name], o.owner) {
+				owners[o.name] = append(owners[o.name], o.owner)
+			}
+			return writeOwners(o.repoFile, owners)
+		}
+		if own := owners[o.name]; len(own) == 0 {
+			// Added out of the controller.
+			return errors.Errorf("repository name (%s) already exists, please specify a different name", o.name)
+		} else if len(own) > 1 || own[0] != o.owner {
+			return errors.Errorf("repository name (%s) already exists, added by source %s, please specify a "+
+				"different name", o.name, strings.Join(own, ","))
+		}
+		log.V(utils.Info).Info(o.name + " already exists, updating")
+	}
 
+	// Write the credentials aside, they replace those of the repository after the index downloaded.
+	dir := credentialsDir(o.repoFile, o.name)
+	if err := os.MkdirAll(filepath.Dir(dir), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(dir), o.name+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if o.certFile, err = writeCredential(tmp, "cert.pem", o.opts.CertData); err != nil {
+		return err
+	}
+	if o.keyFile, err = writeCredential(tmp, "key.pem", o.opts.KeyData); err != nil {
+		return err
+	}
+	if o.caFile, err = writeCredential(tmp, "ca.pem", o.opts.CAData); err != nil {
+		return err
+	}
 	c := repo.Entry{
 		Name:                  o.name,
 		URL:                   o.url,
@@ -90,20 +251,6 @@ func (o *repoAddOptions) run(log logr.Logger) error {
 		InsecureSkipTLSverify: o.insecureSkipTLSverify,
 	}
 
-	if f.Has(o.name) {
-		existing := f.Get(o.name)
-		if c != *existing {
-
-			// The input coming in for the name is different from what is already
-			// configured. Return an error.
-			return errors.Errorf("repository name (%s) already exists, please specify a different name", o.name)
-		}
-
-		// The add is idempotent so do nothing
-		log.V(utils.Info).Info(o.name + "already exists with the same configuration, skipping")
-		return nil
-	}
-
 	r, err := repo.NewChartRepository(&c, getter.All(cli.New()))
 	if err != nil {
 		return err
@@ -116,11 +263,47 @@ func (o *repoAddOptions) run(log logr.Logger) error {
 		return errors.Wrapf(err, "looks like %q is not a valid chart repository or cannot be reached", o.url)
 	}
 
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return err
+	}
+	for _, file := range []*string{&c.CertFile, &c.KeyFile, &c.CAFile} {
+		if len(*file) > 0 {
+			*file = filepath.Join(dir, filepath.Base(*file))
+		}
+	}
 	f.Update(&c)
 
 	if err := f.WriteFile(o.repoFile, 0644); err != nil {
 		return err
 	}
-	log.V(utils.Info).Info(o.name + "has been added to repositories")
+	owners[o.name] = []string{o.owner}
+	if err := writeOwners(o.repoFile, owners); err != nil {
+		return err
+	}
+	log.V(utils.Info).Info(o.name + " has been added to repositories")
 	return nil
 }
+
+//sameAs  return true when the repository entry is configured the same, the credential files compared by content.
+func (o *repoAddOptions) sameAs(e *repo.Entry) bool {
+	if e.URL != o.url || e.Username != o.username || e.Password != o.password ||
+		e.InsecureSkipTLSverify != o.insecureSkipTLSverify {
+		return false
+	}
+	files := []string{e.CertFile, e.KeyFile, e.CAFile}
+	for i, data := range [][]byte{o.opts.CertData, o.opts.KeyData, o.opts.CAData} {
+		if len(files[i]) == 0 {
+			if len(data) > 0 {
+				return false
+			}
+			continue
+		}
+		if b, err := ioutil.ReadFile(files[i]); err != nil || !bytes.Equal(b, data) {
+			return false
+		}
+	}
+	return true
+}
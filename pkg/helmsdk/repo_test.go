@@ -0,0 +1,121 @@
+package helmsdk
+
+import (
+	"encoding/pem"
+	"fmt"
+	"helm.sh/helm/v3/pkg/repo"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"strings"
+	"testing"
+)
+
+func TestAddAndRemoveRepos(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmsdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	repoFile := filepath.Join(dir, "config", "repositories.yaml")
+	for k, v := range map[string]string{
+		"HELM_REPOSITORY_CONFIG": repoFile,
+		"HELM_REPOSITORY_CACHE":  filepath.Join(dir, "cache", "repository"),
+	} {
+		old, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		if ok {
+			defer os.Setenv(k, old)
+		} else {
+			defer os.Unsetenv(k)
+		}
+	}
+	index := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.URL.Path, "/bad") {
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprint(w, "apiVersion: v1\nentries: {}\n")
+	})
+	server := httptest.NewServer(index)
+	defer server.Close()
+	tlsServer := httptest.NewTLSServer(index)
+	defer tlsServer.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw})
+	log := ctrl.Log.WithName("test")
+	url := func(name string) string {
+		f, err := repo.LoadFile(repoFile)
+		if err != nil || !f.Has(name) {
+			return ""
+		}
+		return f.Get(name).URL
+	}
+
+	if err := Add("apps", server.URL+"/a", "a", RepoOptions{}, log); err != nil {
+		t.Fatalf("add repo error: %v", err)
+	}
+	if err := Add("apps", server.URL+"/b", "b", RepoOptions{CAData: []byte("ca")}, log); err == nil ||
+		!strings.Contains(err.Error(), "added by source a") {
+		t.Errorf("repo of another source should not be overwritten, got %v", err)
+	}
+	if u := url("apps"); u != server.URL+"/a" {
+		t.Errorf("repo of another source overwritten by %s", u)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config", "credentials", "apps", "ca.pem")); !os.IsNotExist(err) {
+		t.Errorf("credentials of another source written: %v", err)
+	}
+	if err := Add("apps", server.URL+"/c", "a", RepoOptions{}, log); err != nil || url("apps") != server.URL+"/c" {
+		t.Errorf("repo should be updated by its source, got %s %v", url("apps"), err)
+	}
+	if err := Add("apps", server.URL+"/c", "c", RepoOptions{}, log); err != nil {
+		t.Errorf("repo of the same configuration should be shared, got %v", err)
+	}
+	if err := Add("apps", server.URL+"/d", "a", RepoOptions{}, log); err == nil || url("apps") != server.URL+"/c" {
+		t.Errorf("repo shared should not be updated by one source, got %s %v", url("apps"), err)
+	}
+
+	// The credentials are replaced only after the index downloaded.
+	if err := Add("secure", tlsServer.URL+"/s", "a", RepoOptions{CAData: ca}, log); err != nil {
+		t.Fatalf("add repo with ca error: %v", err)
+	}
+	caFile := filepath.Join(dir, "config", "credentials", "secure", "ca.pem")
+	if err := Add("secure", tlsServer.URL+"/bad", "a", RepoOptions{CAData: append(ca, '\n')}, log); err == nil {
+		t.Error("repo unreachable should not be updated")
+	}
+	if b, err := ioutil.ReadFile(caFile); err != nil || string(b) != string(ca) || url("secure") != tlsServer.URL+"/s" {
+		t.Errorf("credentials should be kept after the update failed, got %v", err)
+	}
+
+	// Added out of the controller.
+	f, _ := repo.LoadFile(repoFile)
+	f.Update(&repo.Entry{Name: "manual", URL: server.URL + "/manual"})
+	if err := f.WriteFile(repoFile, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Add("manual", server.URL+"/b", "b", RepoOptions{}, log); err == nil {
+		t.Error("repo added out of the controller should not be overwritten")
+	}
+	if err := Add("manual", server.URL+"/manual", "b", RepoOptions{}, log); err != nil {
+		t.Errorf("repo of the same configuration should be taken over, got %v", err)
+	}
+
+	if err := RemoveRepos("a", log); err != nil {
+		t.Fatalf("remove repos error: %v", err)
+	}
+	if url("apps") == "" || url("secure") != "" || url("manual") == "" {
+		t.Errorf("only the repos of the source not shared should be removed, got %q %q %q", url("apps"),
+			url("secure"), url("manual"))
+	}
+	if _, err := os.Stat(caFile); !os.IsNotExist(err) {
+		t.Errorf("credentials of the repo removed should be removed: %v", err)
+	}
+	if err := RemoveRepos("c", log); err != nil || url("apps") != "" {
+		t.Errorf("repo should be removed with its last source, got %q %v", url("apps"), err)
+	}
+	if err := Add("apps", server.URL+"/b", "b", RepoOptions{}, log); err != nil {
+		t.Errorf("repo removed should be added by another source, got %v", err)
+	}
+}
@@ -3,22 +3,161 @@ package helm
 import (
 	"cloudnativeapp/clm/pkg/download"
 	"cloudnativeapp/clm/pkg/helmsdk"
+	"cloudnativeapp/clm/pkg/oci"
+	"encoding/base64"
+	"encoding/json"
 	"github.com/pkg/errors"
+	"os"
+	"strings"
 	"time"
 )
 
 type Implement struct {
-	Wait         bool   `json:"wait,omitempty"`
-	Timeout      int    `json:"timeout,omitempty"`
+	Wait    bool `json:"wait,omitempty"`
+	Timeout int  `json:"timeout,omitempty"`
+	// Roll back the failed upgrade, or purge the failed install. Implies wait.
+	// +optional
+	Atomic bool `json:"atomic,omitempty"`
+	// Create the release namespace if not present.
+	// +optional
+	CreateNamespace bool `json:"createNamespace,omitempty"`
+	// Reset the values to the chart values on upgrade. May not set with reuseValues.
+	// +optional
+	ResetValues bool `json:"resetValues,omitempty"`
+	// Reuse the values of the last release and merge on upgrade, the default unless resetValues set. May not set with
+	// resetValues.
+	// +optional
+	ReuseValues bool `json:"reuseValues,omitempty"`
+	// Revisions kept per release on upgrade and rollback, 0 means no limit.
+	// +optional
+	MaxHistory   int    `json:"maxHistory,omitempty"`
 	IgnoreError  bool   `json:"ignoreError,omitempty"`
 	Repositories []Repo `json:"repositories,omitempty"`
+	// OCI registries the oci:// charts are pulled from.
+	// +optional
+	Registries []Registry `json:"registries,omitempty"`
+	// Verify the charts by their provenance files, the installs and upgrades of the charts unsigned or not signed by
+	// the keyring fail.
+	// +optional
+	Verify *Verify `json:"verify,omitempty"`
+	// Key of the source, set by the controller. The oci:// charts are pulled with the registry credentials saved by
+	// the login of the source.
+	SourceKey string `json:"-"`
 }
 
 type Repo struct {
-	Name     string `json:"name"`
-	Url      string `json:"url"`
+	Name string `json:"name"`
+	Url  string `json:"url"`
+	// Basic auth username in plain text, prefer secretRef.
 	UserName string `json:"username,omitempty"`
+	// Basic auth password in plain text, prefer secretRef.
 	PassWord string `json:"password,omitempty"`
+	// Secret with the credentials of the repository, the keys username and password for basic auth, tls.crt and
+	// tls.key for the client certificate, ca.crt for the CA bundle. The repository is added again when it changes.
+	// +optional
+	SecretRef *SecretReference `json:"secretRef,omitempty"`
+	// Skip the certificate check of the repository server.
+	// +optional
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
+}
+
+type Registry struct {
+	// Host and port of the registry, like registry.example.com:5000.
+	Host string `json:"host"`
+	// Secret with the credentials of the registry, the keys username and password, or .dockerconfigjson of a
+	// docker-registry secret, and ca.crt for the CA bundle. The registry is logged in again when it changes.
+	// +optional
+	SecretRef *SecretReference `json:"secretRef,omitempty"`
+	// Access the registry by http instead of https.
+	// +optional
+	PlainHTTP bool `json:"plainHTTP,omitempty"`
+	// Skip the certificate check of the registry server.
+	// +optional
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
+}
+
+type Verify struct {
+	// Secret with the public keyring the charts are signed by, the key pubring.gpg. The keyring is loaded again
+	// when it changes.
+	KeyringSecretRef SecretReference `json:"keyringSecretRef"`
+}
+
+type SecretReference struct {
+	Name string `json:"name"`
+	// Namespace of the secret, required by the cluster scoped sources. The namespaced sources refer to the secrets in
+	// their own namespace.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Keys of the repository and registry secrets.
+const (
+	RepoUsernameKey = "username"
+	RepoPasswordKey = "password"
+	RepoCertKey     = "tls.crt"
+	RepoKeyKey      = "tls.key"
+	RepoCAKey       = "ca.crt"
+	// Key of the docker-registry secrets.
+	DockerConfigJsonKey = ".dockerconfigjson"
+	// Key of the keyring secrets.
+	KeyringKey = "pubring.gpg"
+)
+
+//Options  return the options to add the repository with, the credentials in the secret data win over the inline ones.
+func (r Repo) Options(secret map[string][]byte) helmsdk.RepoOptions {
+	o := helmsdk.RepoOptions{
+		Username:              r.UserName,
+		Password:              r.PassWord,
+		InsecureSkipTLSVerify: r.InsecureSkipTLSVerify,
+	}
+	if v, ok := secret[RepoUsernameKey]; ok {
+		o.Username = string(v)
+	}
+	if v, ok := secret[RepoPasswordKey]; ok {
+		o.Password = string(v)
+	}
+	o.CertData = secret[RepoCertKey]
+	o.KeyData = secret[RepoKeyKey]
+	o.CAData = secret[RepoCAKey]
+	return o
+}
+
+//Options  return the options to pull from the registry, the credentials from the secret data.
+func (r Registry) Options(secret map[string][]byte) (helmsdk.RegistryOptions, error) {
+	o := helmsdk.RegistryOptions{
+		Username:              string(secret[RepoUsernameKey]),
+		Password:              string(secret[RepoPasswordKey]),
+		CAData:                secret[RepoCAKey],
+		PlainHTTP:             r.PlainHTTP,
+		InsecureSkipTLSVerify: r.InsecureSkipTLSVerify,
+	}
+	if data, ok := secret[DockerConfigJsonKey]; ok && len(o.Username) == 0 {
+		var config struct {
+			Auths map[string]struct {
+				Username string `json:"username"`
+				Password string `json:"password"`
+				Auth     string `json:"auth"`
+			} `json:"auths"`
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return o, errors.Wrap(err, "invalid "+DockerConfigJsonKey)
+		}
+		auth, ok := config.Auths[r.Host]
+		if !ok {
+			return o, errors.Errorf("no auth of registry %s in %s", r.Host, DockerConfigJsonKey)
+		}
+		o.Username, o.Password = auth.Username, auth.Password
+		if len(auth.Auth) > 0 {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return o, errors.Wrap(err, "invalid auth of registry "+r.Host)
+			}
+			if parts := strings.SplitN(string(decoded), ":", 2); len(parts) == 2 {
+				o.Username, o.Password = parts[0], parts[1]
+			}
+		}
+	}
+	return o, nil
 }
 
 func Install(i Implement, values map[string]interface{}) (string, error) {
@@ -34,23 +173,95 @@ func Uninstall(i Implement, values map[string]interface{}) (string, error) {
 	if !ok && len(releaseName) == 0 {
 		return "", errors.New("release name needed")
 	}
-	chartPath, ok := values["chartPath"].(string)
-	if !ok {
-		return "", errors.New("chart path needed")
-	}
 	namespace, ok := values["namespace"].(string)
 	if !ok && len(namespace) == 0 {
 		namespace = "default"
 	}
-	result, err := helmsdk.Uninstall(chartPath, releaseName, namespace)
+	result, err := helmsdk.Uninstall(releaseName, namespace)
 	if err != nil && !i.IgnoreError {
 		return result, err
 	}
 	return result, nil
 }
 
+//Recover  rollback the helm release to the revision deployed last, install or upgrade it when there is none.
 func Recover(i Implement, values map[string]interface{}) (string, error) {
-	return doInstallOrUpgrade(i, values)
+	releaseName, ok := values["releaseName"].(string)
+	if !ok && len(releaseName) == 0 {
+		return "", errors.New("release name needed")
+	}
+	namespace, ok := values["namespace"].(string)
+	if !ok && len(namespace) == 0 {
+		namespace = "default"
+	}
+	revision, err := helmsdk.DeployedRevision(releaseName, namespace)
+	if err != nil {
+		return "", err
+	}
+	if revision == 0 {
+		return doInstallOrUpgrade(i, values)
+	}
+	return helmsdk.Rollback(releaseName, namespace, revision, i.options())
+}
+
+//Rollback  rollback the helm release to its previous revision.
+func Rollback(i Implement, values map[string]interface{}) (string, error) {
+	releaseName, ok := values["releaseName"].(string)
+	if !ok && len(releaseName) == 0 {
+		return "", errors.New("release name needed")
+	}
+	namespace, ok := values["namespace"].(string)
+	if !ok && len(namespace) == 0 {
+		namespace = "default"
+	}
+	return helmsdk.Rollback(releaseName, namespace, 0, i.options())
+}
+
+//Plan  render the chart with values and diff against the release deployed.
+func Plan(i Implement, values map[string]interface{}) (string, error) {
+	releaseName, ok := values["releaseName"].(string)
+	if !ok && len(releaseName) == 0 {
+		return "", errors.New("release name needed")
+	}
+	chartPath, ok := values["chartPath"].(string)
+	if !ok {
+		return "", errors.New("chart path needed")
+	}
+	namespace, ok := values["namespace"].(string)
+	if !ok && len(namespace) == 0 {
+		namespace = "default"
+	}
+	var vals map[string]interface{}
+	if values["chartValues"] != nil {
+		v, err := decodeValues(values["chartValues"])
+		if err != nil {
+			return "", err
+		}
+		vals = v
+	}
+	digest, _ := values["sha256"].(string)
+	chartPathLocal, err := localChart(i, chartPath, namespace, digest)
+	if err != nil {
+		return "", err
+	}
+	return helmsdk.Diff(chartPathLocal, releaseName, namespace, vals, i.options())
+}
+
+//Manifest  return the manifest of the helm release deployed.
+func Manifest(i Implement, values map[string]interface{}) (string, error) {
+	releaseName, ok := values["releaseName"].(string)
+	if !ok && len(releaseName) == 0 {
+		return "", errors.New("release name needed")
+	}
+	namespace, ok := values["namespace"].(string)
+	if !ok && len(namespace) == 0 {
+		namespace = "default"
+	}
+	s, err := helmsdk.Status(releaseName, namespace)
+	if err != nil {
+		return "", err
+	}
+	return s.Manifest, nil
 }
 
 func Status(i Implement, values map[string]interface{}) (string, error) {
@@ -91,32 +302,109 @@ func doInstallOrUpgrade(i Implement, values map[string]interface{}) (string, err
 		vals = v
 	}
 
-	chartPathLocal, err := download.HttpGet(chartPath)
+	digest, _ := values["sha256"].(string)
+	chartPathLocal, err := localChart(i, chartPath, namespace, digest)
 	if err != nil {
 		return "", err
 	}
-	if len(chartPathLocal) == 0 {
-		chartPathLocal, err = helmsdk.LocateChart(chartPath, namespace)
-		if err != nil {
-			return "", err
-		}
-	}
 
-	installed, err := helmsdk.Exist(chartPathLocal, namespace)
+	installed, err := helmsdk.ReleaseExist(releaseName, namespace)
 	if err != nil {
 		return "", err
 	}
-	var timeoutSecond time.Duration
-	if i.Timeout <= 0 {
-		timeoutSecond = 60 * time.Second
+	if installed {
+		return helmsdk.Upgrade(chartPathLocal, releaseName, namespace, vals, i.options())
 	} else {
-		timeoutSecond = time.Duration(i.Timeout) * time.Second
+		return helmsdk.Install(chartPathLocal, releaseName, namespace, vals, i.options())
 	}
-	if installed {
-		return helmsdk.Upgrade(chartPathLocal, releaseName, namespace, vals, i.Wait, timeoutSecond)
+}
+
+//options  return the options of the helm actions, the timeout defaults to 60 seconds.
+func (i Implement) options() helmsdk.Options {
+	o := helmsdk.Options{
+		Wait:            i.Wait,
+		Timeout:         60 * time.Second,
+		Atomic:          i.Atomic,
+		CreateNamespace: i.CreateNamespace,
+		ResetValues:     i.ResetValues,
+		ReuseValues:     i.ReuseValues,
+		MaxHistory:      i.MaxHistory,
+	}
+	if i.Timeout > 0 {
+		o.Timeout = time.Duration(i.Timeout) * time.Second
+	}
+	return o
+}
+
+//keyring  return the path of the keyring to verify the charts with, empty when not verified. The namespace of the
+//keyring secret is resolved when the source is registered.
+func (i Implement) keyring() (string, error) {
+	if i.Verify == nil {
+		return "", nil
+	}
+	ref := i.Verify.KeyringSecretRef
+	if len(ref.Namespace) == 0 {
+		return "", errors.Errorf("namespace of keyring secret %s not resolved", ref.Name)
+	}
+	path := helmsdk.KeyringPath(ref.Namespace, ref.Name)
+	if _, err := os.Stat(path); err != nil {
+		return "", errors.Wrapf(err, "keyring of secret %s/%s not loaded", ref.Namespace, ref.Name)
+	}
+	return path, nil
+}
+
+//localChart  download the chart by http, from helm repositories or OCI registries, return the local chart path. The
+//chart archive is checked against the sha256 digest when set, and verified by its provenance when verify set.
+func localChart(i Implement, chartPath, namespace, digest string) (string, error) {
+	keyring, err := i.keyring()
+	if err != nil {
+		return "", err
+	}
+	var chartPathLocal, provenancePath string
+	if oci.IsOCI(chartPath) {
+		ref, err := oci.ParseReference(chartPath)
+		if err != nil {
+			return "", err
+		}
+		var opts helmsdk.RegistryOptions
+		for _, r := range i.Registries {
+			if r.Host == ref.Registry {
+				opts.PlainHTTP = r.PlainHTTP
+				opts.InsecureSkipTLSVerify = r.InsecureSkipTLSVerify
+			}
+		}
+		chart, err := helmsdk.PullChart(i.SourceKey, chartPath, opts)
+		if err != nil {
+			return "", err
+		}
+		chartPathLocal, provenancePath = chart.Path, chart.ProvenancePath
 	} else {
-		return helmsdk.Install(chartPathLocal, releaseName, namespace, vals, i.Wait, timeoutSecond)
+		if chartPathLocal, err = download.HttpGet(chartPath, digest); err != nil {
+			return "", err
+		}
+		if len(chartPathLocal) == 0 {
+			// Verified by helm when located.
+			if chartPathLocal, err = helmsdk.LocateChart(chartPath, namespace, keyring); err != nil {
+				return "", err
+			}
+			keyring = ""
+		} else if len(keyring) > 0 {
+			if provenancePath, err = download.HttpGet(chartPath+".prov", ""); err != nil {
+				return "", errors.Wrap(err, "download chart provenance failed")
+			}
+		}
+	}
+	if len(digest) > 0 {
+		if err := download.VerifyDigest(chartPathLocal, digest); err != nil {
+			return "", err
+		}
+	}
+	if len(keyring) > 0 {
+		if err := helmsdk.VerifyChart(chartPathLocal, provenancePath, keyring); err != nil {
+			return "", err
+		}
 	}
+	return chartPathLocal, nil
 }
 
 func decodeValues(values interface{}) (map[string]interface{}, error) {
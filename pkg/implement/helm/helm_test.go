@@ -0,0 +1,74 @@
+package helm
+
+import (
+	"cloudnativeapp/clm/pkg/helmsdk"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRepo_Options(t *testing.T) {
+	r := Repo{Name: "repo", Url: "https://charts.example.com", UserName: "inline", PassWord: "inline",
+		InsecureSkipTLSVerify: true}
+	expected := helmsdk.RepoOptions{Username: "inline", Password: "inline", InsecureSkipTLSVerify: true}
+	if o := r.Options(nil); !reflect.DeepEqual(o, expected) {
+		t.Errorf("expected %v, got %v", expected, o)
+	}
+	secret := map[string][]byte{
+		RepoUsernameKey: []byte("user"),
+		RepoPasswordKey: []byte("pass"),
+		RepoCertKey:     []byte("cert"),
+		RepoKeyKey:      []byte("key"),
+		RepoCAKey:       []byte("ca"),
+	}
+	expected = helmsdk.RepoOptions{Username: "user", Password: "pass", CertData: []byte("cert"),
+		KeyData: []byte("key"), CAData: []byte("ca"), InsecureSkipTLSVerify: true}
+	if o := r.Options(secret); !reflect.DeepEqual(o, expected) {
+		t.Errorf("expected %v, got %v", expected, o)
+	}
+}
+
+func TestRegistry_Options(t *testing.T) {
+	r := Registry{Host: "registry.example.com:5000", PlainHTTP: true}
+	o, err := r.Options(map[string][]byte{
+		DockerConfigJsonKey: []byte(`{"auths":{"registry.example.com:5000":{"auth":"dXNlcjpwYXNz"}}}`),
+		RepoCAKey:           []byte("ca"),
+	})
+	expected := helmsdk.RegistryOptions{Username: "user", Password: "pass", CAData: []byte("ca"), PlainHTTP: true}
+	if err != nil || !reflect.DeepEqual(o, expected) {
+		t.Errorf("expected %v, got %v %v", expected, o, err)
+	}
+	o, err = r.Options(map[string][]byte{RepoUsernameKey: []byte("u"), RepoPasswordKey: []byte("p")})
+	if err != nil || o.Username != "u" || o.Password != "p" {
+		t.Errorf("unexpected options %v %v", o, err)
+	}
+	if _, err := r.Options(map[string][]byte{DockerConfigJsonKey: []byte(`{"auths":{}}`)}); err == nil {
+		t.Error("registry absent in docker config accepted")
+	}
+}
+
+func TestImplement_Options(t *testing.T) {
+	o := Implement{Wait: true, Atomic: true, CreateNamespace: true, ResetValues: true, MaxHistory: 5}.options()
+	expected := helmsdk.Options{Wait: true, Timeout: 60 * time.Second, Atomic: true, CreateNamespace: true,
+		ResetValues: true, MaxHistory: 5}
+	if o != expected {
+		t.Errorf("expected %v, got %v", expected, o)
+	}
+	if o := (Implement{Timeout: 120}).options(); o.Timeout != 120*time.Second {
+		t.Errorf("unexpected timeout %v", o.Timeout)
+	}
+}
+
+func TestImplement_Keyring(t *testing.T) {
+	if k, err := (Implement{}).keyring(); err != nil || len(k) > 0 {
+		t.Errorf("unexpected keyring %s %v", k, err)
+	}
+	i := Implement{Verify: &Verify{KeyringSecretRef: SecretReference{Name: "keys"}}}
+	if _, err := i.keyring(); err == nil {
+		t.Error("keyring secret namespace not resolved accepted")
+	}
+	i.Verify.KeyringSecretRef.Namespace = "absent"
+	if _, err := i.keyring(); err == nil {
+		t.Error("keyring not loaded accepted")
+	}
+}
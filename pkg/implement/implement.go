@@ -4,11 +4,16 @@ import (
 	"cloudnativeapp/clm/pkg/implement/helm"
 	"cloudnativeapp/clm/pkg/implement/native"
 	"cloudnativeapp/clm/pkg/implement/service"
+	"cloudnativeapp/clm/pkg/metrics"
 	"cloudnativeapp/clm/pkg/utils"
 	"errors"
 	"fmt"
 	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"net/url"
+	"strings"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"time"
 )
 
 type Implement struct {
@@ -25,11 +30,20 @@ var serviceFuncMap = make(map[string]func(logr.Logger, service.Implement, map[st
 var helmFuncMap = make(map[string]func(helm.Implement, map[string]interface{}) (string, error))
 var nativeFuncMap = make(map[string]func(logr.Logger, native.Implement, map[string]interface{}) (string, error))
 
+const (
+	HelmType    = "helm"
+	NativeType  = "native"
+	ServiceType = "service"
+)
+
 const (
 	Install   = "install"
 	Uninstall = "uninstall"
 	Recover   = "recover"
 	Upgrade   = "upgrade"
+	Rollback  = "rollback"
+	Plan      = "plan"
+	Manifest  = "manifest"
 )
 
 func init() {
@@ -37,50 +51,168 @@ func init() {
 	serviceFuncMap[Uninstall] = service.Uninstall
 	serviceFuncMap[Recover] = service.Recover
 	serviceFuncMap[Upgrade] = service.Upgrade
+	// Rollback by upgrading with the previous values.
+	serviceFuncMap[Rollback] = service.Upgrade
+	serviceFuncMap[Plan] = service.Plan
+	serviceFuncMap[Manifest] = service.Manifest
 
 	helmFuncMap[Install] = helm.Install
 	helmFuncMap[Uninstall] = helm.Uninstall
 	helmFuncMap[Recover] = helm.Recover
 	helmFuncMap[Upgrade] = helm.Upgrade
+	helmFuncMap[Rollback] = helm.Rollback
+	helmFuncMap[Plan] = helm.Plan
+	helmFuncMap[Manifest] = helm.Manifest
 
 	nativeFuncMap[Install] = native.Install
 	nativeFuncMap[Uninstall] = native.Uninstall
 	nativeFuncMap[Recover] = native.Recover
 	nativeFuncMap[Upgrade] = native.Upgrade
+	nativeFuncMap[Rollback] = native.Upgrade
+	nativeFuncMap[Plan] = native.Plan
+	nativeFuncMap[Manifest] = native.Manifest
 }
 
 func (i *Implement) do(action, name, version string, values map[string]interface{}) error {
+	_, err := i.run(action, name, version, values)
+	return err
+}
+
+//run  do the action with the configured backend and return its response.
+func (i *Implement) run(action, name, version string, values map[string]interface{}) (rsp string, err error) {
 	iLog.V(utils.Debug).Info("try to do implement", "action", action, "name", name, "values", values)
+	if mutating(action) {
+		start := time.Now()
+		defer func() {
+			metrics.ObserveSourceAction(i.Type(), action, start, err)
+		}()
+	}
 	if i.LocalService != nil {
 		param := service.GetValuesMap(values, name, version)
 		if s, err := serviceFuncMap[action](iLog, *i.LocalService,
 			param); err != nil {
 			iLog.Error(err, fmt.Sprintf("%s implement by service failed", action))
-			return err
+			return "", err
 		} else {
 			iLog.V(utils.Info).Info(fmt.Sprintf("service %s implement success", action), "rsp", s)
-			return nil
+			return s, nil
 		}
 	}
 	if i.Helm != nil {
 		if s, err := helmFuncMap[action](*i.Helm, values); err != nil {
 			iLog.Error(err, fmt.Sprintf("%s implement by helm failed", action))
-			return err
+			return "", err
 		} else {
 			iLog.V(utils.Info).Info(fmt.Sprintf("helm %s implement success", action), "rsp", s)
-			return nil
+			return s, nil
 		}
 	}
 	if i.Native != nil {
 		if s, err := nativeFuncMap[action](iLog, *i.Native, values); err != nil {
 			iLog.Error(err, fmt.Sprintf("%s implement by native failed", action))
-			return err
+			return "", err
 		} else {
 			iLog.V(utils.Info).Info(fmt.Sprintf("native %s implement success", action), "rsp", s)
-			return nil
+			return s, nil
 		}
 	}
-	return errors.New(utils.ImplementNotFound)
+	return "", errors.New(utils.ImplementNotFound)
+}
+
+//mutating  return true for the actions which change the cluster, only those are recorded in metrics. The read-only
+//actions like manifest are done on every probe period and would dominate the metrics.
+func mutating(action string) bool {
+	switch action {
+	case Install, Upgrade, Uninstall, Recover, Rollback:
+		return true
+	}
+	return false
+}
+
+//Type  return the source type of the configured backend, empty when none or more than one configured.
+func (i *Implement) Type() string {
+	var types []string
+	if i.LocalService != nil {
+		types = append(types, ServiceType)
+	}
+	if i.Helm != nil {
+		types = append(types, HelmType)
+	}
+	if i.Native != nil {
+		types = append(types, NativeType)
+	}
+	if len(types) != 1 {
+		return ""
+	}
+	return types[0]
+}
+
+//Validate  check exactly one backend is configured.
+func (i *Implement) Validate(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	backends := 0
+	if i.LocalService != nil {
+		backends++
+		if len(i.LocalService.Name) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("localService", "name"), ""))
+		}
+	}
+	if i.Helm != nil {
+		backends++
+		repos := make(map[string]bool)
+		for k, r := range i.Helm.Repositories {
+			p := fldPath.Child("helm", "repositories").Index(k)
+			if len(r.Name) == 0 {
+				allErrs = append(allErrs, field.Required(p.Child("name"), ""))
+			} else if repos[r.Name] {
+				allErrs = append(allErrs, field.Duplicate(p.Child("name"), r.Name))
+			}
+			repos[r.Name] = true
+			if u, err := url.Parse(r.Url); err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+				allErrs = append(allErrs, field.Invalid(p.Child("url"), r.Url, "must be an absolute url"))
+			}
+			if r.SecretRef != nil && len(r.SecretRef.Name) == 0 {
+				allErrs = append(allErrs, field.Required(p.Child("secretRef", "name"), ""))
+			}
+		}
+		if i.Helm.ResetValues && i.Helm.ReuseValues {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("helm", "reuseValues"),
+				"may not specify with resetValues"))
+		}
+		if i.Helm.MaxHistory < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("helm", "maxHistory"), i.Helm.MaxHistory,
+				"must be greater than or equal to 0"))
+		}
+		registries := make(map[string]bool)
+		for k, r := range i.Helm.Registries {
+			p := fldPath.Child("helm", "registries").Index(k)
+			if len(r.Host) == 0 {
+				allErrs = append(allErrs, field.Required(p.Child("host"), ""))
+			} else if registries[r.Host] {
+				allErrs = append(allErrs, field.Duplicate(p.Child("host"), r.Host))
+			} else if strings.Contains(r.Host, "/") {
+				allErrs = append(allErrs, field.Invalid(p.Child("host"), r.Host, "must be host[:port]"))
+			}
+			registries[r.Host] = true
+			if r.SecretRef != nil && len(r.SecretRef.Name) == 0 {
+				allErrs = append(allErrs, field.Required(p.Child("secretRef", "name"), ""))
+			}
+		}
+		if i.Helm.Verify != nil && len(i.Helm.Verify.KeyringSecretRef.Name) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("helm", "verify", "keyringSecretRef", "name"), ""))
+		}
+	}
+	if i.Native != nil {
+		backends++
+	}
+	if backends == 0 {
+		allErrs = append(allErrs, field.Required(fldPath,
+			"must specify one of localService, helm and native"))
+	} else if backends > 1 {
+		allErrs = append(allErrs, field.Forbidden(fldPath,
+			"may not specify more than 1 of localService, helm and native"))
+	}
+	return allErrs
 }
 
 func (i *Implement) Install(name, version string, values map[string]interface{}) error {
@@ -98,3 +230,17 @@ func (i *Implement) Recover(name, version string, values map[string]interface{})
 func (i *Implement) Upgrade(name, version string, values map[string]interface{}) error {
 	return i.do(Upgrade, name, version, values)
 }
+
+func (i *Implement) Rollback(name, version string, values map[string]interface{}) error {
+	return i.do(Rollback, name, version, values)
+}
+
+//Plan  return the diff of the manifests rendered against the cluster without installing them.
+func (i *Implement) Plan(name, version string, values map[string]interface{}) (string, error) {
+	return i.run(Plan, name, version, values)
+}
+
+//Manifest  return the yaml manifest of the objects applied by the backend.
+func (i *Implement) Manifest(name, version string, values map[string]interface{}) (string, error) {
+	return i.run(Manifest, name, version, values)
+}
@@ -3,6 +3,8 @@ package native
 import (
 	"cloudnativeapp/clm/pkg/cliruntime"
 	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
 	"strings"
 )
 
@@ -26,6 +28,43 @@ func Recover(log logr.Logger, i Implement, values map[string]interface{}) (strin
 	return applyAction(log, i, values)
 }
 
+//Plan  diff the objects to apply against the live objects.
+func Plan(log logr.Logger, i Implement, values map[string]interface{}) (string, error) {
+	urls, yamls := getUrlAndStream(values)
+	d, err := cliruntime.NewApplyOptions(urls, yamls, i.IgnoreError).Diff()
+	if err != nil {
+		log.Error(err, "native diff error")
+		return "", err
+	}
+	return d, nil
+}
+
+//Manifest  return the objects to apply in yaml, with the namespace they are applied to.
+func Manifest(log logr.Logger, i Implement, values map[string]interface{}) (string, error) {
+	urls, yamls := getUrlAndStream(values)
+	infos, err := cliruntime.NewApplyOptions(urls, yamls, i.IgnoreError).GetObjects()
+	if err != nil {
+		log.Error(err, "native get objects error")
+		return "", err
+	}
+	var docs []string
+	for _, info := range infos {
+		obj, ok := info.Object.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		if len(obj.GetNamespace()) == 0 {
+			obj.SetNamespace(info.Namespace)
+		}
+		b, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, string(b))
+	}
+	return strings.Join(docs, "---\n"), nil
+}
+
 func Status(log logr.Logger, i Implement, values map[string]interface{}) (string, error) {
 	return "", nil
 }
@@ -113,6 +113,16 @@ func Recover(log logr.Logger, svc Implement, params map[string]string) (string,
 	return rsp.Msg, nil
 }
 
+//Plan  the manifests installed by service are unknown, nothing to diff.
+func Plan(log logr.Logger, svc Implement, params map[string]string) (string, error) {
+	return "", nil
+}
+
+//Manifest  the objects applied by service are unknown.
+func Manifest(log logr.Logger, svc Implement, params map[string]string) (string, error) {
+	return "", errors.New("manifest not supported by local service")
+}
+
 func Status(log logr.Logger, svc Implement, params map[string]string) (string, error) {
 	rsp, err := doAction(log, svc.Name, svc.Namespace, params, svc.Status)
 	if err != nil {
@@ -37,7 +37,7 @@ func (in *Implement) DeepCopyInto(out *Implement) {
 	if in.Helm != nil {
 		in, out := &in.Helm, &out.Helm
 		*out = new(helm.Implement)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Native != nil {
 		in, out := &in.Native, &out.Native
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"time"
+)
+
+var (
+	// CRD release phase, 1 for the current phase and 0 for the others.
+	ReleasePhase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "clm_crdrelease_phase",
+		Help: "Phase of the crd release, 1 for the current phase.",
+	}, []string{"release", "phase"})
+	// Module state, 1 for the current state and 0 for the others.
+	ModuleState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "clm_module_state",
+		Help: "State of the crd release module, 1 for the current state.",
+	}, []string{"release", "module", "state"})
+	// Recover count of the module recorded in module status.
+	ModuleRecoverCount = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "clm_module_recover_count",
+		Help: "Times the crd release module recovered.",
+	}, []string{"release", "module"})
+	// Source actions done, install, upgrade, recover, uninstall etc.
+	SourceActions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "clm_source_actions_total",
+		Help: "Total number of actions done by source implement.",
+	}, []string{"source_type", "action"})
+	// Source actions failed.
+	SourceActionFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "clm_source_action_failures_total",
+		Help: "Total number of actions failed by source implement.",
+	}, []string{"source_type", "action"})
+	// Duration of the source actions.
+	SourceActionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "clm_source_action_duration_seconds",
+		Help:    "Duration of the actions done by source implement.",
+		Buckets: []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"source_type", "action"})
+	// Latency of the readiness probes.
+	ProbeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "clm_readiness_probe_duration_seconds",
+		Help:    "Latency of the module readiness probes.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"handler", "result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(ReleasePhase, ModuleState, ModuleRecoverCount, SourceActions,
+		SourceActionFailures, SourceActionDuration, ProbeDuration)
+}
+
+//ObserveSourceAction  count the source action and record its duration.
+func ObserveSourceAction(sourceType, action string, start time.Time, err error) {
+	SourceActions.WithLabelValues(sourceType, action).Inc()
+	if err != nil {
+		SourceActionFailures.WithLabelValues(sourceType, action).Inc()
+	}
+	SourceActionDuration.WithLabelValues(sourceType, action).Observe(time.Since(start).Seconds())
+}
+
+//ObserveProbe  record the latency of the readiness probe.
+func ObserveProbe(handler, result string, start time.Time) {
+	ProbeDuration.WithLabelValues(handler, result).Observe(time.Since(start).Seconds())
+}
+
+//SetReleasePhase  set the current phase of crd release to 1 and the other phases to 0.
+func SetReleasePhase(release, phase string, phases []string) {
+	for _, p := range phases {
+		v := 0.0
+		if p == phase {
+			v = 1
+		}
+		ReleasePhase.WithLabelValues(release, p).Set(v)
+	}
+}
+
+//SetModuleState  set the current state of module to 1 and the other states to 0, record the recover count.
+func SetModuleState(release, module, state string, states []string, recoverCount int) {
+	for _, s := range states {
+		v := 0.0
+		if s == state {
+			v = 1
+		}
+		ModuleState.WithLabelValues(release, module, s).Set(v)
+	}
+	ModuleRecoverCount.WithLabelValues(release, module).Set(float64(recoverCount))
+}
+
+//DeleteModule  delete the metrics of the module.
+func DeleteModule(release, module string, states []string) {
+	for _, s := range states {
+		ModuleState.DeleteLabelValues(release, module, s)
+	}
+	ModuleRecoverCount.DeleteLabelValues(release, module)
+}
+
+//DeleteRelease  delete the metrics of the crd release, the metrics of its modules are deleted by DeleteModule.
+func DeleteRelease(release string, phases []string) {
+	for _, p := range phases {
+		ReleasePhase.DeleteLabelValues(release, p)
+	}
+}
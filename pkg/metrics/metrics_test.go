@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"testing"
+	"time"
+)
+
+func TestObserveSourceAction(t *testing.T) {
+	ObserveSourceAction("helm", "install", time.Now(), nil)
+	ObserveSourceAction("helm", "install", time.Now(), errors.New("failed"))
+	if n := testutil.ToFloat64(SourceActions.WithLabelValues("helm", "install")); n != 2 {
+		t.Errorf("expected 2 actions, got %v", n)
+	}
+	if n := testutil.ToFloat64(SourceActionFailures.WithLabelValues("helm", "install")); n != 1 {
+		t.Errorf("expected 1 failure, got %v", n)
+	}
+}
+
+func TestSetModuleState(t *testing.T) {
+	states := []string{"Running", "Abnormal"}
+	SetModuleState("r", "m", "Abnormal", states, 2)
+	if v := testutil.ToFloat64(ModuleState.WithLabelValues("r", "m", "Abnormal")); v != 1 {
+		t.Errorf("current state should be 1, got %v", v)
+	}
+	if v := testutil.ToFloat64(ModuleState.WithLabelValues("r", "m", "Running")); v != 0 {
+		t.Errorf("other state should be 0, got %v", v)
+	}
+	if v := testutil.ToFloat64(ModuleRecoverCount.WithLabelValues("r", "m")); v != 2 {
+		t.Errorf("expected recover count 2, got %v", v)
+	}
+	DeleteModule("r", "m", states)
+	if n := testutil.CollectAndCount(ModuleState); n != 0 {
+		t.Errorf("module metrics should be deleted, got %d", n)
+	}
+}
@@ -0,0 +1,450 @@
+package oci
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// Scheme of the chart references in OCI registries.
+	Scheme = "oci://"
+	// Media type of the chart layer pushed by helm 3.8 and later.
+	ChartLayerMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	// Media type of the chart layer pushed by the experimental OCI support of helm before 3.8.
+	LegacyChartLayerMediaType = "application/tar+gzip"
+	// Media type of the provenance layer of the signed chart.
+	ProvenanceLayerMediaType = "application/vnd.cncf.helm.chart.provenance.v1.prov"
+
+	manifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	// Timeout of a request to the registry.
+	defaultTimeout = 5 * time.Minute
+)
+
+var digestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+//IsOCI  return true when the chart is referred in an OCI registry.
+func IsOCI(ref string) bool {
+	return strings.HasPrefix(ref, Scheme)
+}
+
+// Reference to a chart in an OCI registry, like oci://registry/repository/chart:tag or
+// oci://registry/repository/chart@sha256:digest.
+type Reference struct {
+	// Host and port of the registry.
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+//ParseReference  parse the oci:// chart reference, a tag or digest is required.
+func ParseReference(ref string) (Reference, error) {
+	if !IsOCI(ref) {
+		return Reference{}, fmt.Errorf("chart reference %s is not %s", ref, Scheme)
+	}
+	s := strings.TrimPrefix(ref, Scheme)
+	i := strings.Index(s, "/")
+	if i <= 0 || i == len(s)-1 {
+		return Reference{}, fmt.Errorf("invalid chart reference %s, registry and repository needed", ref)
+	}
+	r := Reference{Registry: s[:i]}
+	s = s[i+1:]
+	if i := strings.Index(s, "@"); i >= 0 {
+		r.Digest = s[i+1:]
+		s = s[:i]
+		if !digestRegexp.MatchString(r.Digest) {
+			return Reference{}, fmt.Errorf("invalid digest %s of chart reference %s", r.Digest, ref)
+		}
+	}
+	if i := strings.LastIndex(s, ":"); i >= 0 && !strings.Contains(s[i:], "/") {
+		r.Tag = s[i+1:]
+		s = s[:i]
+	}
+	r.Repository = s
+	if len(r.Repository) == 0 || strings.HasSuffix(r.Repository, "/") {
+		return Reference{}, fmt.Errorf("invalid chart reference %s, repository needed", ref)
+	}
+	if len(r.Tag) == 0 && len(r.Digest) == 0 {
+		return Reference{}, fmt.Errorf("chart reference %s needs a tag or digest", ref)
+	}
+	return r, nil
+}
+
+func (r Reference) String() string {
+	s := Scheme + r.Registry + "/" + r.Repository
+	if len(r.Tag) > 0 {
+		s += ":" + r.Tag
+	}
+	if len(r.Digest) > 0 {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// Options to access the registry.
+type Options struct {
+	Username string
+	Password string
+	// Access the registry by http instead of https, for local registries.
+	PlainHTTP bool
+	// Skip the certificate check of the registry server.
+	InsecureSkipTLSVerify bool
+	// PEM encoded CA bundle to verify the registry server.
+	CAData []byte
+}
+
+// Client pulls the charts from OCI registries, the manifests pulled by digest and the chart layers are cached in
+// CacheDir by their digests.
+type Client struct {
+	CacheDir string
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type manifest struct {
+	Layers []descriptor `json:"layers"`
+}
+
+// Chart pulled to the cache.
+type Chart struct {
+	// Path of the chart archive.
+	Path string
+	// Digest of the chart archive, the chart layer digest.
+	Digest string
+	// Path of the provenance file, empty when the chart is not signed.
+	ProvenancePath string
+}
+
+//Pull  pull the chart and its provenance when signed to the cache.
+func (c Client) Pull(ref Reference, opts Options) (Chart, error) {
+	s, err := newSession(ref.Registry, ref.Repository, opts)
+	if err != nil {
+		return Chart{}, err
+	}
+	m, err := c.manifest(s, ref)
+	if err != nil {
+		return Chart{}, err
+	}
+	var chart Chart
+	for _, l := range m.Layers {
+		switch l.MediaType {
+		case ChartLayerMediaType, LegacyChartLayerMediaType:
+			if len(chart.Path) > 0 {
+				continue
+			}
+			if chart.Path, err = c.blob(s, l.Digest); err != nil {
+				return Chart{}, err
+			}
+			chart.Digest = l.Digest
+		case ProvenanceLayerMediaType:
+			if chart.ProvenancePath, err = c.blob(s, l.Digest); err != nil {
+				return Chart{}, err
+			}
+		}
+	}
+	if len(chart.Path) == 0 {
+		return Chart{}, fmt.Errorf("no chart layer found in %s", ref.String())
+	}
+	return chart, nil
+}
+
+//blob  pull the blob to the cache when not cached, return its path.
+func (c Client) blob(s *session, digest string) (string, error) {
+	path, ok, err := c.cached("blobs", digest)
+	if err != nil || ok {
+		return path, err
+	}
+	data, err := s.get("blobs/"+digest, "")
+	if err != nil {
+		return "", err
+	}
+	return c.store("blobs", digest, data)
+}
+
+//Ping  check the registry is reachable and the credentials are accepted.
+func Ping(registry string, opts Options) error {
+	s, err := newSession(registry, "", opts)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(s.base + "/v2/")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("login %s failed: %s", registry, resp.Status)
+	}
+	return nil
+}
+
+//manifest  return the manifest of the chart, the manifest pulled by digest is cached.
+func (c Client) manifest(s *session, ref Reference) (*manifest, error) {
+	var data []byte
+	if len(ref.Digest) > 0 {
+		path, ok, err := c.cached("manifests", ref.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if data, err = ioutil.ReadFile(path); err != nil {
+				return nil, err
+			}
+		} else {
+			if data, err = s.get("manifests/"+ref.Digest, manifestMediaType); err != nil {
+				return nil, err
+			}
+			if _, err := c.store("manifests", ref.Digest, data); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		var err error
+		if data, err = s.get("manifests/"+ref.Tag, manifestMediaType); err != nil {
+			return nil, err
+		}
+	}
+	m := &manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid manifest of %s: %v", ref.String(), err)
+	}
+	return m, nil
+}
+
+func (c Client) path(kind, digest string) string {
+	return filepath.Join(c.CacheDir, kind, strings.Replace(digest, ":", "/", 1))
+}
+
+//cached  return the path of the content cached by digest and whether it exists.
+func (c Client) cached(kind, digest string) (string, bool, error) {
+	if !digestRegexp.MatchString(digest) {
+		return "", false, fmt.Errorf("unsupported digest %s", digest)
+	}
+	path := c.path(kind, digest)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return path, false, nil
+		}
+		return "", false, err
+	}
+	return path, true, nil
+}
+
+//store  verify the data against the digest and store it in the cache, return the path.
+func (c Client) store(kind, digest string, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	if "sha256:"+hex.EncodeToString(sum[:]) != digest {
+		return "", fmt.Errorf("digest mismatch, expected %s", digest)
+	}
+	path := c.path(kind, digest)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".pull-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp.Name(), path)
+}
+
+// session keeps the token to access a repository of the registry.
+type session struct {
+	client     *http.Client
+	base       string
+	repository string
+	opts       Options
+	auth       string
+}
+
+func newSession(registry, repository string, opts Options) (*session, error) {
+	s := &session{base: "https://" + registry, repository: repository, opts: opts}
+	if opts.PlainHTTP {
+		s.base = "http://" + registry
+	}
+	config := &tls.Config{InsecureSkipVerify: opts.InsecureSkipTLSVerify}
+	if len(opts.CAData) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(opts.CAData) {
+			return nil, errors.New("no PEM encoded certificate found in CA data")
+		}
+		config.RootCAs = pool
+	}
+	s.client = &http.Client{Timeout: defaultTimeout,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: config}}
+	return s, nil
+}
+
+//get  get the content of the repository path, like manifests/<tag>.
+func (s *session) get(path, accept string) ([]byte, error) {
+	u := fmt.Sprintf("%s/v2/%s/%s", s.base, s.repository, path)
+	resp, err := s.do(u, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s failed: %s", u, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+//do  send the request, authorize by the challenge of the registry and send it again when unauthorized.
+func (s *session) do(u string, accept ...string) (*http.Response, error) {
+	send := func() (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range accept {
+			if len(a) > 0 {
+				req.Header.Add("Accept", a)
+			}
+		}
+		if len(s.auth) > 0 {
+			req.Header.Set("Authorization", s.auth)
+		}
+		return s.client.Do(req)
+	}
+	resp, err := send()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if err := s.authorize(challenge); err != nil {
+		return nil, err
+	}
+	return send()
+}
+
+//authorize  set the authorization by the basic or bearer challenge.
+func (s *session) authorize(challenge string) error {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if len(s.opts.Username) == 0 {
+			return errors.New("registry requires basic auth, no credentials configured")
+		}
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth(s.opts.Username, s.opts.Password)
+		s.auth = req.Header.Get("Authorization")
+		return nil
+	case "bearer":
+		token, err := s.token(params)
+		if err != nil {
+			return err
+		}
+		s.auth = "Bearer " + token
+		return nil
+	}
+	return fmt.Errorf("unsupported registry auth challenge %q", challenge)
+}
+
+//token  request the token from the realm of the bearer challenge.
+func (s *session) token(params map[string]string) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || len(realm.Host) == 0 {
+		return "", fmt.Errorf("invalid token realm %q", params["realm"])
+	}
+	q := realm.Query()
+	if service, ok := params["service"]; ok {
+		q.Set("service", service)
+	}
+	if scope, ok := params["scope"]; ok {
+		q.Set("scope", scope)
+	} else if len(s.repository) > 0 {
+		q.Set("scope", fmt.Sprintf("repository:%s:pull", s.repository))
+	}
+	realm.RawQuery = q.Encode()
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if len(s.opts.Username) > 0 {
+		req.SetBasicAuth(s.opts.Username, s.opts.Password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("request registry token failed: %s", resp.Status)
+	}
+	var t struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", err
+	}
+	if len(t.Token) > 0 {
+		return t.Token, nil
+	}
+	if len(t.AccessToken) > 0 {
+		return t.AccessToken, nil
+	}
+	return "", errors.New("no token in registry token response")
+}
+
+//parseChallenge  parse the WWW-Authenticate header like Bearer realm="...",service="...".
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := make(map[string]string)
+	challenge = strings.TrimSpace(challenge)
+	i := strings.Index(challenge, " ")
+	if i < 0 {
+		return challenge, params
+	}
+	scheme, rest := challenge[:i], challenge[i+1:]
+	for len(rest) > 0 {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if end := strings.Index(rest, ","); end >= 0 {
+			value, rest = rest[:end], rest[end:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+	}
+	return scheme, params
+}
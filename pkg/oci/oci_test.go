@@ -0,0 +1,187 @@
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	cases := []struct {
+		ref      string
+		expected Reference
+		err      bool
+	}{
+		{"oci://registry.example.com/charts/nginx:1.2.0",
+			Reference{Registry: "registry.example.com", Repository: "charts/nginx", Tag: "1.2.0"}, false},
+		{"oci://localhost:5000/nginx@" + digest,
+			Reference{Registry: "localhost:5000", Repository: "nginx", Digest: digest}, false},
+		{"oci://localhost:5000/nginx:1.0@" + digest,
+			Reference{Registry: "localhost:5000", Repository: "nginx", Tag: "1.0", Digest: digest}, false},
+		{"oci://localhost:5000/nginx", Reference{}, true},
+		{"oci://localhost:5000/nginx@sha256:bad", Reference{}, true},
+		{"oci://nginx:1.0", Reference{}, true},
+		{"https://charts.example.com/nginx-1.0.tgz", Reference{}, true},
+	}
+	for _, c := range cases {
+		r, err := ParseReference(c.ref)
+		if (err != nil) != c.err || r != c.expected {
+			t.Errorf("parse %s expected %v %v, got %v %v", c.ref, c.expected, c.err, r, err)
+		}
+		if err == nil && r.String() != c.ref {
+			t.Errorf("expected %s, got %s", c.ref, r.String())
+		}
+	}
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// registry is a local registry stand-in serving a signed chart by tag and digest, authorized by bearer tokens. The
+// authorized requests are counted by path.
+type registry struct {
+	*httptest.Server
+	chart, prov, manifest []byte
+	requests        map[string]int
+}
+
+func newRegistry(username, password string) *registry {
+	r := &registry{chart: []byte("chart archive"), prov: []byte("provenance"), requests: make(map[string]int)}
+	m := map[string]interface{}{
+		"schemaVersion": 2,
+		"config":        map[string]interface{}{"mediaType": "application/vnd.cncf.helm.config.v1+json"},
+		"layers": []map[string]interface{}{
+			{"mediaType": ChartLayerMediaType, "digest": digestOf(r.chart), "size": len(r.chart)},
+			{"mediaType": ProvenanceLayerMediaType, "digest": digestOf(r.prov), "size": len(r.prov)},
+		},
+	}
+	r.manifest, _ = json.Marshal(m)
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			if u, p, _ := req.BasicAuth(); u != username || p != password {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"token":"t0ken"}`)
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate",
+				fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, "http://"+req.Host))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.requests[req.URL.Path]++
+		switch req.URL.Path {
+		case "/v2/":
+		case "/v2/charts/nginx/manifests/1.0.0", "/v2/charts/nginx/manifests/" + digestOf(r.manifest):
+			w.Header().Set("Content-Type", manifestMediaType)
+			w.Write(r.manifest)
+		default:
+			if req.URL.Path == "/v2/charts/nginx/blobs/"+digestOf(r.prov) {
+				w.Write(r.prov)
+				return
+			}
+			if strings.HasPrefix(req.URL.Path, "/v2/charts/nginx/blobs/") {
+				w.Write(r.chart)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return r
+}
+
+func (r *registry) host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+func TestClient_Pull(t *testing.T) {
+	r := newRegistry("user", "pass")
+	defer r.Close()
+	dir, err := ioutil.TempDir("", "oci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := Client{CacheDir: dir}
+	opts := Options{Username: "user", Password: "pass", PlainHTTP: true}
+
+	if err := Ping(r.host(), opts); err != nil {
+		t.Errorf("ping error: %v", err)
+	}
+	if err := Ping(r.host(), Options{Username: "user", Password: "wrong", PlainHTTP: true}); err == nil {
+		t.Error("wrong credentials accepted")
+	}
+
+	chart, err := c.Pull(Reference{Registry: r.host(), Repository: "charts/nginx", Tag: "1.0.0"}, opts)
+	if err != nil {
+		t.Fatalf("pull by tag error: %v", err)
+	}
+	if data, _ := ioutil.ReadFile(chart.Path); string(data) != string(r.chart) {
+		t.Errorf("unexpected chart pulled: %q", data)
+	}
+	if chart.Digest != digestOf(r.chart) {
+		t.Errorf("unexpected chart digest %s", chart.Digest)
+	}
+	if data, _ := ioutil.ReadFile(chart.ProvenancePath); string(data) != string(r.prov) {
+		t.Errorf("unexpected provenance pulled: %q", data)
+	}
+	if r.requests["/v2/charts/nginx/blobs/"+digestOf(r.chart)] != 1 {
+		t.Errorf("chart blob not pulled once: %v", r.requests)
+	}
+
+	// The manifest by digest and the chart are served from the cache.
+	ref := Reference{Registry: r.host(), Repository: "charts/nginx", Digest: digestOf(r.manifest)}
+	for i := 0; i < 2; i++ {
+		if p, err := c.Pull(ref, opts); err != nil || p != chart {
+			t.Fatalf("pull by digest expected %v, got %v %v", chart, p, err)
+		}
+	}
+	if r.requests["/v2/charts/nginx/manifests/"+digestOf(r.manifest)] != 1 ||
+		r.requests["/v2/charts/nginx/blobs/"+digestOf(r.chart)] != 1 {
+		t.Errorf("cache not used: %v", r.requests)
+	}
+
+	if _, err := c.Pull(Reference{Registry: r.host(), Repository: "charts/absent", Tag: "1.0.0"}, opts); err == nil {
+		t.Error("absent chart pulled")
+	}
+}
+
+func TestClient_PullDigestMismatch(t *testing.T) {
+	r := newRegistry("", "")
+	defer r.Close()
+	dir, err := ioutil.TempDir("", "oci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r.chart = []byte("tampered")
+	_, err = Client{CacheDir: dir}.Pull(Reference{Registry: r.host(), Repository: "charts/nginx", Tag: "1.0.0"},
+		Options{PlainHTTP: true})
+	if err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Errorf("tampered chart pulled: %v", err)
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.example.com/token",service="registry",` +
+		`scope="repository:charts/nginx:pull"`)
+	if scheme != "Bearer" || params["realm"] != "https://auth.example.com/token" ||
+		params["service"] != "registry" || params["scope"] != "repository:charts/nginx:pull" {
+		t.Errorf("unexpected challenge %s %v", scheme, params)
+	}
+	if scheme, _ := parseChallenge(`Basic realm="registry"`); scheme != "Basic" {
+		t.Errorf("unexpected scheme %s", scheme)
+	}
+}
@@ -1,29 +1,198 @@
 package exec
 
 import (
+	"bytes"
 	"cloudnativeapp/clm/pkg/probe"
-	"k8s.io/utils/exec"
+	"cloudnativeapp/clm/pkg/utils"
+	"context"
+	"errors"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/transport/spdy"
+	"k8s.io/client-go/util/exec"
+	"net/http"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sort"
+	"sync"
+	"time"
 )
 
 const (
 	maxReadLength = 10 * 1 << 10 // 10KB
+	// Timeout of the command when the probe does not set one.
+	defaultTimeout = 10 * time.Second
 )
 
-// New creates a Prober.
+// New creates a Prober, the clients are created from the controller config on the first probe.
 func New() Prober {
-	return execProber{}
+	return &execProber{}
 }
 
 // Prober is an interface defining the Probe object for container readiness/liveness checks.
 type Prober interface {
-	Probe(e exec.Cmd) (probe.Result, string, error)
+	Probe(action *probe.ExecAction, timeout time.Duration) (probe.Result, string, error)
 }
 
-type execProber struct{}
+type execProber struct {
+	once   sync.Once
+	config *rest.Config
+	client kubernetes.Interface
+	err    error
+}
+
+// Probe executes a command in the container of the pod selected through the pod exec subresource.
+// Returns the Result status, combined output truncated to maxReadLength, and errors if any.
+func (pr *execProber) Probe(action *probe.ExecAction, timeout time.Duration) (probe.Result, string, error) {
+	pr.once.Do(func() {
+		if pr.config, pr.err = ctrl.GetConfig(); pr.err == nil {
+			pr.client, pr.err = kubernetes.NewForConfig(pr.config)
+		}
+	})
+	if pr.err != nil {
+		return probe.Unknown, "", pr.err
+	}
+	pod, err := selectPod(pr.client, action)
+	if err != nil {
+		return probe.Unknown, "", err
+	}
+	if pod == nil {
+		return probe.Failure, fmt.Sprintf("no running pod matches %v in namespace %s", action.Selector,
+			action.Namespace), nil
+	}
+	container := action.Container
+	if len(container) == 0 {
+		container = pod.Spec.Containers[0].Name
+	}
+	req := pr.client.CoreV1().RESTClient().Post().Resource("pods").Namespace(pod.Namespace).Name(pod.Name).
+		SubResource("exec").VersionedParams(&corev1.PodExecOptions{
+		Container: container,
+		Command:   action.Command,
+		Stdout:    true,
+		Stderr:    true,
+	}, scheme.ParameterCodec)
+	transport, upgrader, err := spdy.RoundTripperFor(pr.config)
+	if err != nil {
+		return probe.Unknown, "", err
+	}
+	conn := &closableUpgrader{Upgrader: upgrader}
+	executor, err := remotecommand.NewSPDYExecutorForTransports(transport, conn, "POST", req.URL())
+	if err != nil {
+		return probe.Unknown, "", err
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	probe.PLog.V(utils.Debug).Info("exec probe", "pod", pod.Name, "container", container, "command", action.Command)
+	out := &limitedWriter{limit: maxReadLength}
+	done := make(chan error, 1)
+	go func() {
+		done <- executor.Stream(remotecommand.StreamOptions{Stdout: out, Stderr: out})
+	}()
+	select {
+	case err = <-done:
+		return exitResult(out.String(), err)
+	case <-time.After(timeout):
+		// Closing the connection ends the stream, or the connection when it is upgraded later.
+		conn.Close()
+		return probe.Failure, fmt.Sprintf("command timed out after %v", timeout), nil
+	}
+}
+
+// closableUpgrader keeps the connection upgraded by the executor, so that the stream can be closed on timeout.
+type closableUpgrader struct {
+	spdy.Upgrader
+	sync.Mutex
+	conn   httpstream.Connection
+	closed bool
+}
+
+func (u *closableUpgrader) NewConnection(resp *http.Response) (httpstream.Connection, error) {
+	conn, err := u.Upgrader.NewConnection(resp)
+	if err != nil {
+		return nil, err
+	}
+	u.Lock()
+	defer u.Unlock()
+	if u.closed {
+		conn.Close()
+		return nil, errors.New("connection closed on timeout")
+	}
+	u.conn = conn
+	return conn, nil
+}
+
+//Close  close the connection upgraded, the connection upgraded after it is closed at once.
+func (u *closableUpgrader) Close() {
+	u.Lock()
+	defer u.Unlock()
+	u.closed = true
+	if u.conn != nil {
+		u.conn.Close()
+	}
+}
+
+//selectPod  return the first running pod by name which matches the selector, nil when none.
+func selectPod(client kubernetes.Interface, action *probe.ExecAction) (*corev1.Pod, error) {
+	pods, err := client.CoreV1().Pods(action.Namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(action.Selector).String()})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+	for i, p := range pods.Items {
+		if p.Status.Phase == corev1.PodRunning && p.GetDeletionTimestamp() == nil && len(p.Spec.Containers) > 0 {
+			return &pods.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+//exitResult  map the exit code of the command to probe result, 0 is success and the others are failure.
+func exitResult(out string, err error) (probe.Result, string, error) {
+	if err == nil {
+		return probe.Success, out, nil
+	}
+	if exitErr, ok := err.(exec.ExitError); ok {
+		probe.PLog.V(utils.Warn).Info("exec probe failed", "exitCode", exitErr.ExitStatus(), "output", out)
+		if len(out) == 0 {
+			out = fmt.Sprintf("command exited with code %d", exitErr.ExitStatus())
+		}
+		return probe.Failure, out, nil
+	}
+	return probe.Unknown, out, err
+}
+
+// limitedWriter keeps the first limit bytes written by stdout and stderr, the rest is dropped.
+type limitedWriter struct {
+	sync.Mutex
+	buf   bytes.Buffer
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	if left := w.limit - w.buf.Len(); left > 0 {
+		if len(p) > left {
+			w.buf.Write(p[:left])
+		} else {
+			w.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
 
-// Probe executes a command to check the liveness/readiness of container
-// from executing a command. Returns the Result status, command output, and
-// errors if any.
-func (pr execProber) Probe(e exec.Cmd) (probe.Result, string, error) {
-	return probe.Success, string(""), nil
+func (w *limitedWriter) String() string {
+	w.Lock()
+	defer w.Unlock()
+	return w.buf.String()
 }
@@ -0,0 +1,97 @@
+package exec
+
+import (
+	"cloudnativeapp/clm/pkg/probe"
+	"errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/exec"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func testPod(name string, phase corev1.PodPhase, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+}
+
+func TestSelectPod(t *testing.T) {
+	app := map[string]string{"app": "web"}
+	client := fake.NewSimpleClientset(
+		testPod("web-c", corev1.PodRunning, app),
+		testPod("web-a", corev1.PodPending, app),
+		testPod("web-b", corev1.PodRunning, app),
+		testPod("db-a", corev1.PodRunning, map[string]string{"app": "db"}))
+	pod, err := selectPod(client, &probe.ExecAction{Namespace: "default", Selector: app})
+	if err != nil || pod == nil || pod.Name != "web-b" {
+		t.Errorf("the first running pod should be selected, got %v %v", pod, err)
+	}
+	pod, err = selectPod(client, &probe.ExecAction{Namespace: "default", Selector: map[string]string{"app": "cache"}})
+	if err != nil || pod != nil {
+		t.Errorf("no pod should be selected, got %v %v", pod, err)
+	}
+}
+
+func TestExitResult(t *testing.T) {
+	if result, out, err := exitResult("ok", nil); result != probe.Success || out != "ok" || err != nil {
+		t.Errorf("exit code 0 should succeed, got %v %q %v", result, out, err)
+	}
+	exitErr := exec.CodeExitError{Err: errors.New("command terminated with exit code 3"), Code: 3}
+	if result, out, err := exitResult("", exitErr); result != probe.Failure || !strings.Contains(out, "3") ||
+		err != nil {
+		t.Errorf("non-zero exit code should fail, got %v %q %v", result, out, err)
+	}
+	if result, _, err := exitResult("", errors.New("connection refused")); result != probe.Unknown || err == nil {
+		t.Errorf("stream error should be unknown, got %v %v", result, err)
+	}
+}
+
+func TestLimitedWriter(t *testing.T) {
+	w := &limitedWriter{limit: 4}
+	w.Write([]byte("abc"))
+	if n, _ := w.Write([]byte("def")); n != 3 || w.String() != "abcd" {
+		t.Errorf("output should be truncated, got %d %q", n, w.String())
+	}
+}
+
+type fakeUpgrader struct {
+	conn httpstream.Connection
+}
+
+func (u fakeUpgrader) NewConnection(*http.Response) (httpstream.Connection, error) {
+	return u.conn, nil
+}
+
+type fakeConnection struct {
+	httpstream.Connection
+	closed bool
+}
+
+func (c *fakeConnection) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestClosableUpgrader(t *testing.T) {
+	conn := &fakeConnection{}
+	u := &closableUpgrader{Upgrader: fakeUpgrader{conn: conn}}
+	if _, err := u.NewConnection(nil); err != nil {
+		t.Fatalf("upgrade connection failed: %v", err)
+	}
+	u.Close()
+	if !conn.closed {
+		t.Errorf("connection should be closed on timeout")
+	}
+	late := &fakeConnection{}
+	u = &closableUpgrader{Upgrader: fakeUpgrader{conn: late}}
+	u.Close()
+	if _, err := u.NewConnection(nil); err == nil || !late.closed {
+		t.Errorf("connection upgraded after timeout should be closed, got %v", err)
+	}
+}
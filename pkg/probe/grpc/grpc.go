@@ -0,0 +1,97 @@
+package grpc
+
+import (
+	"cloudnativeapp/clm/pkg/probe"
+	"cloudnativeapp/clm/pkg/utils"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Timeout of the health check when the probe does not set one.
+const defaultTimeout = 10 * time.Second
+
+// New creates Prober.
+func New() Prober {
+	return grpcProber{}
+}
+
+// Prober is an interface that defines the Probe function for doing gRPC health checks.
+type Prober interface {
+	Probe(host string, port int, service string, tlsConfig *probe.GRPCTLSConfig,
+		timeout time.Duration) (probe.Result, string, error)
+}
+
+type grpcProber struct{}
+
+// Probe returns a ProbeRunner capable of running a gRPC health check.
+func (pr grpcProber) Probe(host string, port int, service string, tlsConfig *probe.GRPCTLSConfig,
+	timeout time.Duration) (probe.Result, string, error) {
+	opts := []grpc.DialOption{grpc.WithBlock()}
+	if tlsConfig == nil {
+		opts = append(opts, grpc.WithInsecure())
+	} else {
+		config, err := buildTLSConfig(host, tlsConfig)
+		if err != nil {
+			return probe.Unknown, "", err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return DoGRPCProbe(net.JoinHostPort(host, strconv.Itoa(port)), service, timeout, opts...)
+}
+
+// DoGRPCProbe checks the health of the service by the grpc.health.v1.Health service at the address.
+// If the serving status is SERVING, it returns Success.
+// If the status is not SERVING, or the connection or the check fails, it returns Failure.
+// This is exported because some other packages may want to do direct gRPC probes.
+func DoGRPCProbe(addr, service string, timeout time.Duration, opts ...grpc.DialOption) (probe.Result, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr, opts...)
+	if err != nil {
+		// Convert errors to failures to handle timeouts.
+		return probe.Failure, fmt.Sprintf("failed to connect %s: %v", addr, err), nil
+	}
+	defer conn.Close()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		if s, ok := status.FromError(err); ok && s.Code() == codes.Unimplemented {
+			return probe.Failure, fmt.Sprintf("server %s does not implement grpc.health.v1.Health", addr), nil
+		}
+		return probe.Failure, fmt.Sprintf("health check failed: %v", err), nil
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		probe.PLog.V(utils.Warn).Info("gRPC probe not serving", "addr", addr, "service", service,
+			"status", resp.Status.String())
+		return probe.Failure, resp.Status.String(), nil
+	}
+	return probe.Success, resp.Status.String(), nil
+}
+
+func buildTLSConfig(host string, c *probe.GRPCTLSConfig) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify, ServerName: c.ServerName}
+	if len(config.ServerName) == 0 {
+		config.ServerName = host
+	}
+	if len(c.CACert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(c.CACert)) {
+			return nil, errors.New("no PEM encoded certificate found in caCert")
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
@@ -0,0 +1,60 @@
+package grpc
+
+import (
+	"cloudnativeapp/clm/pkg/probe"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDoGRPCProbe(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	server := grpc.NewServer()
+	hs := health.NewServer()
+	hs.SetServingStatus("ready", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("draining", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(server, hs)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	tests := []struct {
+		service string
+		want    probe.Result
+	}{
+		{"", probe.Success},
+		{"ready", probe.Success},
+		{"draining", probe.Failure},
+		{"absent", probe.Failure},
+	}
+	for _, test := range tests {
+		result, out, err := DoGRPCProbe(lis.Addr().String(), test.service, time.Second, grpc.WithInsecure(),
+			grpc.WithBlock())
+		if err != nil || result != test.want {
+			t.Errorf("probe service %q: want %v, got %v %q %v", test.service, test.want, result, out, err)
+		}
+	}
+
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := closed.Addr().String()
+	closed.Close()
+	if result, _, err := DoGRPCProbe(addr, "", 200*time.Millisecond, grpc.WithInsecure(),
+		grpc.WithBlock()); err != nil || result != probe.Failure {
+		t.Errorf("probe closed port should fail, got %v %v", result, err)
+	}
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	config, err := buildTLSConfig("svc.default", &probe.GRPCTLSConfig{})
+	if err != nil || config.ServerName != "svc.default" {
+		t.Errorf("server name should default to host, got %v %v", config, err)
+	}
+	if _, err := buildTLSConfig("svc", &probe.GRPCTLSConfig{CACert: "invalid"}); err == nil {
+		t.Errorf("invalid ca cert should fail")
+	}
+}
@@ -0,0 +1,204 @@
+package helm
+
+import (
+	"cloudnativeapp/clm/pkg/helmsdk"
+	"cloudnativeapp/clm/pkg/probe"
+	"cloudnativeapp/clm/pkg/utils"
+	"errors"
+	"fmt"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Timeout of the test hooks when the probe does not set one.
+	defaultTestTimeout = 5 * time.Minute
+	// Backoff of running the failed tests again, doubled by each failure up to maxTestBackoff.
+	testBackoff    = 30 * time.Second
+	maxTestBackoff = 10 * time.Minute
+)
+
+// New creates a Prober checking the releases by helm sdk.
+func New() Prober {
+	return &helmProber{status: helmsdk.Status, test: helmsdk.Test, tested: make(map[string]int),
+		runs: make(map[string]*testRun)}
+}
+
+// Prober is an interface that defines the Probe function for doing helm release checks.
+type Prober interface {
+	Probe(action *probe.HelmAction, timeout time.Duration) (probe.Result, string, error)
+	// Tested returns the revision of the release whose tests passed, 0 when none.
+	Tested(action *probe.HelmAction) int
+	// SetTested records the revision of the release whose tests passed before restart, unless one is known.
+	SetTested(action *probe.HelmAction, revision int)
+	// Forget drops the tests of the release, after it is deleted.
+	Forget(action *probe.HelmAction)
+}
+
+type helmProber struct {
+	status func(releaseName, namespace string) (*release.Release, error)
+	test   func(releaseName, namespace string, timeout time.Duration) (*release.Release, error)
+	sync.Mutex
+	// Revisions of the releases whose tests passed.
+	tested map[string]int
+	// The tests of the releases running or failed.
+	runs map[string]*testRun
+}
+
+// testRun is the run of the tests of a release revision, which is not waited by the probes.
+type testRun struct {
+	revision int
+	running  bool
+	failures int
+	finished time.Time
+	output   string
+}
+
+//backoff  return the time to wait after the failed tests before running them again.
+func (r *testRun) backoff() time.Duration {
+	d := testBackoff
+	for i := 1; i < r.failures && d < maxTestBackoff; i++ {
+		d *= 2
+	}
+	if d > maxTestBackoff {
+		d = maxTestBackoff
+	}
+	return d
+}
+
+// Probe checks the status of the helm release, and runs its test hooks when required.
+// Returns Success when the release is deployed and the tests passed, or Failure with the release status.
+// The tests run in background once for a revision, the probe returns Unknown without error while they are pending,
+// fails after they failed, and the failed tests run again after backoff.
+func (pr *helmProber) Probe(action *probe.HelmAction, timeout time.Duration) (probe.Result, string, error) {
+	namespace := releaseNamespace(action)
+	rel, err := pr.status(action.ReleaseName, namespace)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		pr.Forget(action)
+		return probe.Failure, fmt.Sprintf("release %s/%s not found", namespace, action.ReleaseName), nil
+	} else if err != nil {
+		return probe.Unknown, "", err
+	}
+	if rel.Info == nil {
+		return probe.Unknown, "", fmt.Errorf("release %s/%s has no info", namespace, action.ReleaseName)
+	}
+	if rel.Info.Status != release.StatusDeployed {
+		probe.PLog.V(utils.Warn).Info("helm release not deployed", "release", action.ReleaseName,
+			"namespace", namespace, "status", rel.Info.Status)
+		return probe.Failure, statusOutput(rel), nil
+	}
+	if !action.Test {
+		return probe.Success, statusOutput(rel), nil
+	}
+	key := utils.NamespacedKey(namespace, action.ReleaseName)
+	pr.Lock()
+	defer pr.Unlock()
+	if pr.tested[key] == rel.Version {
+		return probe.Success, statusOutput(rel) + ", tests passed", nil
+	}
+	run, ok := pr.runs[key]
+	if ok && run.revision == rel.Version {
+		if run.running {
+			return probe.Unknown, fmt.Sprintf("revision %d tests running", rel.Version), nil
+		}
+		if wait := run.backoff() - time.Since(run.finished); wait > 0 {
+			return probe.Failure, fmt.Sprintf("%s, run again in %v", run.output, wait.Round(time.Second)), nil
+		}
+	} else {
+		run = &testRun{revision: rel.Version}
+		pr.runs[key] = run
+	}
+	if timeout <= 0 {
+		timeout = defaultTestTimeout
+	}
+	run.running = true
+	go pr.runTest(key, action.ReleaseName, namespace, run, rel, timeout)
+	return probe.Unknown, fmt.Sprintf("revision %d tests started", rel.Version), nil
+}
+
+//runTest  run the tests of the release revision and record the result.
+func (pr *helmProber) runTest(key, releaseName, namespace string, run *testRun, rel *release.Release,
+	timeout time.Duration) {
+	tested, err := pr.test(releaseName, namespace, timeout)
+	if tested == nil {
+		tested = rel
+	}
+	pr.Lock()
+	defer pr.Unlock()
+	run.running = false
+	if pr.runs[key] != run {
+		// The release was deleted.
+		return
+	}
+	if err != nil {
+		probe.PLog.V(utils.Warn).Info("helm test failed", "release", releaseName, "namespace", namespace,
+			"revision", run.revision, "error", err.Error())
+		run.failures++
+		run.finished = time.Now()
+		run.output = fmt.Sprintf("test failed: %v; %s", err, testOutput(tested))
+		return
+	}
+	delete(pr.runs, key)
+	pr.tested[key] = run.revision
+}
+
+func (pr *helmProber) Tested(action *probe.HelmAction) int {
+	pr.Lock()
+	defer pr.Unlock()
+	return pr.tested[utils.NamespacedKey(releaseNamespace(action), action.ReleaseName)]
+}
+
+func (pr *helmProber) SetTested(action *probe.HelmAction, revision int) {
+	key := utils.NamespacedKey(releaseNamespace(action), action.ReleaseName)
+	pr.Lock()
+	defer pr.Unlock()
+	// The revision tested in memory is the latest.
+	if _, ok := pr.tested[key]; !ok && revision > 0 {
+		pr.tested[key] = revision
+	}
+}
+
+func (pr *helmProber) Forget(action *probe.HelmAction) {
+	key := utils.NamespacedKey(releaseNamespace(action), action.ReleaseName)
+	pr.Lock()
+	defer pr.Unlock()
+	delete(pr.tested, key)
+	delete(pr.runs, key)
+}
+
+func releaseNamespace(action *probe.HelmAction) string {
+	if len(action.Namespace) == 0 {
+		return "default"
+	}
+	return action.Namespace
+}
+
+func statusOutput(rel *release.Release) string {
+	out := fmt.Sprintf("revision %d %s", rel.Version, rel.Info.Status)
+	if len(rel.Info.Description) > 0 {
+		out += ": " + rel.Info.Description
+	}
+	return out
+}
+
+//testOutput  return the phases of the test hooks of the release, sorted by name.
+func testOutput(rel *release.Release) string {
+	var results []string
+	for _, h := range rel.Hooks {
+		for _, e := range h.Events {
+			if e == release.HookTest {
+				results = append(results, fmt.Sprintf("%s %s", h.Name, h.LastRun.Phase))
+				break
+			}
+		}
+	}
+	if len(results) == 0 {
+		return "no tests"
+	}
+	sort.Strings(results)
+	return strings.Join(results, ", ")
+}
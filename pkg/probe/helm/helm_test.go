@@ -0,0 +1,123 @@
+package helm
+
+import (
+	"cloudnativeapp/clm/pkg/probe"
+	"errors"
+	"fmt"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testProber(status release.Status, version int, testErr error) (*helmProber, *int) {
+	tests := 0
+	rel := &release.Release{Name: "web", Version: version, Info: &release.Info{Status: status},
+		Hooks: []*release.Hook{{Name: "web-test", Events: []release.HookEvent{release.HookTest},
+			LastRun: release.HookExecution{Phase: release.HookPhaseSucceeded}}}}
+	return &helmProber{
+		status: func(name, namespace string) (*release.Release, error) {
+			if name != "web" {
+				return nil, fmt.Errorf("%w", driver.ErrReleaseNotFound)
+			}
+			return rel, nil
+		},
+		test: func(name, namespace string, timeout time.Duration) (*release.Release, error) {
+			tests++
+			return rel, testErr
+		},
+		tested: make(map[string]int),
+		runs:   make(map[string]*testRun),
+	}, &tests
+}
+
+func TestProbe(t *testing.T) {
+	pr, _ := testProber(release.StatusDeployed, 1, nil)
+	if result, out, err := pr.Probe(&probe.HelmAction{ReleaseName: "web"}, 0); result != probe.Success || err != nil {
+		t.Errorf("deployed release should succeed, got %v %q %v", result, out, err)
+	}
+	if result, out, err := pr.Probe(&probe.HelmAction{ReleaseName: "api"}, 0); result != probe.Failure || err != nil ||
+		!strings.Contains(out, "not found") {
+		t.Errorf("release not found should fail, got %v %q %v", result, out, err)
+	}
+	pr, _ = testProber(release.StatusPendingUpgrade, 2, nil)
+	if result, out, err := pr.Probe(&probe.HelmAction{ReleaseName: "web"}, 0); result != probe.Failure || err != nil ||
+		!strings.Contains(out, "pending-upgrade") {
+		t.Errorf("pending release should fail, got %v %q %v", result, out, err)
+	}
+}
+
+//wait  wait for the tests running in background.
+func wait(t *testing.T, pr *helmProber) {
+	for i := 0; i < 100; i++ {
+		pr.Lock()
+		running := false
+		for _, r := range pr.runs {
+			running = running || r.running
+		}
+		pr.Unlock()
+		if !running {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("tests not finished")
+}
+
+func TestProbeTest(t *testing.T) {
+	pr, tests := testProber(release.StatusDeployed, 1, nil)
+	action := &probe.HelmAction{ReleaseName: "web", Test: true}
+	if result, out, err := pr.Probe(action, 0); result != probe.Unknown || err != nil ||
+		!strings.Contains(out, "tests started") {
+		t.Errorf("release should be unknown until tests finish, got %v %q %v", result, out, err)
+	}
+	wait(t, pr)
+	for i := 0; i < 2; i++ {
+		if result, out, err := pr.Probe(action, 0); result != probe.Success || err != nil {
+			t.Errorf("release tests passed should succeed, got %v %q %v", result, out, err)
+		}
+	}
+	if *tests != 1 || pr.Tested(action) != 1 {
+		t.Errorf("tests should run once for a revision, ran %d, tested %d", *tests, pr.Tested(action))
+	}
+
+	pr, tests = testProber(release.StatusDeployed, 1, errors.New("1 test failed"))
+	pr.Probe(action, 0)
+	wait(t, pr)
+	if result, out, err := pr.Probe(action, 0); result != probe.Failure || err != nil ||
+		!strings.Contains(out, "1 test failed") || !strings.Contains(out, "run again in") {
+		t.Errorf("release tests failed should fail, got %v %q %v", result, out, err)
+	}
+	if *tests != 1 {
+		t.Errorf("failed tests should not run again before backoff, ran %d", *tests)
+	}
+	pr.runs["default/web"].finished = time.Now().Add(-testBackoff)
+	if result, out, _ := pr.Probe(action, 0); result != probe.Unknown || !strings.Contains(out, "tests started") {
+		t.Errorf("failed tests should run again after backoff, got %v %q", result, out)
+	}
+	wait(t, pr)
+	if r := pr.runs["default/web"]; *tests != 2 || r.failures != 2 || r.backoff() != 2*testBackoff {
+		t.Errorf("failed tests should back off, ran %d, run %+v", *tests, r)
+	}
+
+	// The revision tested before restart is not tested again.
+	pr, tests = testProber(release.StatusDeployed, 3, nil)
+	pr.SetTested(action, 3)
+	if result, out, err := pr.Probe(action, 0); result != probe.Success || err != nil || *tests != 0 {
+		t.Errorf("release tested should succeed without tests, got %v %q %v, ran %d", result, out, err, *tests)
+	}
+	pr.Forget(action)
+	if pr.Tested(action) != 0 {
+		t.Errorf("tests of the release deleted should be dropped")
+	}
+	pr.Probe(action, 0)
+	pr.status = func(name, namespace string) (*release.Release, error) {
+		return nil, fmt.Errorf("%w", driver.ErrReleaseNotFound)
+	}
+	wait(t, pr)
+	pr.Probe(action, 0)
+	if len(pr.runs) != 0 || len(pr.tested) != 0 {
+		t.Errorf("tests of the release not found should be dropped, runs %v, tested %v", pr.runs, pr.tested)
+	}
+}
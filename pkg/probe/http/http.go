@@ -20,6 +20,8 @@ import (
 
 const (
 	maxRespBodyLength = 10 * 1 << 10 // 10KB
+	// Output of the ready service in Legacy mode.
+	ready = "ready"
 )
 
 type ResponseData struct {
@@ -53,7 +55,8 @@ func NewWithTLSConfig(config *tls.Config, followNonLocalRedirects bool) Prober {
 
 // Prober is an interface that defines the Probe function for doing HTTP readiness/liveness checks.
 type Prober interface {
-	Probe(url *url.URL, headers http.Header, timeout time.Duration) (probe.Result, string, error)
+	Probe(url *url.URL, headers http.Header, success *probe.HTTPSuccessCriteria,
+		timeout time.Duration) (probe.Result, string, error)
 }
 
 type httpProber struct {
@@ -62,13 +65,14 @@ type httpProber struct {
 }
 
 // Probe returns a ProbeRunner capable of running an HTTP check.
-func (pr httpProber) Probe(url *url.URL, headers http.Header, timeout time.Duration) (probe.Result, string, error) {
+func (pr httpProber) Probe(url *url.URL, headers http.Header, success *probe.HTTPSuccessCriteria,
+	timeout time.Duration) (probe.Result, string, error) {
 	client := &http.Client{
 		Timeout:       timeout,
 		Transport:     pr.transport,
 		CheckRedirect: redirectChecker(pr.followNonLocalRedirects),
 	}
-	return DoHTTPProbe(url, headers, client)
+	return DoHTTPProbe(url, headers, success, client)
 }
 
 // GetHTTPInterface is an interface for making HTTP requests, that returns a response and error.
@@ -77,10 +81,11 @@ type GetHTTPInterface interface {
 }
 
 // DoHTTPProbe checks if a GET request to the url succeeds.
-// If the HTTP response code is successful (i.e. 400 > code >= 200), it returns Success.
-// If the HTTP response code is unsuccessful or HTTP communication fails, it returns Failure.
+// If the HTTP response meets the success criteria, Legacy mode when nil, it returns Success.
+// If the HTTP response does not meet the criteria or HTTP communication fails, it returns Failure.
 // This is exported because some other packages may want to do direct HTTP probes.
-func DoHTTPProbe(url *url.URL, headers http.Header, client GetHTTPInterface) (probe.Result, string, error) {
+func DoHTTPProbe(url *url.URL, headers http.Header, success *probe.HTTPSuccessCriteria,
+	client GetHTTPInterface) (probe.Result, string, error) {
 	req, err := http.NewRequest("GET", url.String(), nil)
 	if err != nil {
 		// Convert errors into failures to catch timeouts.
@@ -112,6 +117,22 @@ func DoHTTPProbe(url *url.URL, headers http.Header, client GetHTTPInterface) (pr
 			return probe.Failure, "", err
 		}
 	}
+	mode := probe.HTTPSuccessLegacy
+	if success != nil && len(success.Mode) > 0 {
+		mode = success.Mode
+	}
+	switch mode {
+	case probe.HTTPSuccessAny2xx:
+		return checkAny2xx(url, res, b)
+	case probe.HTTPSuccessMatch:
+		return checkMatch(url, res, b, *success)
+	}
+	return checkLegacy(url, headers, res, b)
+}
+
+//checkLegacy  the response code is 2xx or 3xx, and the data of {code,msg,data,success} envelope, or the body when
+//it is not the envelope, is "ready".
+func checkLegacy(url *url.URL, headers http.Header, res *http.Response, b []byte) (probe.Result, string, error) {
 	body := string(b)
 	if res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusBadRequest {
 		var out string
@@ -132,6 +153,10 @@ func DoHTTPProbe(url *url.URL, headers http.Header, client GetHTTPInterface) (pr
 
 			return probe.Warning, out, nil
 		}
+		if strings.ToLower(out) != ready {
+			probe.PLog.V(utils.Warn).Info(fmt.Sprintf("Probe not ready for %s, output: %s", url.String(), out))
+			return probe.Failure, out, nil
+		}
 		probe.PLog.V(utils.Info).Info(fmt.Sprintf("Probe succeeded for %s, Response: %v", url.String(), *res))
 		return probe.Success, out, nil
 	}
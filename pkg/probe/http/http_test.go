@@ -1,8 +1,12 @@
 package http
 
 import (
+	"cloudnativeapp/clm/pkg/probe"
 	"encoding/json"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 )
 
@@ -14,3 +18,53 @@ func TestDoHTTPProbe(t *testing.T) {
 		log.Printf("%v", err)
 	}
 }
+
+func TestDoHTTPProbe_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/legacy":
+			w.Write([]byte(`{"code":200,"msg":"","data":"Ready","success":true}`))
+		case "/abnormal":
+			w.Write([]byte(`{"code":200,"msg":"","data":"Abnormal","success":true}`))
+		case "/status":
+			w.Write([]byte(`{"status":{"phase":"Running"},"version":"1.2.0"}`))
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path    string
+		success *probe.HTTPSuccessCriteria
+		want    probe.Result
+	}{
+		{"/legacy", nil, probe.Success},
+		{"/abnormal", nil, probe.Failure},
+		{"/empty", nil, probe.Failure},
+		{"/empty", &probe.HTTPSuccessCriteria{Mode: probe.HTTPSuccessAny2xx}, probe.Success},
+		{"/unavailable", &probe.HTTPSuccessCriteria{Mode: probe.HTTPSuccessAny2xx}, probe.Failure},
+		{"/unavailable", &probe.HTTPSuccessCriteria{Mode: probe.HTTPSuccessMatch, StatusCodes: []string{"500-599"}},
+			probe.Success},
+		{"/status", &probe.HTTPSuccessCriteria{Mode: probe.HTTPSuccessMatch, JSONPath: ".status.phase",
+			Value: "Running"}, probe.Success},
+		{"/status", &probe.HTTPSuccessCriteria{Mode: probe.HTTPSuccessMatch, JSONPath: "{.status.phase}",
+			Value: "Pending"}, probe.Failure},
+		{"/status", &probe.HTTPSuccessCriteria{Mode: probe.HTTPSuccessMatch, JSONPath: ".status.ready"},
+			probe.Failure},
+		{"/status", &probe.HTTPSuccessCriteria{Mode: probe.HTTPSuccessMatch, BodyRegex: `"version":"1\.\d+`},
+			probe.Success},
+		{"/empty", &probe.HTTPSuccessCriteria{Mode: probe.HTTPSuccessMatch, JSONPath: ".status"}, probe.Failure},
+	}
+	for _, test := range tests {
+		u, _ := url.Parse(server.URL + test.path)
+		result, out, err := DoHTTPProbe(u, nil, test.success, http.DefaultClient)
+		if err != nil {
+			t.Errorf("probe %s %+v error: %v", test.path, test.success, err)
+		} else if result != test.want {
+			t.Errorf("probe %s %+v: want %v, got %v %q", test.path, test.success, test.want, result, out)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"bytes"
+	"cloudnativeapp/clm/pkg/probe"
+	"cloudnativeapp/clm/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"k8s.io/client-go/util/jsonpath"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+//checkAny2xx  the response code is 2xx.
+func checkAny2xx(url *url.URL, res *http.Response, b []byte) (probe.Result, string, error) {
+	if res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusMultipleChoices {
+		probe.PLog.V(utils.Info).Info(fmt.Sprintf("Probe succeeded for %s, Response: %v", url.String(), *res))
+		return probe.Success, string(b), nil
+	}
+	probe.PLog.V(utils.Warn).Info(fmt.Sprintf("Probe failed for %s, response body: %s", url.String(), string(b)))
+	return probe.Failure, fmt.Sprintf("HTTP probe failed with statuscode: %d", res.StatusCode), nil
+}
+
+//checkMatch  the response matches all the criteria set: status codes, JSONPath value and body regex.
+func checkMatch(url *url.URL, res *http.Response, b []byte, c probe.HTTPSuccessCriteria) (probe.Result, string, error) {
+	ranges, err := c.StatusCodeRanges()
+	if err != nil {
+		return probe.Unknown, "", err
+	}
+	inRange := false
+	for _, r := range ranges {
+		if res.StatusCode >= r[0] && res.StatusCode <= r[1] {
+			inRange = true
+			break
+		}
+	}
+	if !inRange {
+		probe.PLog.V(utils.Warn).Info(fmt.Sprintf("Probe failed for %s, response body: %s", url.String(), string(b)))
+		return probe.Failure, fmt.Sprintf("HTTP probe failed with statuscode: %d", res.StatusCode), nil
+	}
+
+	if len(c.JSONPath) > 0 {
+		j := jsonpath.New("success")
+		if err := j.Parse(c.JSONPathTemplate()); err != nil {
+			return probe.Unknown, "", err
+		}
+		var data interface{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			return probe.Failure, fmt.Sprintf("response body is not json: %v", err), nil
+		}
+		buf := new(bytes.Buffer)
+		if err := j.Execute(buf, data); err != nil {
+			return probe.Failure, fmt.Sprintf("jsonPath %s: %v", c.JSONPath, err), nil
+		}
+		got := buf.String()
+		if (len(c.Value) == 0 && len(got) == 0) || (len(c.Value) > 0 && got != c.Value) {
+			probe.PLog.V(utils.Warn).Info(fmt.Sprintf("Probe failed for %s, jsonPath %s got %q", url.String(),
+				c.JSONPath, got))
+			return probe.Failure, fmt.Sprintf("jsonPath %s got %q, expected %q", c.JSONPath, got, c.Value), nil
+		}
+	}
+
+	if len(c.BodyRegex) > 0 {
+		re, err := regexp.Compile(c.BodyRegex)
+		if err != nil {
+			return probe.Unknown, "", err
+		}
+		if !re.Match(b) {
+			probe.PLog.V(utils.Warn).Info(fmt.Sprintf("Probe failed for %s, response body: %s", url.String(), string(b)))
+			return probe.Failure, fmt.Sprintf("response body does not match %q", c.BodyRegex), nil
+		}
+	}
+	probe.PLog.V(utils.Info).Info(fmt.Sprintf("Probe succeeded for %s, Response: %v", url.String(), *res))
+	return probe.Success, string(b), nil
+}
@@ -1,8 +1,11 @@
 package probe
 
 import (
+	"fmt"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"strconv"
+	"strings"
 )
 
 // Result is a string used to handle the results for probing container readiness/liveness
@@ -37,6 +40,128 @@ type Handler struct {
 	// TODO: implement a realistic TCP lifecycle hook
 	// +optional
 	TCPSocket *TCPSocketAction `json:"tcpSocket,omitempty" protobuf:"bytes,3,opt,name=tcpSocket"`
+	// Exec specifies a command run in a container of the pod selected.
+	// +optional
+	Exec *ExecAction `json:"exec,omitempty"`
+	// GRPC specifies a health check of the grpc.health.v1.Health service.
+	// +optional
+	GRPC *GRPCAction `json:"grpc,omitempty"`
+	// Workloads specifies the Deployments, StatefulSets, DaemonSets and Jobs whose rollout should be complete.
+	// +optional
+	Workloads *WorkloadsAction `json:"workloads,omitempty"`
+	// Helm specifies the status check of the helm release, and optionally its test hooks.
+	// +optional
+	Helm *HelmAction `json:"helm,omitempty"`
+}
+
+// HelmAction describes the status check of a helm release. The release installed by the module source is checked
+// when the release name is omitted.
+type HelmAction struct {
+	// Name of the helm release.
+	// +optional
+	ReleaseName string `json:"releaseName,omitempty"`
+	// Namespace of the helm release, defaults to "default".
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Run the test hooks of the chart once for each revision deployed, the probe fails until the tests pass.
+	// +optional
+	Test bool `json:"test,omitempty"`
+}
+
+const (
+	WorkloadDeployment  = "Deployment"
+	WorkloadStatefulSet = "StatefulSet"
+	WorkloadDaemonSet   = "DaemonSet"
+	WorkloadJob         = "Job"
+)
+
+// WorkloadKinds are the kinds of workloads whose rollout status can be checked.
+var WorkloadKinds = []string{WorkloadDeployment, WorkloadStatefulSet, WorkloadDaemonSet, WorkloadJob}
+
+// WorkloadsAction describes the workloads whose rollout should be complete. The workloads applied by the module
+// source are checked when neither objects nor selector is set.
+type WorkloadsAction struct {
+	// Workloads to check.
+	// +optional
+	Objects []WorkloadReference `json:"objects,omitempty"`
+	// Namespace of the workloads selected.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Labels of the workloads, all the Deployments, StatefulSets, DaemonSets and Jobs matched are checked.
+	// +optional
+	Selector map[string]string `json:"selector,omitempty"`
+}
+
+type WorkloadReference struct {
+	// Deployment, StatefulSet, DaemonSet or Job.
+	Kind string `json:"kind"`
+	// Name of the workload.
+	Name string `json:"name"`
+	// Namespace of the workload.
+	Namespace string `json:"namespace"`
+}
+
+//String  return the kind and namespaced name of the workload.
+func (w WorkloadReference) String() string {
+	return w.Kind + " " + w.Namespace + "/" + w.Name
+}
+
+//IsWorkloadKind  return true when the rollout status of the kind can be checked.
+func IsWorkloadKind(kind string) bool {
+	for _, k := range WorkloadKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+//FromSource  return true when the workloads should be resolved from the objects applied by the module source.
+func (w WorkloadsAction) FromSource() bool {
+	return len(w.Objects) == 0 && len(w.Selector) == 0
+}
+
+// GRPCAction describes a health check of the standard grpc.health.v1.Health service.
+type GRPCAction struct {
+	// Number or name of the port to access.
+	// Number must be in the range 1 to 65535.
+	Port intstr.IntOrString `json:"port"`
+	// Host name to connect to.
+	Host string `json:"host,omitempty"`
+	// Optional: Set namespace when host as service name.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Service name in the health check request, the health of the whole server when omitted.
+	// +optional
+	Service string `json:"service,omitempty"`
+	// TLS to connect with, plaintext when omitted.
+	// +optional
+	TLS *GRPCTLSConfig `json:"tls,omitempty"`
+}
+
+type GRPCTLSConfig struct {
+	// Skip the verification of the server certificate.
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+	// Server name to verify the certificate with, defaults to the host.
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+	// PEM encoded CA certificates to verify the server certificate, the system roots when omitted.
+	// +optional
+	CACert string `json:"caCert,omitempty"`
+}
+
+// ExecAction describes a command run in a container through the pod exec subresource.
+type ExecAction struct {
+	// Command to run in the container, it is not run in a shell. Exit code 0 is success and the others are failure.
+	Command []string `json:"command"`
+	// Namespace of the pod.
+	Namespace string `json:"namespace"`
+	// Labels of the pod, the command runs in the first running pod by name.
+	Selector map[string]string `json:"selector"`
+	// Container to run the command, defaults to the first container of the pod.
+	// +optional
+	Container string `json:"container,omitempty"`
 }
 
 type HTTPGetAction struct {
@@ -61,6 +186,41 @@ type HTTPGetAction struct {
 	// Custom headers to set in the request. HTTP allows repeated headers.
 	// +optional
 	HTTPHeaders []HTTPHeader `json:"httpHeaders,omitempty" protobuf:"bytes,5,rep,name=httpHeaders"`
+	// Criteria of a successful probe, the Legacy mode when omitted.
+	// +optional
+	Success *HTTPSuccessCriteria `json:"success,omitempty"`
+}
+
+type HTTPSuccessMode string
+
+const (
+	// The response code is 2xx or 3xx, and the data of the {code,msg,data,success} envelope, or the response body when
+	// it is not the envelope, is "ready".
+	HTTPSuccessLegacy HTTPSuccessMode = "Legacy"
+	// The response code is 2xx.
+	HTTPSuccessAny2xx HTTPSuccessMode = "Any2xx"
+	// The response matches all the criteria set.
+	HTTPSuccessMatch HTTPSuccessMode = "Match"
+)
+
+// HTTPSuccessCriteria describes the response of a successful http probe.
+type HTTPSuccessCriteria struct {
+	// Legacy, Any2xx or Match, defaults to Legacy.
+	// +optional
+	Mode HTTPSuccessMode `json:"mode,omitempty"`
+	// Status codes like "200" or ranges like "200-299" the response code should be in, defaults to 200-299.
+	// Match mode only.
+	// +optional
+	StatusCodes []string `json:"statusCodes,omitempty"`
+	// JSONPath expression evaluated on the response body, e.g. {.status.phase}. Match mode only.
+	// +optional
+	JSONPath string `json:"jsonPath,omitempty"`
+	// Expected result of the JSONPath expression, any non-empty result when omitted.
+	// +optional
+	Value string `json:"value,omitempty"`
+	// Regular expression the response body should match. Match mode only.
+	// +optional
+	BodyRegex string `json:"bodyRegex,omitempty"`
 }
 
 // TCPSocketAction describes an action based on opening a socket
@@ -86,4 +246,116 @@ type HTTPHeader struct {
 
 type URIScheme string
 
+const (
+	// URISchemeHTTP means that the scheme used will be http://
+	URISchemeHTTP URIScheme = "HTTP"
+	// URISchemeHTTPS means that the scheme used will be https://
+	URISchemeHTTPS URIScheme = "HTTPS"
+)
+
+const (
+	// MinPeriodSeconds is the shortest interval between two probes.
+	MinPeriodSeconds = 3
+	// MinThreshold is the lowest value of success, failure and recover thresholds.
+	MinThreshold = 1
+)
+
+//StatusCodeRanges  parse the status codes and ranges of the criteria, 200-299 when omitted.
+func (c HTTPSuccessCriteria) StatusCodeRanges() ([][2]int, error) {
+	if len(c.StatusCodes) == 0 {
+		return [][2]int{{200, 299}}, nil
+	}
+	var ranges [][2]int
+	for _, s := range c.StatusCodes {
+		bounds := strings.SplitN(s, "-", 2)
+		from, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code %q", s)
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil {
+				return nil, fmt.Errorf("invalid status code range %q", s)
+			}
+		}
+		if from < 100 || to > 599 || from > to {
+			return nil, fmt.Errorf("status code range %q out of 100-599", s)
+		}
+		ranges = append(ranges, [2]int{from, to})
+	}
+	return ranges, nil
+}
+
+//JSONPathTemplate  return the JSONPath expression in braces as the kubectl jsonpath template.
+func (c HTTPSuccessCriteria) JSONPathTemplate() string {
+	if strings.HasPrefix(c.JSONPath, "{") {
+		return c.JSONPath
+	}
+	return "{" + c.JSONPath + "}"
+}
+
+//Type  return the name of the handler configured, empty when none.
+func (h Handler) Type() string {
+	switch {
+	case h.HTTPGet != nil:
+		return "httpGet"
+	case h.TCPSocket != nil:
+		return "tcpSocket"
+	case h.Exec != nil:
+		return "exec"
+	case h.GRPC != nil:
+		return "grpc"
+	case h.Workloads != nil:
+		return "workloads"
+	case h.Helm != nil:
+		return "helm"
+	}
+	return ""
+}
+
+//Default  set the empty probe settings to the values used by readiness check.
+func (p *Probe) Default() {
+	if p.HTTPGet == nil && p.TCPSocket == nil && p.Exec == nil && p.GRPC == nil &&
+		p.Workloads == nil && p.Helm == nil {
+		// No readiness setting, module turns to running directly.
+		return
+	}
+	if p.PeriodSeconds < MinPeriodSeconds {
+		p.PeriodSeconds = MinPeriodSeconds
+	}
+	if p.SuccessThreshold < MinThreshold {
+		p.SuccessThreshold = MinThreshold
+	}
+	if p.FailureThreshold < MinThreshold {
+		p.FailureThreshold = MinThreshold
+	}
+	if p.RecoverThreshold < MinThreshold {
+		p.RecoverThreshold = MinThreshold
+	}
+	if p.HTTPGet != nil && len(p.HTTPGet.Scheme) == 0 {
+		p.HTTPGet.Scheme = URISchemeHTTP
+	}
+}
+
+//ExtractPort  return the port number of int or numeric string, the port must be in the range 1 to 65535.
+func ExtractPort(param intstr.IntOrString) (int, error) {
+	port := -1
+	switch param.Type {
+	case intstr.Int:
+		port = param.IntValue()
+	case intstr.String:
+		p, err := strconv.Atoi(param.StrVal)
+		if err != nil {
+			return -1, err
+		}
+		port = p
+	default:
+		return port, fmt.Errorf("intOrString had no kind: %+v", param)
+	}
+	if port > 0 && port < 65536 {
+		return port, nil
+	}
+	return port, fmt.Errorf("invalid port number: %v", port)
+}
+
 var PLog = ctrl.Log.WithName("probe")
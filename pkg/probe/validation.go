@@ -0,0 +1,189 @@
+package probe
+
+import (
+	"crypto/x509"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/util/jsonpath"
+	"regexp"
+	"strings"
+)
+
+//Validate  check the probe settings which would otherwise fail only when the probe runs.
+func (p Probe) Validate(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if p.RecoverThreshold < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("recoverThreshold"), p.RecoverThreshold,
+			"must be greater than or equal to 0"))
+	}
+	if p.SuccessThreshold < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("successThreshold"), p.SuccessThreshold,
+			"must be greater than or equal to 0"))
+	}
+	if p.FailureThreshold < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("failureThreshold"), p.FailureThreshold,
+			"must be greater than or equal to 0"))
+	}
+	if p.PeriodSeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("periodSeconds"), p.PeriodSeconds,
+			"must be greater than or equal to 0"))
+	}
+	if p.TimeoutSeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("timeoutSeconds"), p.TimeoutSeconds,
+			"must be greater than or equal to 0"))
+	}
+
+	handlers := 0
+	if p.HTTPGet != nil {
+		handlers++
+		allErrs = append(allErrs, validateHTTPGetAction(p.HTTPGet, fldPath.Child("httpGet"))...)
+	}
+	if p.TCPSocket != nil {
+		handlers++
+		allErrs = append(allErrs, validateTCPSocketAction(p.TCPSocket, fldPath.Child("tcpSocket"))...)
+	}
+	if p.Exec != nil {
+		handlers++
+		allErrs = append(allErrs, validateExecAction(p.Exec, fldPath.Child("exec"))...)
+	}
+	if p.GRPC != nil {
+		handlers++
+		allErrs = append(allErrs, validateGRPCAction(p.GRPC, fldPath.Child("grpc"))...)
+	}
+	if p.Workloads != nil {
+		handlers++
+		allErrs = append(allErrs, validateWorkloadsAction(p.Workloads, fldPath.Child("workloads"))...)
+	}
+	if p.Helm != nil {
+		handlers++
+		if len(p.Helm.Namespace) > 0 && len(p.Helm.ReleaseName) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("helm", "releaseName"), "namespace is set"))
+		}
+	}
+	if handlers > 1 {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "may not specify more than 1 handler type"))
+	} else if handlers == 0 && p != (Probe{}) {
+		allErrs = append(allErrs, field.Required(fldPath, "must specify a handler type"))
+	}
+	return allErrs
+}
+
+func validateHTTPGetAction(action *HTTPGetAction, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(action.Host) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("host"), ""))
+	}
+	if _, err := ExtractPort(action.Port); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("port"), action.Port.String(), err.Error()))
+	}
+	switch URIScheme(strings.ToUpper(string(action.Scheme))) {
+	case "", URISchemeHTTP, URISchemeHTTPS:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("scheme"), action.Scheme,
+			[]string{string(URISchemeHTTP), string(URISchemeHTTPS)}))
+	}
+	if action.Success != nil {
+		allErrs = append(allErrs, validateHTTPSuccessCriteria(action.Success, fldPath.Child("success"))...)
+	}
+	return allErrs
+}
+
+func validateHTTPSuccessCriteria(c *HTTPSuccessCriteria, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	switch c.Mode {
+	case "", HTTPSuccessLegacy, HTTPSuccessAny2xx:
+		if len(c.StatusCodes) > 0 || len(c.JSONPath) > 0 || len(c.Value) > 0 || len(c.BodyRegex) > 0 {
+			allErrs = append(allErrs, field.Forbidden(fldPath, "criteria may only be set in Match mode"))
+		}
+		return allErrs
+	case HTTPSuccessMatch:
+	default:
+		return append(allErrs, field.NotSupported(fldPath.Child("mode"), c.Mode,
+			[]string{string(HTTPSuccessLegacy), string(HTTPSuccessAny2xx), string(HTTPSuccessMatch)}))
+	}
+	if _, err := c.StatusCodeRanges(); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("statusCodes"), c.StatusCodes, err.Error()))
+	}
+	if len(c.JSONPath) > 0 {
+		if err := jsonpath.New("success").Parse(c.JSONPathTemplate()); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("jsonPath"), c.JSONPath, err.Error()))
+		}
+	} else if len(c.Value) > 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("jsonPath"), "value is set"))
+	}
+	if len(c.BodyRegex) > 0 {
+		if _, err := regexp.Compile(c.BodyRegex); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("bodyRegex"), c.BodyRegex, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+func validateTCPSocketAction(action *TCPSocketAction, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(action.Host) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("host"), ""))
+	}
+	if _, err := ExtractPort(action.Port); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("port"), action.Port.String(), err.Error()))
+	}
+	return allErrs
+}
+
+func validateExecAction(action *ExecAction, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(action.Command) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("command"), ""))
+	}
+	if len(action.Namespace) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("namespace"), ""))
+	}
+	if len(action.Selector) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("selector"), ""))
+	}
+	allErrs = append(allErrs, validation.ValidateLabels(action.Selector, fldPath.Child("selector"))...)
+	return allErrs
+}
+
+func validateGRPCAction(action *GRPCAction, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(action.Host) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("host"), ""))
+	}
+	if _, err := ExtractPort(action.Port); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("port"), action.Port.String(), err.Error()))
+	}
+	if action.TLS != nil && len(action.TLS.CACert) > 0 {
+		if !x509.NewCertPool().AppendCertsFromPEM([]byte(action.TLS.CACert)) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("tls", "caCert"), "",
+				"no PEM encoded certificate found"))
+		}
+	}
+	return allErrs
+}
+
+func validateWorkloadsAction(action *WorkloadsAction, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(action.Objects) > 0 && len(action.Selector) > 0 {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "may not specify both objects and selector"))
+	}
+	for i, o := range action.Objects {
+		p := fldPath.Child("objects").Index(i)
+		if !IsWorkloadKind(o.Kind) {
+			allErrs = append(allErrs, field.NotSupported(p.Child("kind"), o.Kind, WorkloadKinds))
+		}
+		if len(o.Name) == 0 {
+			allErrs = append(allErrs, field.Required(p.Child("name"), ""))
+		}
+		if len(o.Namespace) == 0 {
+			allErrs = append(allErrs, field.Required(p.Child("namespace"), ""))
+		}
+	}
+	if len(action.Selector) > 0 {
+		if len(action.Namespace) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("namespace"), "selector is set"))
+		}
+		allErrs = append(allErrs, validation.ValidateLabels(action.Selector, fldPath.Child("selector"))...)
+	}
+	return allErrs
+}
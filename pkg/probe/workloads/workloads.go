@@ -0,0 +1,284 @@
+package workloads
+
+import (
+	"bytes"
+	"cloudnativeapp/clm/pkg/probe"
+	"cloudnativeapp/clm/pkg/utils"
+	"context"
+	"fmt"
+	"io"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// New creates a Prober, the client is created from the controller config on the first probe.
+func New() Prober {
+	return &workloadsProber{}
+}
+
+// Prober is an interface defining the Probe object for workload rollout checks.
+type Prober interface {
+	Probe(action *probe.WorkloadsAction) (probe.Result, string, error)
+}
+
+type workloadsProber struct {
+	once   sync.Once
+	client kubernetes.Interface
+	err    error
+}
+
+// Probe checks the rollout status of the workloads.
+// Returns Success when all the workloads are rolled out, or Failure with the workloads not ready.
+func (pr *workloadsProber) Probe(action *probe.WorkloadsAction) (probe.Result, string, error) {
+	pr.once.Do(func() {
+		if config, err := ctrl.GetConfig(); err != nil {
+			pr.err = err
+		} else {
+			pr.client, pr.err = kubernetes.NewForConfig(config)
+		}
+	})
+	if pr.err != nil {
+		return probe.Unknown, "", pr.err
+	}
+	return DoWorkloadsProbe(pr.client, action)
+}
+
+// DoWorkloadsProbe checks the rollout status of the workloads referred or selected by the action.
+// This is exported because some other packages may want to do direct workload checks.
+func DoWorkloadsProbe(client kubernetes.Interface, action *probe.WorkloadsAction) (probe.Result, string, error) {
+	var objects []probe.WorkloadReference
+	if len(action.Selector) > 0 {
+		var err error
+		if objects, err = selectWorkloads(client, action.Namespace, action.Selector); err != nil {
+			return probe.Unknown, "", err
+		}
+		if len(objects) == 0 {
+			return probe.Failure, fmt.Sprintf("no workloads match %v in namespace %s", action.Selector,
+				action.Namespace), nil
+		}
+	} else {
+		objects = action.Objects
+	}
+	var notReady []string
+	for _, o := range objects {
+		ready, msg, err := rolloutStatus(client, o)
+		if err != nil {
+			return probe.Unknown, "", err
+		}
+		if !ready {
+			probe.PLog.V(utils.Warn).Info("workload not ready", "workload", o.String(), "message", msg)
+			notReady = append(notReady, fmt.Sprintf("%s: %s", o.String(), msg))
+		}
+	}
+	if len(notReady) > 0 {
+		return probe.Failure, strings.Join(notReady, "; "), nil
+	}
+	return probe.Success, fmt.Sprintf("%d workloads ready", len(objects)), nil
+}
+
+//selectWorkloads  return the workloads of the supported kinds matching the selector, sorted by kind and name.
+func selectWorkloads(client kubernetes.Interface, namespace string,
+	selector map[string]string) ([]probe.WorkloadReference, error) {
+	ctx := context.Background()
+	opts := metav1.ListOptions{LabelSelector: labels.SelectorFromSet(selector).String()}
+	var objects []probe.WorkloadReference
+	add := func(kind, name string) {
+		objects = append(objects, probe.WorkloadReference{Kind: kind, Name: name, Namespace: namespace})
+	}
+	deploys, err := client.AppsV1().Deployments(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range deploys.Items {
+		add(probe.WorkloadDeployment, i.Name)
+	}
+	sts, err := client.AppsV1().StatefulSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range sts.Items {
+		add(probe.WorkloadStatefulSet, i.Name)
+	}
+	ds, err := client.AppsV1().DaemonSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range ds.Items {
+		add(probe.WorkloadDaemonSet, i.Name)
+	}
+	jobs, err := client.BatchV1().Jobs(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range jobs.Items {
+		add(probe.WorkloadJob, i.Name)
+	}
+	sort.SliceStable(objects, func(i, j int) bool {
+		if objects[i].Kind != objects[j].Kind {
+			return objects[i].Kind < objects[j].Kind
+		}
+		return objects[i].Name < objects[j].Name
+	})
+	return objects, nil
+}
+
+//rolloutStatus  return true when the rollout of the workload is complete, or the message why it is not ready.
+func rolloutStatus(client kubernetes.Interface, o probe.WorkloadReference) (bool, string, error) {
+	ctx := context.Background()
+	var err error
+	var ready bool
+	var msg string
+	switch o.Kind {
+	case probe.WorkloadDeployment:
+		var d *appsv1.Deployment
+		if d, err = client.AppsV1().Deployments(o.Namespace).Get(ctx, o.Name, metav1.GetOptions{}); err == nil {
+			ready, msg = deploymentStatus(d)
+		}
+	case probe.WorkloadStatefulSet:
+		var s *appsv1.StatefulSet
+		if s, err = client.AppsV1().StatefulSets(o.Namespace).Get(ctx, o.Name, metav1.GetOptions{}); err == nil {
+			ready, msg = statefulSetStatus(s)
+		}
+	case probe.WorkloadDaemonSet:
+		var d *appsv1.DaemonSet
+		if d, err = client.AppsV1().DaemonSets(o.Namespace).Get(ctx, o.Name, metav1.GetOptions{}); err == nil {
+			ready, msg = daemonSetStatus(d)
+		}
+	case probe.WorkloadJob:
+		var j *batchv1.Job
+		if j, err = client.BatchV1().Jobs(o.Namespace).Get(ctx, o.Name, metav1.GetOptions{}); err == nil {
+			ready, msg = jobStatus(j)
+		}
+	default:
+		return false, "", fmt.Errorf("unsupported workload kind %s", o.Kind)
+	}
+	if apierrors.IsNotFound(err) {
+		return false, "not found", nil
+	}
+	return ready, msg, err
+}
+
+func deploymentStatus(d *appsv1.Deployment) (bool, string) {
+	if d.Generation > d.Status.ObservedGeneration {
+		return false, "waiting for spec update to be observed"
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			return false, "exceeded its progress deadline"
+		}
+	}
+	replicas := replicasOf(d.Spec.Replicas)
+	if d.Status.UpdatedReplicas < replicas {
+		return false, fmt.Sprintf("%d out of %d new replicas have been updated", d.Status.UpdatedReplicas, replicas)
+	}
+	if d.Status.Replicas > d.Status.UpdatedReplicas {
+		return false, fmt.Sprintf("%d old replicas are pending termination", d.Status.Replicas-d.Status.UpdatedReplicas)
+	}
+	if d.Status.AvailableReplicas < d.Status.UpdatedReplicas {
+		return false, fmt.Sprintf("%d of %d updated replicas are available", d.Status.AvailableReplicas,
+			d.Status.UpdatedReplicas)
+	}
+	return true, ""
+}
+
+func statefulSetStatus(s *appsv1.StatefulSet) (bool, string) {
+	if s.Generation > s.Status.ObservedGeneration {
+		return false, "waiting for spec update to be observed"
+	}
+	replicas := replicasOf(s.Spec.Replicas)
+	if s.Status.ReadyReplicas < replicas {
+		return false, fmt.Sprintf("%d of %d replicas are ready", s.Status.ReadyReplicas, replicas)
+	}
+	if s.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		return true, ""
+	}
+	if r := s.Spec.UpdateStrategy.RollingUpdate; r != nil && r.Partition != nil && *r.Partition > 0 {
+		if s.Status.UpdatedReplicas < replicas-*r.Partition {
+			return false, fmt.Sprintf("%d out of %d new replicas have been updated", s.Status.UpdatedReplicas,
+				replicas-*r.Partition)
+		}
+		return true, ""
+	}
+	if s.Status.UpdateRevision != s.Status.CurrentRevision {
+		return false, fmt.Sprintf("%d out of %d new replicas have been updated", s.Status.UpdatedReplicas, replicas)
+	}
+	return true, ""
+}
+
+func daemonSetStatus(d *appsv1.DaemonSet) (bool, string) {
+	if d.Generation > d.Status.ObservedGeneration {
+		return false, "waiting for spec update to be observed"
+	}
+	if d.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType {
+		return true, ""
+	}
+	if d.Status.UpdatedNumberScheduled < d.Status.DesiredNumberScheduled {
+		return false, fmt.Sprintf("%d out of %d new pods have been updated", d.Status.UpdatedNumberScheduled,
+			d.Status.DesiredNumberScheduled)
+	}
+	if d.Status.NumberAvailable < d.Status.DesiredNumberScheduled {
+		return false, fmt.Sprintf("%d of %d updated pods are available", d.Status.NumberAvailable,
+			d.Status.DesiredNumberScheduled)
+	}
+	return true, ""
+}
+
+func jobStatus(j *batchv1.Job) (bool, string) {
+	for _, c := range j.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return true, ""
+		case batchv1.JobFailed:
+			return false, fmt.Sprintf("failed: %s %s", c.Reason, c.Message)
+		}
+	}
+	completions := replicasOf(j.Spec.Completions)
+	return false, fmt.Sprintf("%d of %d completions succeeded", j.Status.Succeeded, completions)
+}
+
+func replicasOf(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+//ObjectsFromManifest  return the workloads of the supported kinds in the multi-document yaml manifest, the
+//workloads without namespace are in the default namespace given.
+func ObjectsFromManifest(manifest, namespace string) ([]probe.WorkloadReference, error) {
+	var objects []probe.WorkloadReference
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewBufferString(manifest), 4096)
+	for {
+		var obj struct {
+			Kind     string            `json:"kind"`
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		}
+		if err := decoder.Decode(&obj); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if !probe.IsWorkloadKind(obj.Kind) || len(obj.Metadata.Name) == 0 {
+			continue
+		}
+		ns := obj.Metadata.Namespace
+		if len(ns) == 0 {
+			ns = namespace
+		}
+		objects = append(objects, probe.WorkloadReference{Kind: obj.Kind, Name: obj.Metadata.Name, Namespace: ns})
+	}
+	return objects, nil
+}
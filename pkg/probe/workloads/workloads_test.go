@@ -0,0 +1,119 @@
+package workloads
+
+import (
+	"cloudnativeapp/clm/pkg/probe"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testDeployment(name string, replicas, updated, available int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Generation: 2,
+			Labels: map[string]string{"app": "web"}},
+		Spec: appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: updated, UpdatedReplicas: updated,
+			AvailableReplicas: available},
+	}
+}
+
+func TestDoWorkloadsProbe(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		testDeployment("web", 3, 3, 3),
+		testDeployment("api", 3, 3, 1),
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: "default", Labels: map[string]string{"app": "web"}},
+			Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}},
+		})
+	web := probe.WorkloadReference{Kind: probe.WorkloadDeployment, Name: "web", Namespace: "default"}
+	migrate := probe.WorkloadReference{Kind: probe.WorkloadJob, Name: "migrate", Namespace: "default"}
+	result, out, err := DoWorkloadsProbe(client, &probe.WorkloadsAction{Objects: []probe.WorkloadReference{web, migrate}})
+	if result != probe.Success || err != nil {
+		t.Errorf("rolled out workloads should succeed, got %v %q %v", result, out, err)
+	}
+
+	api := probe.WorkloadReference{Kind: probe.WorkloadDeployment, Name: "api", Namespace: "default"}
+	missing := probe.WorkloadReference{Kind: probe.WorkloadStatefulSet, Name: "db", Namespace: "default"}
+	result, out, err = DoWorkloadsProbe(client, &probe.WorkloadsAction{Objects: []probe.WorkloadReference{web, api,
+		missing}})
+	if result != probe.Failure || err != nil || !strings.Contains(out, "Deployment default/api: 1 of 3") ||
+		!strings.Contains(out, "StatefulSet default/db: not found") || strings.Contains(out, "/web") {
+		t.Errorf("workloads not ready should be reported, got %v %q %v", result, out, err)
+	}
+
+	result, out, err = DoWorkloadsProbe(client, &probe.WorkloadsAction{Namespace: "default",
+		Selector: map[string]string{"app": "web"}})
+	if result != probe.Failure || err != nil || !strings.HasPrefix(out, "Deployment default/api") {
+		t.Errorf("selected workloads not ready should be reported, got %v %q %v", result, out, err)
+	}
+	result, _, err = DoWorkloadsProbe(client, &probe.WorkloadsAction{Namespace: "default",
+		Selector: map[string]string{"app": "cache"}})
+	if result != probe.Failure || err != nil {
+		t.Errorf("no workloads selected should fail, got %v %v", result, err)
+	}
+}
+
+func TestRolloutStatus(t *testing.T) {
+	d := testDeployment("web", 3, 3, 3)
+	d.Status.ObservedGeneration = 1
+	if ready, _ := deploymentStatus(d); ready {
+		t.Errorf("deployment spec update not observed should not be ready")
+	}
+	replicas, partition := int32(3), int32(2)
+	s := &appsv1.StatefulSet{
+		Spec: appsv1.StatefulSetSpec{Replicas: &replicas},
+		Status: appsv1.StatefulSetStatus{ReadyReplicas: 3, UpdatedReplicas: 1, CurrentRevision: "r1",
+			UpdateRevision: "r2"},
+	}
+	if ready, msg := statefulSetStatus(s); ready || !strings.Contains(msg, "1 out of 3") {
+		t.Errorf("statefulset rolling update should not be ready, got %q", msg)
+	}
+	s.Spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition}
+	if ready, msg := statefulSetStatus(s); !ready {
+		t.Errorf("statefulset partition updated should be ready, got %q", msg)
+	}
+	ds := &appsv1.DaemonSet{Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, UpdatedNumberScheduled: 2,
+		NumberAvailable: 1}}
+	if ready, msg := daemonSetStatus(ds); ready || !strings.Contains(msg, "1 of 2") {
+		t.Errorf("daemonset pods not available should not be ready, got %q", msg)
+	}
+	j := &batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+		{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded"}}}}
+	if ready, msg := jobStatus(j); ready || !strings.Contains(msg, "BackoffLimitExceeded") {
+		t.Errorf("failed job should not be ready, got %q", msg)
+	}
+}
+
+func TestObjectsFromManifest(t *testing.T) {
+	manifest := `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  namespace: jobs
+`
+	objects, err := ObjectsFromManifest(manifest, "app")
+	expected := []probe.WorkloadReference{
+		{Kind: probe.WorkloadDeployment, Name: "web", Namespace: "app"},
+		{Kind: probe.WorkloadJob, Name: "migrate", Namespace: "jobs"},
+	}
+	if err != nil || !reflect.DeepEqual(objects, expected) {
+		t.Errorf("workloads in manifest expected %v, got %v %v", expected, objects, err)
+	}
+}
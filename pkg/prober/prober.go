@@ -1,13 +1,16 @@
 package prober
 
 import (
+	"cloudnativeapp/clm/pkg/metrics"
 	"cloudnativeapp/clm/pkg/probe"
 	execprobe "cloudnativeapp/clm/pkg/probe/exec"
+	grpcprobe "cloudnativeapp/clm/pkg/probe/grpc"
+	helmprobe "cloudnativeapp/clm/pkg/probe/helm"
 	httpprobe "cloudnativeapp/clm/pkg/probe/http"
 	tcpprobe "cloudnativeapp/clm/pkg/probe/tcp"
+	workloadsprobe "cloudnativeapp/clm/pkg/probe/workloads"
 	"cloudnativeapp/clm/pkg/utils"
 	"fmt"
-	"k8s.io/apimachinery/pkg/util/intstr"
 	"net"
 	"net/http"
 	"net/url"
@@ -20,17 +23,23 @@ import (
 var probeLog = ctrl.Log.WithName("probe")
 
 type Prober struct {
-	exec execprobe.Prober
-	http httpprobe.Prober
-	tcp  tcpprobe.Prober
+	exec      execprobe.Prober
+	http      httpprobe.Prober
+	tcp       tcpprobe.Prober
+	grpc      grpcprobe.Prober
+	workloads workloadsprobe.Prober
+	helm      helmprobe.Prober
 }
 
 func NewProber() *Prober {
 	const followNonLocalRedirects = false
 	return &Prober{
-		exec: execprobe.New(),
-		http: httpprobe.New(followNonLocalRedirects),
-		tcp:  tcpprobe.New(),
+		exec:      execprobe.New(),
+		http:      httpprobe.New(followNonLocalRedirects),
+		tcp:       tcpprobe.New(),
+		grpc:      grpcprobe.New(),
+		workloads: workloadsprobe.New(),
+		helm:      helmprobe.New(),
 	}
 }
 
@@ -39,7 +48,9 @@ func (pb *Prober) RunProbeWithRetries(retries int, p *probe.Probe) (probe.Result
 	var result probe.Result
 	var output string
 	for i := 0; i < retries; i++ {
+		start := time.Now()
 		result, output, err = pb.runProbe(p)
+		metrics.ObserveProbe(p.Handler.Type(), string(result), start)
 		if err == nil {
 			return result, output, nil
 		}
@@ -47,6 +58,21 @@ func (pb *Prober) RunProbeWithRetries(retries int, p *probe.Probe) (probe.Result
 	return result, output, err
 }
 
+//HelmTested  return the revision of the helm release whose tests passed, 0 when none.
+func (pb *Prober) HelmTested(action *probe.HelmAction) int {
+	return pb.helm.Tested(action)
+}
+
+//SetHelmTested  record the revision of the helm release whose tests passed before restart.
+func (pb *Prober) SetHelmTested(action *probe.HelmAction, revision int) {
+	pb.helm.SetTested(action, revision)
+}
+
+//ForgetHelm  drop the tests of the helm release deleted.
+func (pb *Prober) ForgetHelm(action *probe.HelmAction) {
+	pb.helm.Forget(action)
+}
+
 func (pb *Prober) runProbe(p *probe.Probe) (probe.Result, string, error) {
 	timeout := time.Duration(p.TimeoutSeconds) * time.Second
 	if p.Handler.HTTPGet != nil {
@@ -55,7 +81,7 @@ func (pb *Prober) runProbe(p *probe.Probe) (probe.Result, string, error) {
 		if len(p.Handler.HTTPGet.Namespace) > 0 {
 			host = host + "." + p.Handler.HTTPGet.Namespace
 		}
-		port, err := extractPort(p.Handler.HTTPGet.Port)
+		port, err := probe.ExtractPort(p.Handler.HTTPGet.Port)
 		if err != nil {
 			return probe.Unknown, "", err
 		}
@@ -64,11 +90,11 @@ func (pb *Prober) runProbe(p *probe.Probe) (probe.Result, string, error) {
 		url := formatURL(scheme, host, port, path)
 		headers := buildHeader(p.Handler.HTTPGet.HTTPHeaders)
 		probeLog.V(utils.Debug).Info(fmt.Sprintf("HTTP-Probe Headers: %v", headers))
-		return pb.http.Probe(url, headers, timeout)
+		return pb.http.Probe(url, headers, p.Handler.HTTPGet.Success, timeout)
 
 	}
 	if p.Handler.TCPSocket != nil {
-		port, err := extractPort(p.Handler.TCPSocket.Port)
+		port, err := probe.ExtractPort(p.Handler.TCPSocket.Port)
 		if err != nil {
 			return probe.Unknown, "", err
 		}
@@ -79,28 +105,36 @@ func (pb *Prober) runProbe(p *probe.Probe) (probe.Result, string, error) {
 		probeLog.V(utils.Debug).Info(fmt.Sprintf("TCP-Probe Host: %v, Port: %v, Timeout: %v", host, port, timeout))
 		return pb.tcp.Probe(host, port, timeout)
 	}
-	probeLog.V(utils.Warn).Info("Failed to find probe builder")
-	return probe.Unknown, "", fmt.Errorf("missing probe handler")
-}
-
-func extractPort(param intstr.IntOrString) (int, error) {
-	port := -1
-	switch param.Type {
-	case intstr.Int:
-		port = param.IntValue()
-	case intstr.String:
-		p, err := strconv.Atoi(param.StrVal)
+	if p.Handler.GRPC != nil {
+		port, err := probe.ExtractPort(p.Handler.GRPC.Port)
 		if err != nil {
-			return -1, err
+			return probe.Unknown, "", err
+		}
+		host := p.Handler.GRPC.Host
+		if len(p.Handler.GRPC.Namespace) > 0 {
+			host = host + "." + p.Handler.GRPC.Namespace
 		}
-		port = p
-	default:
-		return port, fmt.Errorf("intOrString had no kind: %+v", param)
+		probeLog.V(utils.Debug).Info(fmt.Sprintf("GRPC-Probe Host: %v, Port: %v, Service: %v, Timeout: %v", host, port,
+			p.Handler.GRPC.Service, timeout))
+		return pb.grpc.Probe(host, port, p.Handler.GRPC.Service, p.Handler.GRPC.TLS, timeout)
 	}
-	if port > 0 && port < 65536 {
-		return port, nil
+	if p.Handler.Exec != nil {
+		probeLog.V(utils.Debug).Info(fmt.Sprintf("Exec-Probe Namespace: %v, Selector: %v, Command: %v",
+			p.Handler.Exec.Namespace, p.Handler.Exec.Selector, p.Handler.Exec.Command))
+		return pb.exec.Probe(p.Handler.Exec, timeout)
 	}
-	return port, fmt.Errorf("invalid port number: %v", port)
+	if p.Handler.Workloads != nil {
+		probeLog.V(utils.Debug).Info(fmt.Sprintf("Workloads-Probe Objects: %v, Namespace: %v, Selector: %v",
+			p.Handler.Workloads.Objects, p.Handler.Workloads.Namespace, p.Handler.Workloads.Selector))
+		return pb.workloads.Probe(p.Handler.Workloads)
+	}
+	if p.Handler.Helm != nil {
+		probeLog.V(utils.Debug).Info(fmt.Sprintf("Helm-Probe Release: %v, Namespace: %v, Test: %v",
+			p.Handler.Helm.ReleaseName, p.Handler.Helm.Namespace, p.Handler.Helm.Test))
+		return pb.helm.Probe(p.Handler.Helm, timeout)
+	}
+	probeLog.V(utils.Warn).Info("Failed to find probe builder")
+	return probe.Unknown, "", fmt.Errorf("missing probe handler")
 }
 
 func formatURL(scheme string, host string, port int, path string) *url.URL {
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+const (
+	// Unchanged lines kept around the changed lines in diff.
+	diffContext = 3
+	// Replaces the values of the secrets in diff.
+	redacted = "<redacted>"
+)
+
+var documentSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+
+//LineDiff  return the unified diff of the lines from one text to the other, empty when they are the same.
+func LineDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	a, b := splitLines(from), splitLines(to)
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	type line struct {
+		op   byte
+		text string
+		i, j int
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i], i, j})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			lines = append(lines, line{'+', b[j], i, j})
+			j++
+		default:
+			lines = append(lines, line{'-', a[i], i, j})
+			i++
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", fromName, toName))
+	for k := 0; k < len(lines); {
+		if lines[k].op == ' ' {
+			k++
+			continue
+		}
+		// Extend the hunk until the unchanged lines are more than twice the context.
+		start := k - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := k
+		for end < len(lines) {
+			if lines[end].op != ' ' {
+				end++
+				continue
+			}
+			n := end
+			for n < len(lines) && lines[n].op == ' ' {
+				n++
+			}
+			if n == len(lines) || n-end > 2*diffContext {
+				break
+			}
+			end = n
+		}
+		stop := end + diffContext
+		if stop > len(lines) {
+			stop = len(lines)
+		}
+		var fromLen, toLen int
+		for _, l := range lines[start:stop] {
+			if l.op != '+' {
+				fromLen++
+			}
+			if l.op != '-' {
+				toLen++
+			}
+		}
+		fromStart, toStart := lines[start].i+1, lines[start].j+1
+		// Empty ranges start at the line before.
+		if fromLen == 0 {
+			fromStart--
+		}
+		if toLen == 0 {
+			toStart--
+		}
+		sb.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", fromStart, fromLen, toStart, toLen))
+		for _, l := range lines[start:stop] {
+			sb.WriteByte(l.op)
+			sb.WriteString(l.text)
+			sb.WriteByte('\n')
+		}
+		k = stop
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+//RedactSecrets  replace the values in data and stringData of the secrets in the yaml manifests, so the secrets are
+//not exposed in diff. The other documents are kept as they are.
+func RedactSecrets(manifest string) string {
+	var sb strings.Builder
+	start := 0
+	for _, sep := range append(documentSeparator.FindAllStringIndex(manifest, -1), []int{len(manifest), len(manifest)}) {
+		sb.WriteString(redactSecret(manifest[start:sep[0]]))
+		sb.WriteString(manifest[sep[0]:sep[1]])
+		start = sep[1]
+	}
+	return sb.String()
+}
+
+func redactSecret(doc string) string {
+	obj := make(map[string]interface{})
+	if err := yaml.Unmarshal([]byte(doc), &obj); err != nil || obj["kind"] != "Secret" || obj["apiVersion"] != "v1" {
+		return doc
+	}
+	for _, f := range []string{"data", "stringData"} {
+		if values, ok := obj[f].(map[string]interface{}); ok {
+			for k := range values {
+				values[k] = redacted
+			}
+		}
+	}
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return redacted + "\n"
+	}
+	if strings.HasPrefix(doc, "\n") {
+		// Keep the document apart from the separator.
+		return "\n" + string(b)
+	}
+	return string(b)
+}
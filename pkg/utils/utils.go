@@ -1,8 +1,7 @@
 package utils
 
 import (
-	"strconv"
-	"strings"
+	"github.com/Masterminds/semver/v3"
 )
 
 const (
@@ -15,6 +14,11 @@ const (
 	DependencyAbsentError     = "dependency absent"
 	DependencyPullError       = "dependency pull error"
 	DependencyWaiting         = "dependency absent waiting"
+	DependencyUnsatisfiable   = "dependency version constraint unsatisfiable"
+	DependsOnWaiting          = "dependsOn modules waiting"
+	DependsOnInvalid          = "dependsOn modules missing or cycle found"
+	DeletionBlocked           = "deletion blocked by crd releases depending on it"
+	RecoveryExhausted         = "recover attempts exhausted"
 )
 
 const (
@@ -24,6 +28,14 @@ const (
 	Debug
 )
 
+//NamespacedKey  return namespace/name, or name for the cluster-scoped object.
+func NamespacedKey(namespace, name string) string {
+	if len(namespace) == 0 {
+		return name
+	}
+	return namespace + "/" + name
+}
+
 func Contains(list []string, s string) bool {
 	for _, v := range list {
 		if v == s {
@@ -49,6 +61,7 @@ func IgnoreWaitingErr(err error) error {
 	e := err.Error()
 	if e == ImplementNotFound ||
 		e == PreCheckWaiting ||
+		e == DependsOnWaiting ||
 		e == DependencyWaiting {
 		return nil
 	}
@@ -64,6 +77,7 @@ func IgnoreKnownErr(err error) error {
 		e == ModuleStateAbnormal ||
 		e == ReleaseStateAbnormal ||
 		e == PreCheckWaiting ||
+		e == DependsOnWaiting ||
 		e == DependencyStateAbnormal ||
 		e == DependencyVersionMismatch ||
 		e == DependencyAbsentError ||
@@ -74,7 +88,7 @@ func IgnoreKnownErr(err error) error {
 	return err
 }
 
-//VersionMatch :  min <= current < max
+//VersionMatch :  min <= current < max, versions are compared as semantic versions.
 func VersionMatch(current, min, max string) bool {
 	if current == min || current == max {
 		return true
@@ -89,30 +103,13 @@ func VersionMatch(current, min, max string) bool {
 }
 
 func versionEqualOrNewer(v1, v2 string) bool {
-	nslice := strings.Split(v1, ".")
-	oslice := strings.Split(v2, ".")
-	var maxlen int
-	if len(nslice) > len(oslice) {
-		maxlen = len(oslice)
-	} else {
-		maxlen = len(nslice)
+	n, err := semver.NewVersion(v1)
+	if err != nil {
+		return true
 	}
-
-	for i := 0; i < maxlen; i++ {
-		tmpn, err := strconv.Atoi(nslice[i])
-		if err != nil {
-			return true
-		}
-
-		tmpo, err := strconv.Atoi(oslice[i])
-		if err != nil {
-			return true
-		}
-
-		if tmpn > tmpo {
-			return true
-		}
+	o, err := semver.NewVersion(v2)
+	if err != nil {
+		return true
 	}
-
-	return len(nslice) > len(oslice)
+	return !n.LessThan(o)
 }
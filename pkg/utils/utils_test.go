@@ -3,10 +3,74 @@ package utils
 import "testing"
 
 func TestVersionMatch(t *testing.T) {
-	ok := VersionMatch("1.0.6", "1.0.1", "1.0.5")
-	if ok {
-		t.Log("ok")
-	} else {
-		t.Log("not ok")
+	cases := []struct {
+		current, min, max string
+		match             bool
+	}{
+		{"1.0.6", "1.0.1", "1.0.5", false},
+		{"1.0.3", "1.0.1", "1.0.5", true},
+		{"1.10.0", "1.9.0", "", true},
+		{"1.9.0", "1.10.0", "", false},
+		{"2.0.0", "1.10.0", "", true},
+		{"1.0.0-beta", "1.0.0", "", false},
+	}
+	for _, c := range cases {
+		if ok := VersionMatch(c.current, c.min, c.max); ok != c.match {
+			t.Errorf("version %s in [%s, %s) expected %v, got %v", c.current, c.min, c.max, c.match, ok)
+		}
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	if d := LineDiff("a", "b", "x\ny\n", "x\ny\n"); d != "" {
+		t.Errorf("same text should have no diff, got %q", d)
+	}
+	expected := "--- a\n+++ b\n@@ -1,3 +1,3 @@\n x\n-y\n+z\n w\n"
+	if d := LineDiff("a", "b", "x\ny\nw\n", "x\nz\nw\n"); d != expected {
+		t.Errorf("expected diff %q, got %q", expected, d)
+	}
+	expected = "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+x\n"
+	if d := LineDiff("a", "b", "", "x"); d != expected {
+		t.Errorf("expected diff %q, got %q", expected, d)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	manifest := `---
+# Source: app/templates/secret.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app
+data:
+  password: cGFzc3dvcmQ=
+stringData:
+  token: secret-token
+---
+apiVersion: v1
+kind: ConfigMap
+data:
+  password: visible
+`
+	expected := `---
+apiVersion: v1
+data:
+  password: <redacted>
+kind: Secret
+metadata:
+  name: app
+stringData:
+  token: <redacted>
+---
+apiVersion: v1
+kind: ConfigMap
+data:
+  password: visible
+`
+	if r := RedactSecrets(manifest); r != expected {
+		t.Errorf("expected %q, got %q", expected, r)
+	}
+	if r := RedactSecrets("kind: Deployment\n"); r != "kind: Deployment\n" {
+		t.Errorf("manifest without secrets changed: %q", r)
 	}
 }